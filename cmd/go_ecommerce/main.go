@@ -5,6 +5,8 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/fatihesergg/go_ecommerce/internal/handler"
 	"github.com/fatihesergg/go_ecommerce/internal/middleware"
@@ -31,7 +33,7 @@ func main() {
 	// Database
 	dsn := "postgresql://localhost/go_ecommerce?user=fatih&password=test"
 	address := ":3000"
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{TranslateError: true})
 	if err != nil {
 		panic(err)
 	}
@@ -56,34 +58,220 @@ func main() {
 	db.AutoMigrate(&model.Order{})
 	db.AutoMigrate(&model.OrderItem{})
 	db.AutoMigrate(&model.Payment{})
-	db.AutoMigrate(&model.Review{})
+	db.AutoMigrate(&model.ProductRating{})
+	// A user's review only has to be unique among the reviews that aren't
+	// deleted. The index can't be built while duplicates from before are
+	// left, so they are deleted once the columns are migrated
+	if db.Migrator().HasIndex(&model.Review{}, "idx_review_user_product") {
+		db.Migrator().DropIndex(&model.Review{}, "idx_review_user_product")
+	}
+	if err := db.AutoMigrate(&model.Review{}); err != nil {
+		if err := storage.NewReviewRepository(db).DeleteDuplicates(); err != nil {
+			sugar.Errorf("Error while deleting duplicate reviews: %v", err)
+		}
+		if err := db.AutoMigrate(&model.Review{}); err != nil {
+			sugar.Errorf("Error while migrating reviews: %v", err)
+		}
+	}
+	db.AutoMigrate(&model.ReviewVote{})
+	db.AutoMigrate(&model.ReviewReport{})
+	db.AutoMigrate(&model.ReviewReply{})
+	db.AutoMigrate(&model.Notification{})
+	db.AutoMigrate(&model.SlugRedirect{})
+	db.AutoMigrate(&model.AttributeDefinition{})
+	db.AutoMigrate(&model.ProductAttributeValue{})
+	db.AutoMigrate(&model.ImportJob{})
+	db.AutoMigrate(&model.ProductFeed{})
 	db.AutoMigrate(&model.User{})
+	db.AutoMigrate(&model.ExchangeRate{})
+	db.AutoMigrate(&model.ProductPrice{})
+	db.AutoMigrate(&model.PriceHistory{})
+	db.AutoMigrate(&model.ScheduledPrice{})
+	db.AutoMigrate(&model.StockMovement{})
+	db.AutoMigrate(&model.IdempotencyKey{})
+	db.AutoMigrate(&model.Coupon{})
+	db.AutoMigrate(&model.CouponUsage{})
+	db.AutoMigrate(&model.OrderDiscount{})
+	db.AutoMigrate(&model.Promotion{})
+	db.AutoMigrate(&model.PromotionTier{})
+	db.AutoMigrate(&model.TaxRate{})
+	db.AutoMigrate(&model.OrderItemTax{})
+	db.AutoMigrate(&model.Address{})
+	db.AutoMigrate(&model.ShippingZone{})
+	db.AutoMigrate(&model.ShippingZoneCountry{})
+	db.AutoMigrate(&model.ShippingMethod{})
+	db.AutoMigrate(&model.Shipment{})
+	db.AutoMigrate(&model.ShipmentItem{})
+	db.AutoMigrate(&model.ReturnRequest{})
+	db.AutoMigrate(&model.ReturnItem{})
+	db.AutoMigrate(&model.ReturnHistory{})
+	db.AutoMigrate(&model.Refund{})
+
+	// Product feeds
+	storeURL := os.Getenv("STORE_URL")
+	if storeURL == "" {
+		storeURL = "http://localhost:3000"
+	}
+	feedInterval, err := time.ParseDuration(os.Getenv("FEED_INTERVAL"))
+	if err != nil || feedInterval <= 0 {
+		feedInterval = 6 * time.Hour
+	}
+
+	// Orders left pending this long are cancelled, only when it is set
+	var orderExpiry time.Duration
+	if value := os.Getenv("ORDER_EXPIRY"); value != "" {
+		orderExpiry, err = time.ParseDuration(value)
+		if err != nil || orderExpiry <= 0 {
+			sugar.Errorf("Invalid ORDER_EXPIRY %q, pending orders won't expire", value)
+			orderExpiry = 0
+		}
+	}
+
+	// Review screening
+	reviewScreener := service.NewReviewScreener(strings.Split(os.Getenv("REVIEW_BANNED_WORDS"), ","), 10, 2000)
 
 	validate := validator.New(validator.WithRequiredStructEnabled())
 
 	// Repositories
 	categoryRepo := storage.NewCategoryRepository(db)
+	slugRedirectRepo := storage.NewSlugRedirectRepository(db)
+	attributeRepo := storage.NewAttributeRepository(db)
 	productRepo := storage.NewProductRepository(db)
 	userRepo := storage.NewUserRepository(db)
 	reviewRepo := storage.NewReviewRepository(db)
 	orderRepo := storage.NewOrderRepository(db)
 	paymentRepo := storage.NewPaymentRepository(db)
+	exchangeRateRepo := storage.NewExchangeRateRepository(db)
+	productPriceRepo := storage.NewProductPriceRepository(db)
+	priceHistoryRepo := storage.NewPriceHistoryRepository(db)
+	scheduledPriceRepo := storage.NewScheduledPriceRepository(db)
+	stockMovementRepo := storage.NewStockMovementRepository(db)
+	idempotencyRepo := storage.NewIdempotencyRepository(db)
+	couponRepo := storage.NewCouponRepository(db)
+	promotionRepo := storage.NewPromotionRepository(db)
+	taxRateRepo := storage.NewTaxRateRepository(db)
+	addressRepo := storage.NewAddressRepository(db)
+	shippingZoneRepo := storage.NewShippingZoneRepository(db)
+	shippingMethodRepo := storage.NewShippingMethodRepository(db)
+	shipmentRepo := storage.NewShipmentRepository(db)
+	returnRepo := storage.NewReturnRepository(db)
+	refundRepo := storage.NewRefundRepository(db)
+	notificationRepo := storage.NewNotificationRepository(db)
+	importJobRepo := storage.NewImportJobRepository(db)
+	productFeedRepo := storage.NewProductFeedRepository(db)
 
 	// Services
-	categoryService := service.NewCategoryService(*categoryRepo)
-	productService := service.NewProductService(*productRepo)
+	categoryService := service.NewCategoryService(*categoryRepo, *slugRedirectRepo)
+	productService := service.NewProductService(*productRepo, *slugRedirectRepo, *attributeRepo, *categoryRepo)
 	userService := service.NewUserService(*userRepo)
-	reviewService := service.NewReviewService(*reviewRepo)
+	reviewService := service.NewReviewService(*reviewRepo, *orderRepo, reviewScreener)
 	orderService := service.NewOrderService(*orderRepo)
-	paymentService := service.NewPaymentService(*paymentRepo)
+	paymentService := service.NewPaymentService(*paymentRepo, *refundRepo)
+	currencyService := service.NewCurrencyService(*exchangeRateRepo, *productPriceRepo)
+	priceService := service.NewPriceService(*priceHistoryRepo, *scheduledPriceRepo)
+	stockService := service.NewStockService(*stockMovementRepo, *productRepo)
+	idempotencyService := service.NewIdempotencyService(*idempotencyRepo)
+	couponService := service.NewCouponService(*couponRepo)
+	promotionService := service.NewPromotionService(*promotionRepo)
+	taxService := service.NewTaxService(*taxRateRepo)
+	addressService := service.NewAddressService(*addressRepo)
+	shippingService := service.NewShippingService(*shippingZoneRepo, *shippingMethodRepo)
+	shipmentService := service.NewShipmentService(*shipmentRepo, *orderRepo)
+	returnService := service.NewReturnService(*returnRepo)
+	notificationService := service.NewNotificationService(*notificationRepo)
+	attributeService := service.NewAttributeService(*attributeRepo, *categoryRepo)
+	importService := service.NewImportService(*importJobRepo, *productService, *categoryRepo, validate)
+	feedService := service.NewFeedService(*productFeedRepo, *productRepo, *categoryRepo, *priceService, storeURL)
+
+	// Slugs of the products and categories created before slugs existed
+	if err := categoryService.BackfillSlugs(); err != nil {
+		sugar.Errorf("Error while backfilling category slugs: %v", err)
+	}
+	if err := productService.BackfillSlugs(); err != nil {
+		sugar.Errorf("Error while backfilling product slugs: %v", err)
+	}
+
+	// Stock of the products from before stock movements were recorded
+	if err := stockService.BackfillOpening(); err != nil {
+		sugar.Errorf("Error while backfilling opening stock: %v", err)
+	}
+
+	// Prices of the products from before price changes were recorded
+	if err := priceService.BackfillBaseline(); err != nil {
+		sugar.Errorf("Error while backfilling baseline prices: %v", err)
+	}
+
+	// Order items saved before product names and SKUs were captured
+	if err := orderService.BackfillSnapshots(); err != nil {
+		sugar.Errorf("Error while backfilling order item snapshots: %v", err)
+	}
+
+	// Values of enum options removed before updates deleted them
+	if err := attributeService.PruneOptions(); err != nil {
+		sugar.Errorf("Error while pruning attribute values: %v", err)
+	}
+
+	// Imports interrupted by a restart can't be resumed
+	if err := importService.FailUnfinished(); err != nil {
+		sugar.Errorf("Error while failing unfinished imports: %v", err)
+	}
+
+	// Feeds are regenerated on a schedule so marketplaces see recent stock
+	// and prices
+	go func() {
+		ticker := time.NewTicker(feedInterval)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			if _, err := feedService.GenerateAll(); err != nil {
+				sugar.Errorf("Error while generating product feeds: %v", err)
+			}
+		}
+	}()
+
+	// Scheduled prices start and end within a minute of their time
+	go func() {
+		ticker := time.NewTicker(time.Minute)
+		defer ticker.Stop()
+		for ; ; <-ticker.C {
+			if _, err := priceService.ApplySchedules(); err != nil {
+				sugar.Errorf("Error while applying scheduled prices: %v", err)
+			}
+		}
+	}()
+
+	// Abandoned checkouts give their stock and coupons back
+	if orderExpiry > 0 {
+		go func() {
+			ticker := time.NewTicker(10 * time.Minute)
+			defer ticker.Stop()
+			for ; ; <-ticker.C {
+				if _, err := orderService.ExpirePending(orderExpiry); err != nil {
+					sugar.Errorf("Error while expiring pending orders: %v", err)
+				}
+			}
+		}()
+	}
 
 	// Handlers
 	categoryHandler := handler.NewCategoryHandler(*categoryService, validate)
-	producthandler := handler.NewProductHandler(*productService, *categoryService, validate)
+	producthandler := handler.NewProductHandler(*productService, *categoryService, *currencyService, *priceService, validate)
 	authHandler := handler.NewAuthHandler(*userService, validate)
 	reviewHandler := handler.NewReviewHandler(*reviewService, *userService, *productService, validate)
-	orderHandler := handler.NewOrderHandler(*orderService, *productService, *categoryService, *userService, validate)
+	orderHandler := handler.NewOrderHandler(*orderService, *productService, *categoryService, *userService, *currencyService, *couponService, *promotionService, *taxService, *addressService, *shippingService, validate)
 	paymentHandler := handler.NewPaymentHandler(*paymentService, *orderService, validate)
+	currencyHandler := handler.NewCurrencyHandler(*currencyService, validate)
+	couponHandler := handler.NewCouponHandler(*couponService, *productService, *categoryService, validate)
+	promotionHandler := handler.NewPromotionHandler(*promotionService, *productService, *categoryService, validate)
+	taxHandler := handler.NewTaxHandler(*taxService, validate)
+	addressHandler := handler.NewAddressHandler(*addressService, validate)
+	shippingHandler := handler.NewShippingHandler(*shippingService, validate)
+	shipmentHandler := handler.NewShipmentHandler(*shipmentService, *orderService, validate)
+	returnHandler := handler.NewReturnHandler(*returnService, *orderService, *paymentService, validate)
+	notificationHandler := handler.NewNotificationHandler(*notificationService)
+	attributeHandler := handler.NewAttributeHandler(*attributeService, validate)
+	importHandler := handler.NewImportHandler(*importService)
+	feedHandler := handler.NewFeedHandler(*feedService)
+	stockHandler := handler.NewStockHandler(*stockService, validate)
 
 	fs := http.FileServer(http.Dir("../../docs"))
 	apiRouter := http.NewServeMux()
@@ -94,6 +282,18 @@ func main() {
 	apiRouter.HandleFunc("GET /category/{id}", categoryHandler.Get)
 	apiRouter.HandleFunc("POST /category", middleware.RequireLogin("admin", categoryHandler.Create))
 	apiRouter.HandleFunc("PUT /category", middleware.RequireLogin("admin", categoryHandler.Update))
+	apiRouter.HandleFunc("GET /category/tree", categoryHandler.Tree)
+	apiRouter.HandleFunc("GET /category/{id}/breadcrumb", categoryHandler.Breadcrumb)
+	apiRouter.HandleFunc("PUT /category/move", middleware.RequireLogin("admin", categoryHandler.Move))
+	apiRouter.HandleFunc("DELETE /category/{id}", middleware.RequireLogin("admin", categoryHandler.Delete))
+	apiRouter.HandleFunc("GET /category/deleted", middleware.RequireLogin("admin", categoryHandler.GetDeleted))
+	apiRouter.HandleFunc("POST /category/{id}/restore", middleware.RequireLogin("admin", categoryHandler.Restore))
+
+	// Attribute
+	apiRouter.HandleFunc("GET /category/{id}/attributes", attributeHandler.GetByCategory)
+	apiRouter.HandleFunc("POST /attribute", middleware.RequireLogin("admin", attributeHandler.Create))
+	apiRouter.HandleFunc("PUT /attribute", middleware.RequireLogin("admin", attributeHandler.Update))
+	apiRouter.HandleFunc("DELETE /attribute/{id}", middleware.RequireLogin("admin", attributeHandler.Delete))
 
 	// Product
 	apiRouter.HandleFunc("GET /product", producthandler.GetAll)
@@ -101,19 +301,123 @@ func main() {
 	apiRouter.HandleFunc("POST /product", middleware.RequireLogin("admin", producthandler.Create))
 	apiRouter.HandleFunc("PUT /product", middleware.RequireLogin("admin", producthandler.Update))
 	apiRouter.HandleFunc("DELETE /product/{id}", middleware.RequireLogin("admin", producthandler.Delete))
+	apiRouter.HandleFunc("GET /product/deleted", middleware.RequireLogin("admin", producthandler.GetDeleted))
+	apiRouter.HandleFunc("POST /product/{id}/restore", middleware.RequireLogin("admin", producthandler.Restore))
+	apiRouter.HandleFunc("GET /product/{id}/price", producthandler.GetPrices)
+	apiRouter.HandleFunc("PUT /product/price", middleware.RequireLogin("admin", producthandler.SavePrice))
+	apiRouter.HandleFunc("DELETE /product/{id}/price/{currency}", middleware.RequireLogin("admin", producthandler.DeletePrice))
+	apiRouter.HandleFunc("GET /product/{id}/price/history", producthandler.GetPriceHistory)
+	apiRouter.HandleFunc("GET /product/{id}/price/schedule", middleware.RequireLogin("admin", producthandler.GetScheduledPrices))
+	apiRouter.HandleFunc("POST /product/price/schedule", middleware.RequireLogin("admin", producthandler.SchedulePrice))
+	apiRouter.HandleFunc("DELETE /product/price/schedule/{id}", middleware.RequireLogin("admin", producthandler.CancelScheduledPrice))
+
+	// Currency
+	apiRouter.HandleFunc("GET /exchange-rate", currencyHandler.GetAll)
+	apiRouter.HandleFunc("PUT /exchange-rate", middleware.RequireLogin("admin", currencyHandler.Save))
+	apiRouter.HandleFunc("DELETE /exchange-rate/{currency}", middleware.RequireLogin("admin", currencyHandler.Delete))
 
 	// Review
 	apiRouter.HandleFunc("GET /review/{id}", reviewHandler.Get)
+	apiRouter.HandleFunc("GET /product/{id}/reviews", reviewHandler.GetByProduct)
 	apiRouter.HandleFunc("POST /review", middleware.RequireLogin("user", reviewHandler.Create))
 	apiRouter.HandleFunc("PUT /review", middleware.RequireLogin("user", reviewHandler.Update))
 	apiRouter.HandleFunc("DELETE /review/{id}", middleware.RequireLogin("user", reviewHandler.Delete))
+	apiRouter.HandleFunc("GET /review/deleted", middleware.RequireLogin("admin", reviewHandler.GetDeleted))
+	apiRouter.HandleFunc("POST /review/{id}/restore", middleware.RequireLogin("admin", reviewHandler.Restore))
+	apiRouter.HandleFunc("POST /review/{id}/vote", middleware.RequireLogin("user", reviewHandler.Vote))
+	apiRouter.HandleFunc("DELETE /review/{id}/vote", middleware.RequireLogin("user", reviewHandler.Unvote))
+	apiRouter.HandleFunc("POST /review/{id}/report", middleware.RequireLogin("user", reviewHandler.Report))
+	apiRouter.HandleFunc("POST /review/{id}/reply", middleware.RequireLogin("admin", reviewHandler.CreateReply))
+	apiRouter.HandleFunc("PUT /review/{id}/reply", middleware.RequireLogin("admin", reviewHandler.UpdateReply))
+	apiRouter.HandleFunc("DELETE /review/{id}/reply", middleware.RequireLogin("admin", reviewHandler.DeleteReply))
+	apiRouter.HandleFunc("GET /review/pending", middleware.RequireLogin("admin", reviewHandler.GetPending))
+	apiRouter.HandleFunc("POST /review/{id}/approve", middleware.RequireLogin("admin", reviewHandler.Approve))
+	apiRouter.HandleFunc("POST /review/{id}/reject", middleware.RequireLogin("admin", reviewHandler.Reject))
 
 	// Order
 	apiRouter.HandleFunc("GET /order/{id}", middleware.RequireLogin("user", orderHandler.Get))
-	apiRouter.HandleFunc("POST /order", middleware.RequireLogin("user", orderHandler.Create))
+	apiRouter.HandleFunc("POST /order", middleware.RequireLogin("user", middleware.Idempotent(*idempotencyService, orderHandler.Create)))
+	apiRouter.HandleFunc("POST /order/quote", middleware.RequireLogin("user", orderHandler.Quote))
+	apiRouter.HandleFunc("POST /order/shipping-rates", middleware.RequireLogin("user", orderHandler.ShippingRates))
+	apiRouter.HandleFunc("GET /order/{id}/tracking", middleware.RequireLogin("user", shipmentHandler.Tracking))
+	apiRouter.HandleFunc("POST /order/{id}/cancel", middleware.RequireLogin("user", orderHandler.Cancel))
+
+	// Address
+	apiRouter.HandleFunc("GET /me/addresses", middleware.RequireLogin("user", addressHandler.GetAll))
+	apiRouter.HandleFunc("GET /me/addresses/{id}", middleware.RequireLogin("user", addressHandler.Get))
+	apiRouter.HandleFunc("POST /me/addresses", middleware.RequireLogin("user", addressHandler.Create))
+	apiRouter.HandleFunc("PUT /me/addresses", middleware.RequireLogin("user", addressHandler.Update))
+	apiRouter.HandleFunc("DELETE /me/addresses/{id}", middleware.RequireLogin("user", addressHandler.Delete))
+
+	// Coupon
+	apiRouter.HandleFunc("GET /coupon", middleware.RequireLogin("admin", couponHandler.GetAll))
+	apiRouter.HandleFunc("GET /coupon/{id}", middleware.RequireLogin("admin", couponHandler.Get))
+	apiRouter.HandleFunc("POST /coupon", middleware.RequireLogin("admin", couponHandler.Create))
+	apiRouter.HandleFunc("PUT /coupon", middleware.RequireLogin("admin", couponHandler.Update))
+	apiRouter.HandleFunc("DELETE /coupon/{id}", middleware.RequireLogin("admin", couponHandler.Delete))
+
+	// Promotion
+	apiRouter.HandleFunc("GET /promotion", middleware.RequireLogin("admin", promotionHandler.GetAll))
+	apiRouter.HandleFunc("GET /promotion/{id}", middleware.RequireLogin("admin", promotionHandler.Get))
+	apiRouter.HandleFunc("POST /promotion", middleware.RequireLogin("admin", promotionHandler.Create))
+	apiRouter.HandleFunc("PUT /promotion", middleware.RequireLogin("admin", promotionHandler.Update))
+	apiRouter.HandleFunc("DELETE /promotion/{id}", middleware.RequireLogin("admin", promotionHandler.Delete))
+
+	// Tax
+	apiRouter.HandleFunc("GET /tax-rate", middleware.RequireLogin("admin", taxHandler.GetAll))
+	apiRouter.HandleFunc("POST /tax-rate", middleware.RequireLogin("admin", taxHandler.Create))
+	apiRouter.HandleFunc("PUT /tax-rate", middleware.RequireLogin("admin", taxHandler.Update))
+	apiRouter.HandleFunc("DELETE /tax-rate/{id}", middleware.RequireLogin("admin", taxHandler.Delete))
+
+	// Shipping
+	apiRouter.HandleFunc("GET /shipping-zone", middleware.RequireLogin("admin", shippingHandler.GetZones))
+	apiRouter.HandleFunc("GET /shipping-zone/{id}", middleware.RequireLogin("admin", shippingHandler.GetZone))
+	apiRouter.HandleFunc("POST /shipping-zone", middleware.RequireLogin("admin", shippingHandler.CreateZone))
+	apiRouter.HandleFunc("PUT /shipping-zone", middleware.RequireLogin("admin", shippingHandler.UpdateZone))
+	apiRouter.HandleFunc("DELETE /shipping-zone/{id}", middleware.RequireLogin("admin", shippingHandler.DeleteZone))
+	apiRouter.HandleFunc("POST /shipping-method", middleware.RequireLogin("admin", shippingHandler.CreateMethod))
+	apiRouter.HandleFunc("PUT /shipping-method", middleware.RequireLogin("admin", shippingHandler.UpdateMethod))
+	apiRouter.HandleFunc("DELETE /shipping-method/{id}", middleware.RequireLogin("admin", shippingHandler.DeleteMethod))
+
+	// Shipment
+	apiRouter.HandleFunc("GET /shipment/{id}", middleware.RequireLogin("admin", shipmentHandler.Get))
+	apiRouter.HandleFunc("POST /shipment", middleware.RequireLogin("admin", shipmentHandler.Create))
+	apiRouter.HandleFunc("PUT /shipment", middleware.RequireLogin("admin", shipmentHandler.Update))
+
+	// Return
+	apiRouter.HandleFunc("GET /me/returns", middleware.RequireLogin("user", returnHandler.GetMine))
+	apiRouter.HandleFunc("GET /me/returns/{id}", middleware.RequireLogin("user", returnHandler.GetOwn))
+	apiRouter.HandleFunc("POST /me/returns", middleware.RequireLogin("user", returnHandler.Create))
+	apiRouter.HandleFunc("GET /return", middleware.RequireLogin("admin", returnHandler.GetAll))
+	apiRouter.HandleFunc("GET /return/{id}", middleware.RequireLogin("admin", returnHandler.Get))
+	apiRouter.HandleFunc("POST /return/{id}/approve", middleware.RequireLogin("admin", returnHandler.Approve))
+	apiRouter.HandleFunc("POST /return/{id}/reject", middleware.RequireLogin("admin", returnHandler.Reject))
+	apiRouter.HandleFunc("POST /return/{id}/receive", middleware.RequireLogin("admin", returnHandler.Receive))
+	apiRouter.HandleFunc("POST /return/{id}/inspect", middleware.RequireLogin("admin", returnHandler.Inspect))
+	apiRouter.HandleFunc("POST /return/{id}/refund", middleware.RequireLogin("admin", returnHandler.Refund))
+
+	// Import
+	apiRouter.HandleFunc("POST /import/product", middleware.RequireLogin("admin", importHandler.ImportProducts))
+	apiRouter.HandleFunc("GET /import/{id}", middleware.RequireLogin("admin", importHandler.Get))
+
+	// Feed
+	apiRouter.HandleFunc("GET /feed/{file}", feedHandler.Feed)
+	apiRouter.HandleFunc("POST /feed/generate", middleware.RequireLogin("admin", feedHandler.Generate))
+	apiRouter.HandleFunc("GET /export/product", middleware.RequireLogin("admin", feedHandler.Export))
+
+	// Stock
+	apiRouter.HandleFunc("GET /product/{id}/stock", middleware.RequireLogin("admin", stockHandler.Report))
+	apiRouter.HandleFunc("POST /stock/adjustment", middleware.RequireLogin("admin", stockHandler.Adjust))
+	apiRouter.HandleFunc("GET /stock/discrepancies", middleware.RequireLogin("admin", stockHandler.GetDiscrepancies))
+	apiRouter.HandleFunc("POST /stock/reconcile", middleware.RequireLogin("admin", stockHandler.Reconcile))
+
+	// Notification
+	apiRouter.HandleFunc("GET /me/notifications", middleware.RequireLogin("user", notificationHandler.GetAll))
+	apiRouter.HandleFunc("POST /me/notifications/read", middleware.RequireLogin("user", notificationHandler.MarkAllRead))
+	apiRouter.HandleFunc("POST /me/notifications/{id}/read", middleware.RequireLogin("user", notificationHandler.MarkRead))
 
 	// Payment
-	apiRouter.HandleFunc("POST /payment/{id}", middleware.RequireLogin("user", paymentHandler.Create))
+	apiRouter.HandleFunc("POST /payment/{id}", middleware.RequireLogin("user", middleware.Idempotent(*idempotencyService, paymentHandler.Create)))
 
 	// Auth
 	apiRouter.HandleFunc("POST /login", authHandler.Login)
@@ -0,0 +1,76 @@
+// Command import_products imports products from a CSV or JSON file, the same
+// way as POST /import/product, and prints the rows that failed.
+//
+//	go run ./cmd/import_products -file products.csv
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/fatihesergg/go_ecommerce/internal/model"
+	"github.com/fatihesergg/go_ecommerce/internal/service"
+	"github.com/fatihesergg/go_ecommerce/internal/storage"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+func main() {
+	dsn := flag.String("dsn", "postgresql://localhost/go_ecommerce?user=fatih&password=test", "Database connection string")
+	path := flag.String("file", "", "CSV or JSON file to import")
+	format := flag.String("format", "", "File format, csv or json. Taken from the file extension when not given")
+	flag.Parse()
+	if *path == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+	if *format == "" {
+		*format = strings.TrimPrefix(filepath.Ext(*path), ".")
+	}
+
+	db, err := gorm.Open(postgres.Open(*dsn), &gorm.Config{TranslateError: true})
+	if err != nil {
+		panic(err)
+	}
+	db.AutoMigrate(&model.ImportJob{})
+	db.AutoMigrate(&model.PriceHistory{})
+	db.AutoMigrate(&model.StockMovement{})
+
+	file, err := os.Open(*path)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	defer file.Close()
+	rows, err := service.ParseImport(model.ImportFormat(strings.ToLower(*format)), file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+
+	categoryRepo := storage.NewCategoryRepository(db)
+	productRepo := storage.NewProductRepository(db)
+	productService := service.NewProductService(*productRepo, *storage.NewSlugRedirectRepository(db), *storage.NewAttributeRepository(db), *categoryRepo)
+	importService := service.NewImportService(*storage.NewImportJobRepository(db), *productService, *categoryRepo, validator.New(validator.WithRequiredStructEnabled()))
+
+	job := model.ImportJob{FileName: filepath.Base(*path), Format: model.ImportFormat(strings.ToLower(*format))}
+	job, err = importService.Create(job, rows)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	job = importService.Run(job, rows)
+
+	for _, rowError := range job.Errors {
+		fmt.Printf("row %d (%s): %s\n", rowError.Row, rowError.SKU, rowError.Message)
+	}
+	fmt.Printf("import %d %s: %d created, %d updated, %d failed of %d rows\n", job.ID, job.Status, job.Created, job.Updated, job.Failed, job.Total)
+	if job.Status == model.IMPORT_FAILED {
+		fmt.Fprintln(os.Stderr, job.Message)
+		os.Exit(1)
+	}
+}
@@ -0,0 +1,28 @@
+package dto
+
+type AddressCreateDto struct {
+	FullName          string `json:"full_name" validate:"required"`
+	Line1             string `json:"line1" validate:"required"`
+	Line2             string `json:"line2"`
+	City              string `json:"city" validate:"required"`
+	Region            string `json:"region"`
+	PostalCode        string `json:"postal_code"`
+	Country           string `json:"country" validate:"required,len=2"`
+	Phone             string `json:"phone"`
+	IsDefaultShipping bool   `json:"is_default_shipping"`
+	IsDefaultBilling  bool   `json:"is_default_billing"`
+}
+
+type AddressUpdateDto struct {
+	ID                int    `json:"id" validate:"required"`
+	FullName          string `json:"full_name" validate:"required"`
+	Line1             string `json:"line1" validate:"required"`
+	Line2             string `json:"line2"`
+	City              string `json:"city" validate:"required"`
+	Region            string `json:"region"`
+	PostalCode        string `json:"postal_code"`
+	Country           string `json:"country" validate:"required,len=2"`
+	Phone             string `json:"phone"`
+	IsDefaultShipping bool   `json:"is_default_shipping"`
+	IsDefaultBilling  bool   `json:"is_default_billing"`
+}
@@ -0,0 +1,18 @@
+package dto
+
+type AttributeCreateDto struct {
+	CategoryID uint     `json:"category_id" validate:"required"`
+	Name       string   `json:"name" validate:"required"`
+	Type       string   `json:"type" validate:"required,oneof=text number enum boolean"`
+	Unit       string   `json:"unit"`
+	Options    []string `json:"options"`
+	Required   bool     `json:"required"`
+}
+
+type AttributeUpdateDto struct {
+	ID       uint     `json:"id" validate:"required"`
+	Name     string   `json:"name" validate:"required"`
+	Unit     string   `json:"unit"`
+	Options  []string `json:"options"`
+	Required bool     `json:"required"`
+}
@@ -1,10 +1,18 @@
 package dto
 
 type CategoryCreateDto struct {
-	Name string `json:"name" validate:"required"`
+	Name     string `json:"name" validate:"required"`
+	Slug     string `json:"slug" validate:"max=200"`
+	ParentID *uint  `json:"parent_id"`
 }
 
 type CategoryUpdateDto struct {
 	ID   int    `json:"id" validate:"required"`
 	Name string `json:"name" validate:"required"`
+	Slug string `json:"slug" validate:"max=200"`
+}
+
+type CategoryMoveDto struct {
+	ID       uint  `json:"id" validate:"required"`
+	ParentID *uint `json:"parent_id"`
 }
@@ -0,0 +1,32 @@
+package dto
+
+import "time"
+
+type CouponCreateDto struct {
+	Code           string     `json:"code" validate:"required"`
+	Type           string     `json:"type" validate:"required,oneof=percentage fixed"`
+	Value          float64    `json:"value" validate:"required,gt=0"`
+	MinOrderAmount float64    `json:"min_order_amount" validate:"gte=0"`
+	UsageLimit     uint       `json:"usage_limit"`
+	PerUserLimit   uint       `json:"per_user_limit"`
+	StartsAt       *time.Time `json:"starts_at"`
+	EndsAt         *time.Time `json:"ends_at"`
+	Active         bool       `json:"active"`
+	ProductIDs     []uint     `json:"product_ids"`
+	CategoryIDs    []uint     `json:"category_ids"`
+}
+
+type CouponUpdateDto struct {
+	ID             int        `json:"id" validate:"required"`
+	Code           string     `json:"code" validate:"required"`
+	Type           string     `json:"type" validate:"required,oneof=percentage fixed"`
+	Value          float64    `json:"value" validate:"required,gt=0"`
+	MinOrderAmount float64    `json:"min_order_amount" validate:"gte=0"`
+	UsageLimit     uint       `json:"usage_limit"`
+	PerUserLimit   uint       `json:"per_user_limit"`
+	StartsAt       *time.Time `json:"starts_at"`
+	EndsAt         *time.Time `json:"ends_at"`
+	Active         bool       `json:"active"`
+	ProductIDs     []uint     `json:"product_ids"`
+	CategoryIDs    []uint     `json:"category_ids"`
+}
@@ -0,0 +1,12 @@
+package dto
+
+type ExchangeRateDto struct {
+	Currency string  `json:"currency" validate:"required,len=3"`
+	Rate     float64 `json:"rate" validate:"required,gt=0"`
+}
+
+type ProductPriceDto struct {
+	ProductID uint    `json:"product_id" validate:"required"`
+	Currency  string  `json:"currency" validate:"required,len=3"`
+	Price     float64 `json:"price" validate:"required,gt=0"`
+}
@@ -0,0 +1,54 @@
+package dto
+
+import "encoding/xml"
+
+// ProductExportDto is a product in a catalog export. Price is the regular
+// price and SalePrice the scheduled price the product is on, if any. Stock is
+// only exported as its availability. CategoryPath is the category with the
+// ones above it, from the root down, joined by " > ".
+type ProductExportDto struct {
+	ID           uint     `json:"id"`
+	SKU          string   `json:"sku"`
+	Name         string   `json:"name"`
+	Slug         string   `json:"slug"`
+	Description  string   `json:"description"`
+	URL          string   `json:"url"`
+	ImageURL     string   `json:"image_url"`
+	Price        float64  `json:"price"`
+	SalePrice    *float64 `json:"sale_price"`
+	Currency     string   `json:"currency"`
+	Availability string   `json:"availability"`
+	CategoryID   uint     `json:"category_id"`
+	CategoryPath string   `json:"category_path"`
+}
+
+// MerchantFeedDto is an RSS 2.0 product feed in the merchant feed format
+// read by shopping and comparison sites.
+type MerchantFeedDto struct {
+	XMLName xml.Name               `xml:"rss"`
+	Version string                 `xml:"version,attr"`
+	G       string                 `xml:"xmlns:g,attr"`
+	Channel MerchantFeedChannelDto `xml:"channel"`
+}
+
+type MerchantFeedChannelDto struct {
+	Title       string                `xml:"title"`
+	Link        string                `xml:"link"`
+	Description string                `xml:"description"`
+	Items       []MerchantFeedItemDto `xml:"item"`
+}
+
+type MerchantFeedItemDto struct {
+	ID               string `xml:"g:id"`
+	Title            string `xml:"g:title"`
+	Description      string `xml:"g:description"`
+	Link             string `xml:"g:link"`
+	ImageLink        string `xml:"g:image_link,omitempty"`
+	Availability     string `xml:"g:availability"`
+	Price            string `xml:"g:price"`
+	SalePrice        string `xml:"g:sale_price,omitempty"`
+	ProductType      string `xml:"g:product_type,omitempty"`
+	MPN              string `xml:"g:mpn,omitempty"`
+	IdentifierExists string `xml:"g:identifier_exists,omitempty"`
+	Condition        string `xml:"g:condition"`
+}
@@ -0,0 +1,8 @@
+package dto
+
+// ProductImportDto is a row of a product import. Category is the ID or the
+// name of the category, used when category_id isn't given.
+type ProductImportDto struct {
+	ProductCreateDto
+	Category string `json:"category"`
+}
@@ -0,0 +1,12 @@
+package dto
+
+import "github.com/fatihesergg/go_ecommerce/internal/model"
+
+type NotificationListDto struct {
+	Unread        int64                `json:"unread"`
+	Notifications []model.Notification `json:"notifications"`
+	Page          int                  `json:"page"`
+	PageSize      int                  `json:"page_size"`
+	Total         int64                `json:"total"`
+	TotalPages    int                  `json:"total_pages"`
+}
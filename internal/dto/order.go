@@ -18,7 +18,14 @@ type OrderItemDto struct {
 	Quantity  uint `json:"quantity"  validate:"required"`
 }
 type CreateOrderDto struct {
-	Products []OrderItemDto `json:"products" validate:"required" `
+	Products          []OrderItemDto `json:"products" validate:"required" `
+	Currency          string         `json:"currency" validate:"omitempty,len=3"`
+	CouponCode        string         `json:"coupon_code"`
+	Country           string         `json:"country" validate:"omitempty,len=2"`
+	Region            string         `json:"region"`
+	ShippingAddressID *uint          `json:"shipping_address_id"`
+	BillingAddressID  *uint          `json:"billing_address_id"`
+	ShippingMethodID  *uint          `json:"shipping_method_id"`
 }
 
 type UpdateOrderDto struct {
@@ -0,0 +1,12 @@
+package dto
+
+import "time"
+
+// ScheduledPriceCreateDto is a price a product goes on at StartsAt. Without
+// EndsAt the price stays until it's changed.
+type ScheduledPriceCreateDto struct {
+	ProductID uint       `json:"product_id" validate:"required"`
+	Price     float64    `json:"price" validate:"required,gt=0"`
+	StartsAt  time.Time  `json:"starts_at" validate:"required"`
+	EndsAt    *time.Time `json:"ends_at" validate:"omitempty,gtfield=StartsAt"`
+}
@@ -1,18 +1,41 @@
 package dto
 
+type ProductAttributeDto struct {
+	AttributeID uint   `json:"attribute_id" validate:"required"`
+	Value       string `json:"value"`
+}
+
 type ProductCreateDto struct {
-	Name       string  `json:"name" validate:"required"`
-	ImageURL   *string `json:"image_url" `
-	Price      float64 `json:"price" validate:"required"`
-	Stock      uint    `json:"stock" validate:"required"`
-	CategoryID uint    `json:"category_id" validate:"required"`
+	Name        string                `json:"name" validate:"required"`
+	Slug        string                `json:"slug" validate:"max=200"`
+	SKU         *string               `json:"sku" validate:"omitempty,max=64"`
+	Description string                `json:"description"`
+	ImageURL    *string               `json:"image_url" `
+	Price       float64               `json:"price" validate:"required"`
+	Stock       uint                  `json:"stock" validate:"required"`
+	CategoryID  uint                  `json:"category_id" validate:"required"`
+	TaxCategory string                `json:"tax_category"`
+	Weight      float64               `json:"weight" validate:"gte=0"`
+	Length      float64               `json:"length" validate:"gte=0"`
+	Width       float64               `json:"width" validate:"gte=0"`
+	Height      float64               `json:"height" validate:"gte=0"`
+	Attributes  []ProductAttributeDto `json:"attributes" validate:"dive"`
 }
 
+// ProductUpdateDto has no stock, which only changes through stock movements.
 type ProductUpdateDto struct {
-	ID         int     `json:"id" validate:"required"`
-	Name       string  `json:"name" validate:"required"`
-	ImageURL   *string `json:"image_url"`
-	Price      float64 `json:"price" validate:"required"`
-	Stock      uint    `json:"stock" validate:"required"`
-	CategoryID uint    `json:"category_id" validate:"required"`
+	ID          int                   `json:"id" validate:"required"`
+	Name        string                `json:"name" validate:"required"`
+	Slug        string                `json:"slug" validate:"max=200"`
+	SKU         *string               `json:"sku" validate:"omitempty,max=64"`
+	Description string                `json:"description"`
+	ImageURL    *string               `json:"image_url"`
+	Price       float64               `json:"price" validate:"required"`
+	CategoryID  uint                  `json:"category_id" validate:"required"`
+	TaxCategory string                `json:"tax_category"`
+	Weight      float64               `json:"weight" validate:"gte=0"`
+	Length      float64               `json:"length" validate:"gte=0"`
+	Width       float64               `json:"width" validate:"gte=0"`
+	Height      float64               `json:"height" validate:"gte=0"`
+	Attributes  []ProductAttributeDto `json:"attributes" validate:"dive"`
 }
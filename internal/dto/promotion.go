@@ -0,0 +1,45 @@
+package dto
+
+import "time"
+
+type PromotionTierDto struct {
+	MinAmount     float64 `json:"min_amount" validate:"gte=0"`
+	DiscountValue float64 `json:"discount_value" validate:"required,gt=0"`
+}
+
+type PromotionCreateDto struct {
+	Name          string             `json:"name" validate:"required"`
+	Description   string             `json:"description"`
+	Type          string             `json:"type" validate:"required,oneof=buy_x_get_y threshold category_sale bundle"`
+	Priority      int                `json:"priority"`
+	Exclusive     bool               `json:"exclusive"`
+	Active        bool               `json:"active"`
+	StartsAt      *time.Time         `json:"starts_at"`
+	EndsAt        *time.Time         `json:"ends_at"`
+	BuyQuantity   uint               `json:"buy_quantity"`
+	GetQuantity   uint               `json:"get_quantity"`
+	DiscountType  string             `json:"discount_type" validate:"omitempty,oneof=percentage fixed"`
+	DiscountValue float64            `json:"discount_value" validate:"gte=0"`
+	CategoryID    *uint              `json:"category_id"`
+	ProductIDs    []uint             `json:"product_ids"`
+	Tiers         []PromotionTierDto `json:"tiers" validate:"dive"`
+}
+
+type PromotionUpdateDto struct {
+	ID            int                `json:"id" validate:"required"`
+	Name          string             `json:"name" validate:"required"`
+	Description   string             `json:"description"`
+	Type          string             `json:"type" validate:"required,oneof=buy_x_get_y threshold category_sale bundle"`
+	Priority      int                `json:"priority"`
+	Exclusive     bool               `json:"exclusive"`
+	Active        bool               `json:"active"`
+	StartsAt      *time.Time         `json:"starts_at"`
+	EndsAt        *time.Time         `json:"ends_at"`
+	BuyQuantity   uint               `json:"buy_quantity"`
+	GetQuantity   uint               `json:"get_quantity"`
+	DiscountType  string             `json:"discount_type" validate:"omitempty,oneof=percentage fixed"`
+	DiscountValue float64            `json:"discount_value" validate:"gte=0"`
+	CategoryID    *uint              `json:"category_id"`
+	ProductIDs    []uint             `json:"product_ids"`
+	Tiers         []PromotionTierDto `json:"tiers" validate:"dive"`
+}
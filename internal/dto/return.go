@@ -0,0 +1,28 @@
+package dto
+
+type ReturnItemDto struct {
+	OrderItemID uint   `json:"order_item_id" validate:"required"`
+	Quantity    int    `json:"quantity" validate:"required,gte=1"`
+	Reason      string `json:"reason" validate:"required,oneof=damaged wrong_item not_as_described no_longer_needed other"`
+}
+
+type ReturnCreateDto struct {
+	OrderID uint            `json:"order_id" validate:"required"`
+	Note    string          `json:"note"`
+	Items   []ReturnItemDto `json:"items" validate:"required,min=1,dive"`
+}
+
+type ReturnActionDto struct {
+	Note string `json:"note"`
+}
+
+type ReturnInspectItemDto struct {
+	ID       uint `json:"id" validate:"required"`
+	Accepted bool `json:"accepted"`
+	Restock  bool `json:"restock"`
+}
+
+type ReturnInspectDto struct {
+	Note  string                 `json:"note"`
+	Items []ReturnInspectItemDto `json:"items" validate:"required,min=1,dive"`
+}
@@ -1,12 +1,61 @@
 package dto
 
+import "time"
+
 type ReviewCreateDto struct {
 	Comment   string `json:"comment" validate:"required"`
+	Rating    int    `json:"rating" validate:"required,gte=1,lte=5"`
 	ProductID int    `json:"product_id" validate:"required"`
 }
 
 type ReviewUpdateDto struct {
-	ID        int    `json:"id" validate:"required"`
-	Comment   string `json:"comment" validate:"required"`
-	ProductID int    `json:"product_id" validate:"required"`
+	ID      int    `json:"id" validate:"required"`
+	Comment string `json:"comment" validate:"required"`
+	Rating  int    `json:"rating" validate:"required,gte=1,lte=5"`
+}
+
+type ReviewModerationDto struct {
+	Reason string `json:"reason" validate:"max=500"`
+}
+
+type ReviewVoteDto struct {
+	Helpful *bool `json:"helpful" validate:"required"`
+}
+
+type ReviewReportDto struct {
+	Reason string `json:"reason" validate:"required,oneof=spam offensive off_topic fake other"`
+	Note   string `json:"note" validate:"max=500"`
+}
+
+type ReviewReplyDto struct {
+	Body string `json:"body" validate:"required,max=2000"`
+}
+
+type ReviewReplyResponseDto struct {
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type ReviewResponseDto struct {
+	ID               uint                    `json:"id"`
+	Comment          string                  `json:"comment"`
+	Rating           int                     `json:"rating"`
+	HelpfulCount     int                     `json:"helpful_count"`
+	UnhelpfulCount   int                     `json:"unhelpful_count"`
+	VerifiedPurchase bool                    `json:"verified_purchase"`
+	ProductID        uint                    `json:"product_id"`
+	UserID           uint                    `json:"user_id"`
+	ReviewerName     string                  `json:"reviewer_name"`
+	Reply            *ReviewReplyResponseDto `json:"reply"`
+	CreatedAt        time.Time               `json:"created_at"`
+	UpdatedAt        time.Time               `json:"updated_at"`
+}
+
+type ReviewPageDto struct {
+	Reviews    []ReviewResponseDto `json:"reviews"`
+	Page       int                 `json:"page"`
+	PageSize   int                 `json:"page_size"`
+	Total      int64               `json:"total"`
+	TotalPages int                 `json:"total_pages"`
 }
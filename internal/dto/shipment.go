@@ -0,0 +1,31 @@
+package dto
+
+import "github.com/fatihesergg/go_ecommerce/internal/model"
+
+type ShipmentItemDto struct {
+	OrderItemID uint `json:"order_item_id" validate:"required"`
+	Quantity    int  `json:"quantity" validate:"required,gte=1"`
+}
+
+type ShipmentCreateDto struct {
+	OrderID        uint              `json:"order_id" validate:"required"`
+	Carrier        string            `json:"carrier" validate:"required"`
+	TrackingNumber string            `json:"tracking_number"`
+	TrackingURL    string            `json:"tracking_url" validate:"omitempty,url"`
+	Items          []ShipmentItemDto `json:"items" validate:"required,min=1,dive"`
+}
+
+type ShipmentUpdateDto struct {
+	ID             int    `json:"id" validate:"required"`
+	Carrier        string `json:"carrier" validate:"required"`
+	TrackingNumber string `json:"tracking_number"`
+	TrackingURL    string `json:"tracking_url" validate:"omitempty,url"`
+	Status         string `json:"status" validate:"required,oneof=shipped in_transit delivered"`
+}
+
+type OrderTrackingDto struct {
+	OrderID         uint                  `json:"order_id"`
+	Status          model.OrderStatus     `json:"status"`
+	ShippingAddress model.AddressSnapshot `json:"shipping_address"`
+	Shipments       []model.Shipment      `json:"shipments"`
+}
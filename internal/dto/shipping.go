@@ -0,0 +1,33 @@
+package dto
+
+type ShippingZoneCreateDto struct {
+	Name      string   `json:"name" validate:"required"`
+	Countries []string `json:"countries" validate:"required,min=1,dive,len=2"`
+}
+
+type ShippingZoneUpdateDto struct {
+	ID        int      `json:"id" validate:"required"`
+	Name      string   `json:"name" validate:"required"`
+	Countries []string `json:"countries" validate:"required,min=1,dive,len=2"`
+}
+
+type ShippingMethodCreateDto struct {
+	ZoneID    uint    `json:"zone_id" validate:"required"`
+	Name      string  `json:"name" validate:"required"`
+	Type      string  `json:"type" validate:"required,oneof=flat weight free_over"`
+	BaseRate  float64 `json:"base_rate" validate:"gte=0"`
+	PerKgRate float64 `json:"per_kg_rate" validate:"gte=0"`
+	FreeOver  float64 `json:"free_over" validate:"gte=0"`
+	Active    bool    `json:"active"`
+}
+
+type ShippingMethodUpdateDto struct {
+	ID        int     `json:"id" validate:"required"`
+	ZoneID    uint    `json:"zone_id" validate:"required"`
+	Name      string  `json:"name" validate:"required"`
+	Type      string  `json:"type" validate:"required,oneof=flat weight free_over"`
+	BaseRate  float64 `json:"base_rate" validate:"gte=0"`
+	PerKgRate float64 `json:"per_kg_rate" validate:"gte=0"`
+	FreeOver  float64 `json:"free_over" validate:"gte=0"`
+	Active    bool    `json:"active"`
+}
@@ -0,0 +1,29 @@
+package dto
+
+import "github.com/fatihesergg/go_ecommerce/internal/model"
+
+// StockAdjustmentDto changes the stock of a product either by Quantity, which
+// is negative to take stock out, or to Stock after a count.
+type StockAdjustmentDto struct {
+	ProductID uint   `json:"product_id" validate:"required"`
+	Quantity  *int   `json:"quantity" validate:"required_without=Stock,excluded_with=Stock"`
+	Stock     *uint  `json:"stock" validate:"required_without=Quantity"`
+	Note      string `json:"note" validate:"required,max=255"`
+}
+
+// StockReportDto is the stock history of a product over a period. Stock is
+// the stock of the product and LedgerStock the sum of all its movements,
+// which match unless Stock was changed outside the ledger. In and Out are the
+// stock that came in and went out in the period, and Reasons the change of
+// the period by reason.
+type StockReportDto struct {
+	ProductID   uint                      `json:"product_id"`
+	Name        string                    `json:"name"`
+	SKU         *string                   `json:"sku"`
+	Stock       uint                      `json:"stock"`
+	LedgerStock int                       `json:"ledger_stock"`
+	In          int                       `json:"in"`
+	Out         int                       `json:"out"`
+	Reasons     map[model.StockReason]int `json:"reasons"`
+	Movements   []model.StockMovement     `json:"movements"`
+}
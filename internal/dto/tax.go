@@ -0,0 +1,20 @@
+package dto
+
+type TaxRateCreateDto struct {
+	Name        string  `json:"name" validate:"required"`
+	Country     string  `json:"country" validate:"required,len=2"`
+	Region      string  `json:"region"`
+	TaxCategory string  `json:"tax_category"`
+	Rate        float64 `json:"rate" validate:"gte=0,lte=100"`
+	Inclusive   bool    `json:"inclusive"`
+}
+
+type TaxRateUpdateDto struct {
+	ID          int     `json:"id" validate:"required"`
+	Name        string  `json:"name" validate:"required"`
+	Country     string  `json:"country" validate:"required,len=2"`
+	Region      string  `json:"region"`
+	TaxCategory string  `json:"tax_category"`
+	Rate        float64 `json:"rate" validate:"gte=0,lte=100"`
+	Inclusive   bool    `json:"inclusive"`
+}
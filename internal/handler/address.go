@@ -0,0 +1,271 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/fatihesergg/go_ecommerce/internal/dto"
+	"github.com/fatihesergg/go_ecommerce/internal/middleware"
+	"github.com/fatihesergg/go_ecommerce/internal/model"
+	"github.com/fatihesergg/go_ecommerce/internal/service"
+	"github.com/fatihesergg/go_ecommerce/internal/util"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+type AddressHandler struct {
+	AddressService service.AddressService
+	Validator      *validator.Validate
+}
+
+func NewAddressHandler(service service.AddressService, validator *validator.Validate) AddressHandler {
+	return AddressHandler{AddressService: service, Validator: validator}
+}
+
+// GetAll godoc
+//
+//	@Tags			address
+//	@Summary		Show my addresses
+//	@Description	get the address book of the logged in user
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	util.ApiResponse{data=[]model.Address}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/me/addresses [get]
+func (h *AddressHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.AuthUserID).(string)
+	userIDint, _ := strconv.Atoi(userID)
+	var response util.ApiResponse
+	addresses, err := h.AddressService.GetByUser(uint(userIDint))
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting addresses"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = addresses
+	util.WriteJson(w, response)
+}
+
+// Get godoc
+//
+//	@Tags			address
+//	@Summary		Show an address
+//	@Description	get address by ID
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Address ID"
+//	@Success		200	{object}	util.ApiResponse{data=model.Address}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/me/addresses/{id} [get]
+func (h *AddressHandler) Get(w http.ResponseWriter, r *http.Request) {
+	address, ok := h.ownAddress(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	var response util.ApiResponse
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = address
+	util.WriteJson(w, response)
+}
+
+// Create godoc
+//
+//	@Tags			address
+//	@Summary		Create an address
+//	@Description	Add an address to the address book of the logged in user
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			address	body		dto.AddressCreateDto	true	"Create Address"
+//	@Success		200		{object}	util.ApiResponse{data=model.Address}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/me/addresses [post]
+func (h *AddressHandler) Create(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.AddressCreateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	userID := r.Context().Value(middleware.AuthUserID).(string)
+	userIDint, _ := strconv.Atoi(userID)
+	address := model.Address{
+		UserID:            uint(userIDint),
+		FullName:          data.FullName,
+		Line1:             data.Line1,
+		Line2:             data.Line2,
+		City:              data.City,
+		Region:            data.Region,
+		PostalCode:        data.PostalCode,
+		Country:           data.Country,
+		Phone:             data.Phone,
+		IsDefaultShipping: data.IsDefaultShipping,
+		IsDefaultBilling:  data.IsDefaultBilling,
+	}
+	address, err = h.AddressService.Save(address)
+	if err != nil {
+		if errors.Is(err, util.InvalidAddressError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while creating address"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusCreated
+	response.Message = "Address created successfully."
+	response.Data = address
+	util.WriteJson(w, response)
+}
+
+// Update godoc
+//
+//	@Tags			address
+//	@Summary		Update an address
+//	@Description	Update an address of the logged in user
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			address	body		dto.AddressUpdateDto	true	"Update Address"
+//	@Success		200		{object}	util.ApiResponse{data=model.Address}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/me/addresses [put]
+func (h *AddressHandler) Update(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.AddressUpdateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	address, ok := h.ownAddress(w, r, strconv.Itoa(data.ID))
+	if !ok {
+		return
+	}
+	address.FullName = data.FullName
+	address.Line1 = data.Line1
+	address.Line2 = data.Line2
+	address.City = data.City
+	address.Region = data.Region
+	address.PostalCode = data.PostalCode
+	address.Country = data.Country
+	address.Phone = data.Phone
+	address.IsDefaultShipping = data.IsDefaultShipping
+	address.IsDefaultBilling = data.IsDefaultBilling
+
+	address, err = h.AddressService.Save(address)
+	if err != nil {
+		if errors.Is(err, util.InvalidAddressError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while updating address"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = address
+	util.WriteJson(w, response)
+}
+
+// Delete godoc
+//
+//	@Tags			address
+//	@Summary		Delete an address
+//	@Description	Delete an address of the logged in user
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Address ID"
+//	@Success		200	{object}	util.ApiResponse{}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/me/addresses/{id} [delete]
+func (h *AddressHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	address, ok := h.ownAddress(w, r, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	var response util.ApiResponse
+	err := h.AddressService.Delete(strconv.Itoa(int(address.ID)))
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while deleting address"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// ownAddress loads an address of the logged in user. It writes the error
+// response and returns false when the address can't be used.
+func (h *AddressHandler) ownAddress(w http.ResponseWriter, r *http.Request, id string) (model.Address, bool) {
+	var response util.ApiResponse
+	if _, err := strconv.Atoi(id); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid address id"
+		util.WriteJson(w, response)
+		return model.Address{}, false
+	}
+	address, err := h.AddressService.Get(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Address not found"
+			util.WriteJson(w, response)
+			return model.Address{}, false
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting address"
+		util.WriteJson(w, response)
+		return model.Address{}, false
+	}
+	userID := r.Context().Value(middleware.AuthUserID).(string)
+	if userID != strconv.Itoa(int(address.UserID)) {
+		response.Status = http.StatusBadRequest
+		response.Message = "Address not found"
+		util.WriteJson(w, response)
+		return model.Address{}, false
+	}
+	return address, true
+}
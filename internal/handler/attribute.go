@@ -0,0 +1,231 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/fatihesergg/go_ecommerce/internal/dto"
+	"github.com/fatihesergg/go_ecommerce/internal/model"
+	"github.com/fatihesergg/go_ecommerce/internal/service"
+	"github.com/fatihesergg/go_ecommerce/internal/util"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+type AttributeHandler struct {
+	AttributeService service.AttributeService
+	Validator        *validator.Validate
+}
+
+func NewAttributeHandler(service service.AttributeService, validator *validator.Validate) AttributeHandler {
+	return AttributeHandler{AttributeService: service, Validator: validator}
+}
+
+// GetByCategory godoc
+//
+//	@Tags			attribute
+//	@Summary		Show the attributes of a category
+//	@Description	get the attributes of the products of a category, including the ones of the categories above it
+//	@Produce		json
+//	@Param			id	path		int	true	"Category ID"
+//	@Success		200	{object}	util.ApiResponse{data=[]model.AttributeDefinition}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/category/{id}/attributes [get]
+func (h *AttributeHandler) GetByCategory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid category id"
+		util.WriteJson(w, response)
+		return
+	}
+	attributes, err := h.AttributeService.GetByCategory(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Category not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting attributes"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = attributes
+	util.WriteJson(w, response)
+}
+
+// Create godoc
+//
+//	@Tags			attribute
+//	@Summary		Create an attribute
+//	@Description	Define an attribute for the products of a category and of the categories below it
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			attribute	body		dto.AttributeCreateDto	true	"Create Attribute"
+//	@Success		200			{object}	util.ApiResponse{data=model.AttributeDefinition}
+//	@Failure		400			{object}	util.ApiResponse{}
+//	@Failure		500			{object}	util.ApiResponse{}
+//	@Router			/attribute [post]
+func (h *AttributeHandler) Create(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.AttributeCreateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	attribute := model.AttributeDefinition{
+		CategoryID: data.CategoryID,
+		Name:       data.Name,
+		Type:       model.AttributeType(data.Type),
+		Unit:       data.Unit,
+		Options:    data.Options,
+		Required:   data.Required,
+	}
+	attribute, err = h.AttributeService.Create(attribute)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Category not found"
+			util.WriteJson(w, response)
+			return
+		}
+		if errors.Is(err, util.InvalidAttributeError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while creating attribute"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusCreated
+	response.Message = "Attribute created successfully."
+	response.Data = attribute
+	util.WriteJson(w, response)
+}
+
+// Update godoc
+//
+//	@Tags			attribute
+//	@Summary		Update an attribute
+//	@Description	Update the name, unit, options and whether an attribute is required
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			attribute	body		dto.AttributeUpdateDto	true	"Update Attribute"
+//	@Success		200			{object}	util.ApiResponse{data=model.AttributeDefinition}
+//	@Failure		400			{object}	util.ApiResponse{}
+//	@Failure		500			{object}	util.ApiResponse{}
+//	@Router			/attribute [put]
+func (h *AttributeHandler) Update(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.AttributeUpdateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	attribute := model.AttributeDefinition{
+		ID:       data.ID,
+		Name:     data.Name,
+		Unit:     data.Unit,
+		Options:  data.Options,
+		Required: data.Required,
+	}
+	attribute, err = h.AttributeService.Update(attribute)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Attribute not found"
+			util.WriteJson(w, response)
+			return
+		}
+		if errors.Is(err, util.InvalidAttributeError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while updating attribute"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = attribute
+	util.WriteJson(w, response)
+}
+
+// Delete godoc
+//
+//	@Tags			attribute
+//	@Summary		Delete an attribute
+//	@Description	Delete an attribute with the values products have for it
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Attribute ID"
+//	@Success		200	{object}	util.ApiResponse{}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/attribute/{id} [delete]
+func (h *AttributeHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	_, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid attribute id"
+		util.WriteJson(w, response)
+		return
+	}
+	err = h.AttributeService.Delete(r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Attribute not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while deleting attribute"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
@@ -27,23 +27,27 @@ func NewCategoryHandler(service service.CategoryService, validator *validator.Va
 //
 //	@Tags			category
 //	@Summary		Show a category
-//	@Description	get category by ID
+//	@Description	get category by ID or slug. Old slugs redirect to the current one
 //	@Produce		json
-//	@Param			id	path		int	true	"Category ID"
+//	@Param			id	path		string	true	"Category ID or slug"
 //	@Success		200	{object}	util.ApiResponse{data=model.Category}
 //	@Failure		400	{object}	util.ApiResponse{}
 //	@Failure		500	{object}	util.ApiResponse{}
 //	@Router			/category/{id} [get]
 func (h *CategoryHandler) Get(w http.ResponseWriter, r *http.Request) {
-	_, err := strconv.Atoi(r.PathValue("id"))
 	var response util.ApiResponse
-	if err != nil {
-		response.Status = http.StatusBadRequest
-		response.Message = "Invalid category id"
-		util.WriteJson(w, response)
-		return
+	var category model.Category
+	var err error
+	if _, convErr := strconv.Atoi(r.PathValue("id")); convErr == nil {
+		category, err = h.CategoryService.Get(r.PathValue("id"))
+	} else {
+		var redirected bool
+		category, redirected, err = h.CategoryService.GetBySlug(r.PathValue("id"))
+		if err == nil && redirected {
+			redirectToSlug(w, r, "/category/", category.Slug)
+			return
+		}
 	}
-	category, err := h.CategoryService.Get(r.PathValue("id"))
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			response.Status = http.StatusBadRequest
@@ -126,8 +130,14 @@ func (h *CategoryHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	err = h.CategoryService.Create(data.Name)
+	err = h.CategoryService.Create(model.Category{Name: data.Name, Slug: data.Slug, ParentID: data.ParentID})
 	if err != nil {
+		if errors.Is(err, util.ParentCategoryNotFoundError) || errors.Is(err, util.SlugTakenError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
 		response.Status = http.StatusInternalServerError
 		response.Message = "Error while creating category"
 		util.WriteJson(w, response)
@@ -175,7 +185,7 @@ func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
 		util.WriteJson(w, response)
 		return
 	}
-	err = h.CategoryService.Update(model.Category{Name: data.Name, ID: uint(data.ID)})
+	err = h.CategoryService.Update(model.Category{Name: data.Name, Slug: data.Slug, ID: uint(data.ID)})
 	if err != nil {
 
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -184,6 +194,12 @@ func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
 			util.WriteJson(w, response)
 			return
 		}
+		if errors.Is(err, util.SlugTakenError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
 		response.Status = http.StatusInternalServerError
 		response.Message = "Error while updating category"
 		util.WriteJson(w, response)
@@ -194,3 +210,246 @@ func (h *CategoryHandler) Update(w http.ResponseWriter, r *http.Request) {
 	response.Message = "Success"
 	util.WriteJson(w, response)
 }
+
+// Tree godoc
+//
+//	@Tags			category
+//	@Summary		Show the category tree
+//	@Description	get the root categories with all the categories below them
+//	@Produce		json
+//	@Success		200	{object}	util.ApiResponse{data=[]service.CategoryNode}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/category/tree [get]
+func (h *CategoryHandler) Tree(w http.ResponseWriter, r *http.Request) {
+	tree, err := h.CategoryService.Tree()
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting categories."
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = tree
+	util.WriteJson(w, response)
+}
+
+// Breadcrumb godoc
+//
+//	@Tags			category
+//	@Summary		Show the breadcrumb of a category
+//	@Description	get the path from the root category down to the category
+//	@Produce		json
+//	@Param			id	path		int	true	"Category ID"
+//	@Success		200	{object}	util.ApiResponse{data=[]model.Category}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/category/{id}/breadcrumb [get]
+func (h *CategoryHandler) Breadcrumb(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid category id"
+		util.WriteJson(w, response)
+		return
+	}
+	path, err := h.CategoryService.Breadcrumb(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Category not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting category"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = path
+	util.WriteJson(w, response)
+}
+
+// Move godoc
+//
+//	@Tags			category
+//	@Summary		Move a category
+//	@Description	Move a category with its subtree under another category, or to the root when parent_id is empty
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			category	body		dto.CategoryMoveDto	true	"Move Category"
+//	@Success		200			{object}	util.ApiResponse{}
+//	@Failure		400			{object}	util.ApiResponse{}
+//	@Failure		500			{object}	util.ApiResponse{}
+//	@Router			/category/move [put]
+func (h *CategoryHandler) Move(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.CategoryMoveDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+	err = h.CategoryService.Move(data.ID, data.ParentID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Category not found"
+			util.WriteJson(w, response)
+			return
+		}
+		if errors.Is(err, util.ParentCategoryNotFoundError) || errors.Is(err, util.CategoryCycleError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while moving category"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// Delete godoc
+//
+//	@Tags			category
+//	@Summary		Delete a category
+//	@Description	Delete a category. Products, subcategories, promotions and coupons of the category are moved to reassign_to; without it the category must be unused
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id			path		int	true	"Category ID"
+//	@Param			reassign_to	query		int	false	"Category ID to reassign to"
+//	@Success		200			{object}	util.ApiResponse{data=storage.CategoryDeletion}
+//	@Failure		400			{object}	util.ApiResponse{}
+//	@Failure		500			{object}	util.ApiResponse{}
+//	@Router			/category/{id} [delete]
+func (h *CategoryHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid category id"
+		util.WriteJson(w, response)
+		return
+	}
+	var reassignTo *uint
+	if value := r.URL.Query().Get("reassign_to"); value != "" {
+		target, err := strconv.Atoi(value)
+		if err != nil {
+			response.Status = http.StatusBadRequest
+			response.Message = "Invalid category id to reassign to"
+			util.WriteJson(w, response)
+			return
+		}
+		targetID := uint(target)
+		reassignTo = &targetID
+	}
+
+	report, err := h.CategoryService.Delete(uint(id), reassignTo)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Category not found"
+			util.WriteJson(w, response)
+			return
+		}
+		if errors.Is(err, util.CategoryInUseError) || errors.Is(err, util.ReassignCategoryNotFoundError) || errors.Is(err, util.CategoryReassignError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while deleting category"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = report
+	util.WriteJson(w, response)
+}
+
+// GetDeleted godoc
+//
+//	@Tags			category
+//	@Summary		Show deleted categories
+//	@Description	get the deleted categories, most recently deleted first
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	util.ApiResponse{data=[]model.Category}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/category/deleted [get]
+func (h *CategoryHandler) GetDeleted(w http.ResponseWriter, r *http.Request) {
+	categories, err := h.CategoryService.GetDeleted()
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting categories"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = categories
+	util.WriteJson(w, response)
+}
+
+// Restore godoc
+//
+//	@Tags			category
+//	@Summary		Restore a category
+//	@Description	Bring back a deleted category. It goes to the root of the tree when its parent is deleted too
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Category ID"
+//	@Success		200	{object}	util.ApiResponse{data=model.Category}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/category/{id}/restore [post]
+func (h *CategoryHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid category id"
+		util.WriteJson(w, response)
+		return
+	}
+	category, err := h.CategoryService.Restore(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Deleted category not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while restoring category"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = category
+	util.WriteJson(w, response)
+}
@@ -0,0 +1,326 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/fatihesergg/go_ecommerce/internal/dto"
+	"github.com/fatihesergg/go_ecommerce/internal/model"
+	"github.com/fatihesergg/go_ecommerce/internal/service"
+	"github.com/fatihesergg/go_ecommerce/internal/util"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+type CouponHandler struct {
+	CouponService   service.CouponService
+	ProductService  service.ProductService
+	CategoryService service.CategoryService
+	Validator       *validator.Validate
+}
+
+func NewCouponHandler(couponService service.CouponService, productService service.ProductService, categoryService service.CategoryService, validator *validator.Validate) CouponHandler {
+	return CouponHandler{CouponService: couponService, ProductService: productService, CategoryService: categoryService, Validator: validator}
+}
+
+// Get godoc
+//
+//	@Tags			coupon
+//	@Summary		Show a coupon
+//	@Description	get coupon by ID
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Coupon ID"
+//	@Success		200	{object}	util.ApiResponse{data=model.Coupon}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/coupon/{id} [get]
+func (h *CouponHandler) Get(w http.ResponseWriter, r *http.Request) {
+	_, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid coupon id"
+		util.WriteJson(w, response)
+		return
+	}
+	coupon, err := h.CouponService.Get(r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Coupon not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting coupon"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = coupon
+	util.WriteJson(w, response)
+}
+
+// GetAll godoc
+//
+//	@Tags			coupon
+//	@Summary		Show all coupons
+//	@Description	get all coupons
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	util.ApiResponse{data=[]model.Coupon}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/coupon [get]
+func (h *CouponHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	coupons, err := h.CouponService.GetAll()
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting coupons"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = coupons
+	util.WriteJson(w, response)
+}
+
+// Create godoc
+//
+//	@Tags			coupon
+//	@Summary		Create a coupon
+//	@Description	Create a coupon
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			coupon	body		dto.CouponCreateDto	true	"Create Coupon"
+//	@Success		200		{object}	util.ApiResponse{}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/coupon [post]
+func (h *CouponHandler) Create(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.CouponCreateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	coupon := model.Coupon{
+		Code:           data.Code,
+		Type:           model.DiscountType(data.Type),
+		Value:          data.Value,
+		MinOrderAmount: data.MinOrderAmount,
+		UsageLimit:     data.UsageLimit,
+		PerUserLimit:   data.PerUserLimit,
+		StartsAt:       data.StartsAt,
+		EndsAt:         data.EndsAt,
+		Active:         data.Active,
+	}
+	if !h.checkCoupon(w, &coupon, data.ProductIDs, data.CategoryIDs) {
+		return
+	}
+
+	if _, err := h.CouponService.GetByCode(coupon.Code); err == nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Coupon code already exist"
+		util.WriteJson(w, response)
+		return
+	}
+	err = h.CouponService.Create(coupon)
+	if err != nil {
+		if errors.Is(err, util.CouponValueError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while creating coupon"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusCreated
+	response.Message = "Coupon created successfully."
+	util.WriteJson(w, response)
+}
+
+// Update godoc
+//
+//	@Tags			coupon
+//	@Summary		Update a coupon
+//	@Description	Update a coupon
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			coupon	body		dto.CouponUpdateDto	true	"Update Coupon"
+//	@Success		200		{object}	util.ApiResponse{}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/coupon [put]
+func (h *CouponHandler) Update(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.CouponUpdateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	coupon := model.Coupon{
+		ID:             uint(data.ID),
+		Code:           data.Code,
+		Type:           model.DiscountType(data.Type),
+		Value:          data.Value,
+		MinOrderAmount: data.MinOrderAmount,
+		UsageLimit:     data.UsageLimit,
+		PerUserLimit:   data.PerUserLimit,
+		StartsAt:       data.StartsAt,
+		EndsAt:         data.EndsAt,
+		Active:         data.Active,
+	}
+	if !h.checkCoupon(w, &coupon, data.ProductIDs, data.CategoryIDs) {
+		return
+	}
+
+	err = h.CouponService.Update(coupon)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Coupon not found"
+			util.WriteJson(w, response)
+			return
+		}
+		if errors.Is(err, util.CouponValueError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while updating coupon"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// Delete godoc
+//
+//	@Tags			coupon
+//	@Summary		Delete a coupon
+//	@Description	Delete a coupon
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Coupon ID"
+//	@Success		200	{object}	util.ApiResponse{}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/coupon/{id} [delete]
+func (h *CouponHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	_, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid coupon id"
+		util.WriteJson(w, response)
+		return
+	}
+	err = h.CouponService.Delete(r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Coupon not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while deleting coupon"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// checkCoupon validates the rules of a coupon and loads its product and
+// category restrictions. It writes the error response and returns false when
+// the coupon is invalid.
+func (h *CouponHandler) checkCoupon(w http.ResponseWriter, coupon *model.Coupon, productIDs []uint, categoryIDs []uint) bool {
+	var response util.ApiResponse
+	if coupon.Type == model.DISCOUNT_PERCENTAGE && coupon.Value > 100 {
+		response.Status = http.StatusBadRequest
+		response.Message = "Percentage discount can't be more than 100"
+		util.WriteJson(w, response)
+		return false
+	}
+	if coupon.StartsAt != nil && coupon.EndsAt != nil && !coupon.EndsAt.After(*coupon.StartsAt) {
+		response.Status = http.StatusBadRequest
+		response.Message = "Coupon must end after it starts"
+		util.WriteJson(w, response)
+		return false
+	}
+
+	if len(productIDs) > 0 {
+		products, err := h.ProductService.GetByIDs(productIDs)
+		if err != nil {
+			response.Status = http.StatusInternalServerError
+			response.Message = "Error while getting products"
+			util.WriteJson(w, response)
+			return false
+		}
+		if len(products) != len(productIDs) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Invalid product id"
+			util.WriteJson(w, response)
+			return false
+		}
+		coupon.Products = products
+	}
+	if len(categoryIDs) > 0 {
+		categories, err := h.CategoryService.GetByIDs(categoryIDs)
+		if err != nil {
+			response.Status = http.StatusInternalServerError
+			response.Message = "Error while getting categories"
+			util.WriteJson(w, response)
+			return false
+		}
+		if len(categories) != len(categoryIDs) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Invalid category id"
+			util.WriteJson(w, response)
+			return false
+		}
+		coupon.Categories = categories
+	}
+	return true
+}
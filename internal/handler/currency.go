@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/fatihesergg/go_ecommerce/internal/dto"
+	"github.com/fatihesergg/go_ecommerce/internal/service"
+	"github.com/fatihesergg/go_ecommerce/internal/util"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+type CurrencyHandler struct {
+	CurrencyService service.CurrencyService
+	Validator       *validator.Validate
+}
+
+func NewCurrencyHandler(service service.CurrencyService, validator *validator.Validate) CurrencyHandler {
+	return CurrencyHandler{CurrencyService: service, Validator: validator}
+}
+
+// GetAll godoc
+//
+//	@Tags			currency
+//	@Summary		Show all exchange rates
+//	@Description	get exchange rates relative to the base currency
+//	@Produce		json
+//	@Success		200	{object}	util.ApiResponse{data=[]model.ExchangeRate}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/exchange-rate [get]
+func (h *CurrencyHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.CurrencyService.GetRates()
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting exchange rates"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = rates
+	util.WriteJson(w, response)
+}
+
+// Save godoc
+//
+//	@Tags			currency
+//	@Summary		Create or update an exchange rate
+//	@Description	Set the amount of a currency equal to one unit of the base currency
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			rate	body		dto.ExchangeRateDto	true	"Exchange Rate"
+//	@Success		200		{object}	util.ApiResponse{}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/exchange-rate [put]
+func (h *CurrencyHandler) Save(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.ExchangeRateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+	err = h.CurrencyService.SaveRate(data.Currency, data.Rate)
+	if err != nil {
+		if errors.Is(err, util.UnsupportedCurrencyError) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Base currency rate can't be changed"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while saving exchange rate"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// Delete godoc
+//
+//	@Tags			currency
+//	@Summary		Delete an exchange rate
+//	@Description	Delete an exchange rate
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			currency	path		string	true	"Currency code"
+//	@Success		200			{object}	util.ApiResponse{}
+//	@Failure		400			{object}	util.ApiResponse{}
+//	@Failure		500			{object}	util.ApiResponse{}
+//	@Router			/exchange-rate/{currency} [delete]
+func (h *CurrencyHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	var response util.ApiResponse
+	err := h.CurrencyService.DeleteRate(r.PathValue("currency"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Exchange rate not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while deleting exchange rate"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
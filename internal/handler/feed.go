@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fatihesergg/go_ecommerce/internal/model"
+	"github.com/fatihesergg/go_ecommerce/internal/service"
+	"github.com/fatihesergg/go_ecommerce/internal/util"
+)
+
+// exportContentTypes are the content types of the export formats.
+var exportContentTypes = map[model.ExportFormat]string{
+	model.EXPORT_CSV:  "text/csv; charset=utf-8",
+	model.EXPORT_JSON: "application/json",
+	model.EXPORT_XML:  "application/xml; charset=utf-8",
+}
+
+type FeedHandler struct {
+	FeedService service.FeedService
+}
+
+func NewFeedHandler(service service.FeedService) FeedHandler {
+	return FeedHandler{FeedService: service}
+}
+
+// Feed godoc
+//
+//	@Tags			feed
+//	@Summary		Show a product feed
+//	@Description	get the last generated product feed. products.xml is in the merchant feed format
+//	@Produce		xml
+//	@Produce		json
+//	@Produce		text/csv
+//	@Param			file	path		string	true	"Feed file"	Enums(products.csv, products.json, products.xml)
+//	@Success		200		{file}		file
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/feed/{file} [get]
+func (h *FeedHandler) Feed(w http.ResponseWriter, r *http.Request) {
+	var response util.ApiResponse
+	name, format, _ := strings.Cut(r.PathValue("file"), ".")
+	if _, ok := exportContentTypes[model.ExportFormat(format)]; name != "products" || !ok {
+		response.Status = http.StatusBadRequest
+		response.Message = "Feed not found"
+		util.WriteJson(w, response)
+		return
+	}
+	feed, err := h.FeedService.Get(model.ExportFormat(format))
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting feed"
+		util.WriteJson(w, response)
+		return
+	}
+	w.Header().Set("Content-Type", exportContentTypes[feed.Format])
+	w.Header().Set("Last-Modified", feed.GeneratedAt.UTC().Format(http.TimeFormat))
+	w.Write(feed.Content)
+}
+
+// Generate godoc
+//
+//	@Tags			feed
+//	@Summary		Generate product feeds
+//	@Description	Regenerate the product feed of a format, or of every format when none is given
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			format	query		string	false	"Feed format"	Enums(csv, json, xml)
+//	@Success		200		{object}	util.ApiResponse{data=[]model.ProductFeed}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/feed/generate [post]
+func (h *FeedHandler) Generate(w http.ResponseWriter, r *http.Request) {
+	var response util.ApiResponse
+	var feeds []model.ProductFeed
+	var err error
+	if format := r.URL.Query().Get("format"); format != "" {
+		var feed model.ProductFeed
+		feed, err = h.FeedService.Generate(model.ExportFormat(strings.ToLower(format)))
+		feeds = []model.ProductFeed{feed}
+	} else {
+		feeds, err = h.FeedService.GenerateAll()
+	}
+	if err != nil {
+		if errors.Is(err, util.ExportFormatError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while generating feed"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = feeds
+	util.WriteJson(w, response)
+}
+
+// Export godoc
+//
+//	@Tags			feed
+//	@Summary		Export products
+//	@Description	Download the current catalog as CSV, JSON or a merchant feed
+//	@Produce		xml
+//	@Produce		json
+//	@Produce		text/csv
+//	@Security		BearerAuth
+//	@Param			format	query		string	true	"Export format"	Enums(csv, json, xml)
+//	@Success		200		{file}		file
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/export/product [get]
+func (h *FeedHandler) Export(w http.ResponseWriter, r *http.Request) {
+	var response util.ApiResponse
+	format := model.ExportFormat(strings.ToLower(r.URL.Query().Get("format")))
+	content, _, err := h.FeedService.Export(format)
+	if err != nil {
+		if errors.Is(err, util.ExportFormatError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while exporting products"
+		util.WriteJson(w, response)
+		return
+	}
+	fileName := "products-" + strconv.FormatInt(time.Now().Unix(), 10) + "." + string(format)
+	w.Header().Set("Content-Type", exportContentTypes[format])
+	w.Header().Set("Content-Disposition", `attachment; filename="`+fileName+`"`)
+	w.Write(content)
+}
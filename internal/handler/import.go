@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/fatihesergg/go_ecommerce/internal/model"
+	"github.com/fatihesergg/go_ecommerce/internal/service"
+	"github.com/fatihesergg/go_ecommerce/internal/util"
+	"gorm.io/gorm"
+)
+
+// MAX_IMPORT_SIZE is the largest import file accepted, in bytes.
+const MAX_IMPORT_SIZE = 10 << 20
+
+type ImportHandler struct {
+	ImportService service.ImportService
+}
+
+func NewImportHandler(service service.ImportService) ImportHandler {
+	return ImportHandler{ImportService: service}
+}
+
+// ImportProducts godoc
+//
+//	@Tags			import
+//	@Summary		Import products
+//	@Description	Upload a CSV or JSON file of products. Rows are matched by SKU, updating known products and creating the others, in a background job
+//	@Accept			mpfd
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			file	formData	file	true	"CSV or JSON file"
+//	@Param			format	query		string	false	"File format, taken from the file extension when not given"	Enums(csv, json)
+//	@Success		202		{object}	util.ApiResponse{data=model.ImportJob}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/import/product [post]
+func (h *ImportHandler) ImportProducts(w http.ResponseWriter, r *http.Request) {
+	var response util.ApiResponse
+	r.Body = http.MaxBytesReader(w, r.Body, MAX_IMPORT_SIZE)
+	file, header, err := r.FormFile("file")
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Error while reading file"
+		util.WriteJson(w, response)
+		return
+	}
+	defer file.Close()
+
+	format := r.URL.Query().Get("format")
+	if format == "" {
+		format = strings.TrimPrefix(filepath.Ext(header.Filename), ".")
+	}
+	rows, err := service.ParseImport(model.ImportFormat(strings.ToLower(format)), file)
+	if err != nil {
+		if errors.Is(err, util.ImportFormatError) || errors.Is(err, util.ImportFileError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while reading file"
+		util.WriteJson(w, response)
+		return
+	}
+
+	userID := actorID(r)
+	job := model.ImportJob{UserID: &userID, FileName: header.Filename, Format: model.ImportFormat(strings.ToLower(format))}
+	job, err = h.ImportService.Start(job, rows)
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while starting import"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusAccepted
+	response.Message = "Import started."
+	response.Data = job
+	util.WriteJson(w, response)
+}
+
+// Get godoc
+//
+//	@Tags			import
+//	@Summary		Show an import
+//	@Description	get the progress of an import with the errors of the rows that failed
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Import ID"
+//	@Success		200	{object}	util.ApiResponse{data=model.ImportJob}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/import/{id} [get]
+func (h *ImportHandler) Get(w http.ResponseWriter, r *http.Request) {
+	_, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid import id"
+		util.WriteJson(w, response)
+		return
+	}
+	job, err := h.ImportService.Get(r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Import not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting import"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = job
+	util.WriteJson(w, response)
+}
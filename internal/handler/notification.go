@@ -0,0 +1,137 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/fatihesergg/go_ecommerce/internal/dto"
+	"github.com/fatihesergg/go_ecommerce/internal/service"
+	"github.com/fatihesergg/go_ecommerce/internal/util"
+	"gorm.io/gorm"
+)
+
+type NotificationHandler struct {
+	NotificationService service.NotificationService
+}
+
+func NewNotificationHandler(service service.NotificationService) NotificationHandler {
+	return NotificationHandler{NotificationService: service}
+}
+
+const (
+	defaultNotificationPageSize = 20
+	maxNotificationPageSize     = 100
+)
+
+// GetAll godoc
+//
+//	@Tags			notification
+//	@Summary		Show my notifications
+//	@Description	get a page of the notifications of the logged in user, newest first
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			page		query		int	false	"Page number"	default(1)
+//	@Param			page_size	query		int	false	"Page size"		default(20)
+//	@Success		200			{object}	util.ApiResponse{data=dto.NotificationListDto}
+//	@Failure		400			{object}	util.ApiResponse{}
+//	@Failure		500			{object}	util.ApiResponse{}
+//	@Router			/me/notifications [get]
+func (h *NotificationHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	var response util.ApiResponse
+	page, pageSize, message := pageQuery(r.URL.Query(), defaultNotificationPageSize, maxNotificationPageSize)
+	if message != "" {
+		response.Status = http.StatusBadRequest
+		response.Message = message
+		util.WriteJson(w, response)
+		return
+	}
+	notifications, total, err := h.NotificationService.GetByUser(actorID(r), page, pageSize)
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting notifications"
+		util.WriteJson(w, response)
+		return
+	}
+	unread, err := h.NotificationService.CountUnread(actorID(r))
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting notifications"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = dto.NotificationListDto{
+		Unread:        unread,
+		Notifications: notifications,
+		Page:          page,
+		PageSize:      pageSize,
+		Total:         total,
+		TotalPages:    totalPages(total, pageSize),
+	}
+	util.WriteJson(w, response)
+}
+
+// MarkRead godoc
+//
+//	@Tags			notification
+//	@Summary		Mark a notification as read
+//	@Description	Mark a notification of the logged in user as read
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Notification ID"
+//	@Success		200	{object}	util.ApiResponse{}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/me/notifications/{id}/read [post]
+func (h *NotificationHandler) MarkRead(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid notification id"
+		util.WriteJson(w, response)
+		return
+	}
+	err = h.NotificationService.MarkRead(uint(id), actorID(r))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Notification not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while updating notification"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// MarkAllRead godoc
+//
+//	@Tags			notification
+//	@Summary		Mark all notifications as read
+//	@Description	Mark every notification of the logged in user as read
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/me/notifications/read [post]
+func (h *NotificationHandler) MarkAllRead(w http.ResponseWriter, r *http.Request) {
+	var response util.ApiResponse
+	err := h.NotificationService.MarkAllRead(actorID(r))
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while updating notifications"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
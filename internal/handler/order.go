@@ -4,8 +4,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"strconv"
+	"strings"
 
 	"github.com/fatihesergg/go_ecommerce/internal/dto"
 	"github.com/fatihesergg/go_ecommerce/internal/middleware"
@@ -17,20 +19,32 @@ import (
 )
 
 type OrderHandler struct {
-	OrderService    service.OrderService
-	ProductService  service.ProductService
-	CategoryService service.CategoryService
-	UserService     service.UserService
-	Validator       *validator.Validate
+	OrderService     service.OrderService
+	ProductService   service.ProductService
+	CategoryService  service.CategoryService
+	UserService      service.UserService
+	CurrencyService  service.CurrencyService
+	CouponService    service.CouponService
+	PromotionService service.PromotionService
+	TaxService       service.TaxService
+	AddressService   service.AddressService
+	ShippingService  service.ShippingService
+	Validator        *validator.Validate
 }
 
-func NewOrderHandler(orderService service.OrderService, productService service.ProductService, categoryService service.CategoryService, userService service.UserService, validator *validator.Validate) OrderHandler {
+func NewOrderHandler(orderService service.OrderService, productService service.ProductService, categoryService service.CategoryService, userService service.UserService, currencyService service.CurrencyService, couponService service.CouponService, promotionService service.PromotionService, taxService service.TaxService, addressService service.AddressService, shippingService service.ShippingService, validator *validator.Validate) OrderHandler {
 	return OrderHandler{
-		OrderService:    orderService,
-		ProductService:  productService,
-		CategoryService: categoryService,
-		UserService:     userService,
-		Validator:       validator,
+		OrderService:     orderService,
+		ProductService:   productService,
+		CategoryService:  categoryService,
+		UserService:      userService,
+		CurrencyService:  currencyService,
+		CouponService:    couponService,
+		PromotionService: promotionService,
+		TaxService:       taxService,
+		AddressService:   addressService,
+		ShippingService:  shippingService,
+		Validator:        validator,
 	}
 }
 
@@ -56,6 +70,9 @@ func (h *OrderHandler) Get(w http.ResponseWriter, r *http.Request) {
 	}
 
 	order, err := h.OrderService.Get(r.PathValue("id"))
+	if err == nil && order.UserID != actorID(r) {
+		err = gorm.ErrRecordNotFound
+	}
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			response.Status = http.StatusBadRequest
@@ -82,10 +99,13 @@ func (h *OrderHandler) Get(w http.ResponseWriter, r *http.Request) {
 //	@Description	Create a order
 //	@Security		BearerAuth
 //	@Produce		json
-//	@Param			order	body		dto.CreateOrderDto	true	"Order"
-//	@Success		200		{object}	util.ApiResponse{}
-//	@Failure		400		{object}	util.ApiResponse{}
-//	@Failure		500		{object}	util.ApiResponse{}
+//	@Param			order			body		dto.CreateOrderDto	true	"Order"
+//	@Param			Idempotency-Key	header		string				false	"Idempotency key"
+//	@Success		200				{object}	util.ApiResponse{data=model.Order}
+//	@Failure		400				{object}	util.ApiResponse{}
+//	@Failure		409				{object}	util.ApiResponse{}
+//	@Failure		422				{object}	util.ApiResponse{}
+//	@Failure		500				{object}	util.ApiResponse{}
 //	@Router			/order [post]
 func (h *OrderHandler) Create(w http.ResponseWriter, r *http.Request) {
 	defer r.Body.Close()
@@ -105,12 +125,157 @@ func (h *OrderHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	order, ok := h.buildOrder(w, r, data, true)
+	if !ok {
+		return
+	}
+
+	order, err = h.OrderService.Create(order)
+	if err != nil {
+		if isCouponError(err) || errors.Is(err, util.OutOfStockError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while creating order"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusCreated
+	response.Message = "Order created successfully."
+	response.Data = order
+	util.WriteJson(w, response)
+}
+
+// Cancel godoc
+//
+//	@Tags			order
+//	@Summary		Cancel an order
+//	@Description	Cancel a pending order of the user and put its items back in stock
+//	@Security		BearerAuth
+//	@Produce		json
+//	@Param			id	path		int	true	"Order ID"
+//	@Success		200	{object}	util.ApiResponse{}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/order/{id}/cancel [post]
+func (h *OrderHandler) Cancel(w http.ResponseWriter, r *http.Request) {
+	_, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid order id"
+		util.WriteJson(w, response)
+		return
+	}
+	order, err := h.OrderService.Get(r.PathValue("id"))
+	userID := actorID(r)
+	if err == nil && order.UserID != userID {
+		err = gorm.ErrRecordNotFound
+	}
+	if err == nil {
+		err = h.OrderService.Cancel(order.ID, userID)
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Order not found"
+			util.WriteJson(w, response)
+			return
+		}
+		if errors.Is(err, util.OrderNotCancellableError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while cancelling order"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// Quote godoc
+//
+//	@Tags			order
+//	@Summary		Price a cart
+//	@Description	Calculate the totals, discounts and taxes of a cart without creating an order
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			order	body		dto.CreateOrderDto	true	"Cart"
+//	@Success		200		{object}	util.ApiResponse{data=model.Order}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/order/quote [post]
+func (h *OrderHandler) Quote(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.CreateOrderDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	order, ok := h.buildOrder(w, r, data, false)
+	if !ok {
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = order
+	util.WriteJson(w, response)
+}
+
+// buildOrder prices the cart of the request. Taxes are calculated for the
+// shipping address. Quotes without a shipping address may give a country and
+// region to be priced for instead; at checkout a shipping address is
+// mandatory, and so is a shipping method when any can ship to it. It writes
+// the error response and returns false when the order can't be built.
+func (h *OrderHandler) buildOrder(w http.ResponseWriter, r *http.Request, data dto.CreateOrderDto, checkout bool) (model.Order, bool) {
+	var response util.ApiResponse
 	userID, _ := r.Context().Value(middleware.AuthUserID).(string)
 
-	var totalAmount float64
+	currency, rate, err := h.CurrencyService.Resolve(data.Currency)
+	if err != nil {
+		if errors.Is(err, util.UnsupportedCurrencyError) {
+			response.Status = http.StatusBadRequest
+			response.Message = util.UnsupportedCurrencyError.Error()
+			util.WriteJson(w, response)
+			return model.Order{}, false
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting exchange rate"
+		util.WriteJson(w, response)
+		return model.Order{}, false
+	}
+
+	var subtotal float64
 	var orderItems []model.OrderItem
+	lines := map[uint]int{}
 
 	for _, orderItem := range data.Products {
+		if i, ok := lines[orderItem.ProductID]; ok {
+			orderItems[i].Quantity += int(orderItem.Quantity)
+			subtotal += orderItems[i].UnitPrice * float64(orderItem.Quantity)
+			continue
+		}
 		productID := strconv.Itoa(int(orderItem.ProductID))
 		product, err := h.ProductService.Get(productID)
 		if err != nil {
@@ -118,37 +283,255 @@ func (h *OrderHandler) Create(w http.ResponseWriter, r *http.Request) {
 				response.Status = http.StatusBadRequest
 				response.Message = fmt.Sprintf("Product with id %d not found", orderItem.ProductID)
 				util.WriteJson(w, response)
-				return
+				return model.Order{}, false
 			}
 			response.Status = http.StatusInternalServerError
 			response.Message = "Error while getting product"
 			util.WriteJson(w, response)
-			return
+			return model.Order{}, false
+		}
+		price, err := h.CurrencyService.Price(product, currency, rate)
+		if err != nil {
+			response.Status = http.StatusInternalServerError
+			response.Message = "Error while getting product price"
+			util.WriteJson(w, response)
+			return model.Order{}, false
 		}
 
-		orderItems = append(orderItems, model.OrderItem{
-			ProductID: orderItem.ProductID,
-			Product:   product,
-			Quantity:  int(orderItem.Quantity),
-		})
-		totalAmount += product.Price * float64(orderItem.Quantity)
-
+		lines[orderItem.ProductID] = len(orderItems)
+		item := model.OrderItem{
+			ProductID:   orderItem.ProductID,
+			Product:     product,
+			ProductName: product.Name,
+			Quantity:    int(orderItem.Quantity),
+			UnitPrice:   price,
+		}
+		if product.SKU != nil {
+			item.SKU = *product.SKU
+		}
+		orderItems = append(orderItems, item)
+		subtotal += price * float64(orderItem.Quantity)
 	}
 
 	userIdint, _ := strconv.Atoi(userID)
 	user, _ := h.UserService.Get(userID)
 	order := model.Order{
-		UserID:      uint(userIdint),
-		User:        user,
-		TotalAmount: totalAmount,
-		Products:    orderItems,
+		UserID:       uint(userIdint),
+		User:         user,
+		Products:     orderItems,
+		Subtotal:     util.RoundAmount(subtotal),
+		Currency:     currency,
+		ExchangeRate: rate,
 	}
 
-	err = h.OrderService.Create(order)
+	var shipping model.Address
+	found, ok := true, true
+	if !checkout && data.ShippingAddressID == nil && data.Country != "" {
+		shipping = model.Address{Country: strings.ToUpper(data.Country), Region: data.Region}
+	} else {
+		shipping, found, ok = h.orderAddress(w, order.UserID, data.ShippingAddressID, h.AddressService.GetDefaultShipping)
+	}
+	if !ok {
+		return model.Order{}, false
+	}
+	if !found && checkout {
+		response.Status = http.StatusBadRequest
+		response.Message = "Shipping address is required"
+		util.WriteJson(w, response)
+		return model.Order{}, false
+	}
+	billing, billingFound, ok := h.orderAddress(w, order.UserID, data.BillingAddressID, h.AddressService.GetDefaultBilling)
+	if !ok {
+		return model.Order{}, false
+	}
+	if !billingFound {
+		billing = shipping
+	}
+	order.ShippingAddress = service.Snapshot(shipping)
+	order.BillingAddress = service.Snapshot(billing)
+
+	discounts, err := h.PromotionService.Apply(order.Products, rate)
 	if err != nil {
 		response.Status = http.StatusInternalServerError
-		response.Message = "Error while creating order"
+		response.Message = "Error while applying promotions"
+		util.WriteJson(w, response)
+		return model.Order{}, false
+	}
+	order.Discounts = append(order.Discounts, discounts...)
+
+	if data.CouponCode != "" {
+		discount, err := h.CouponService.Apply(data.CouponCode, order.UserID, order.Products, rate)
+		if err != nil {
+			if isCouponError(err) {
+				response.Status = http.StatusBadRequest
+				response.Message = err.Error()
+				util.WriteJson(w, response)
+				return model.Order{}, false
+			}
+			response.Status = http.StatusInternalServerError
+			response.Message = "Error while applying coupon"
+			util.WriteJson(w, response)
+			return model.Order{}, false
+		}
+		order.Discounts = append(order.Discounts, discount)
+	}
+
+	err = h.TaxService.Apply(shipping.Country, shipping.Region, order.Products)
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while calculating taxes"
 		util.WriteJson(w, response)
+		return model.Order{}, false
+	}
 
+	for _, discount := range order.Discounts {
+		order.DiscountAmount += discount.Amount
+	}
+	var totalAmount float64
+	for _, item := range order.Products {
+		order.TaxAmount += item.TaxAmount
+		totalAmount += item.Total
+	}
+	order.DiscountAmount = util.RoundAmount(math.Min(order.DiscountAmount, order.Subtotal))
+	order.TaxAmount = util.RoundAmount(order.TaxAmount)
+
+	amount := order.Subtotal - order.DiscountAmount
+	if data.ShippingMethodID != nil {
+		if !found {
+			response.Status = http.StatusBadRequest
+			response.Message = "Shipping address is required"
+			util.WriteJson(w, response)
+			return model.Order{}, false
+		}
+		method, cost, err := h.ShippingService.Quote(*data.ShippingMethodID, shipping.Country, order.Products, amount, rate)
+		if err != nil {
+			if errors.Is(err, util.ShippingMethodNotAvailableError) {
+				response.Status = http.StatusBadRequest
+				response.Message = err.Error()
+				util.WriteJson(w, response)
+				return model.Order{}, false
+			}
+			response.Status = http.StatusInternalServerError
+			response.Message = "Error while calculating shipping"
+			util.WriteJson(w, response)
+			return model.Order{}, false
+		}
+		order.ShippingMethodID = &method.ID
+		order.ShippingMethod = method.Name
+		order.ShippingAmount = cost
+	} else if checkout {
+		rates, err := h.ShippingService.Rates(shipping.Country, order.Products, amount, currency, rate)
+		if err != nil {
+			response.Status = http.StatusInternalServerError
+			response.Message = "Error while calculating shipping"
+			util.WriteJson(w, response)
+			return model.Order{}, false
+		}
+		if len(rates) > 0 {
+			response.Status = http.StatusBadRequest
+			response.Message = "Shipping method is required"
+			util.WriteJson(w, response)
+			return model.Order{}, false
+		}
 	}
+	order.TotalAmount = util.RoundAmount(totalAmount + order.ShippingAmount)
+	return order, true
+}
+
+// ShippingRates godoc
+//
+//	@Tags			order
+//	@Summary		Quote shipping for a cart
+//	@Description	Price every shipping method that can ship the cart to the shipping address
+//	@Security		BearerAuth
+//	@Accept			json
+//	@Produce		json
+//	@Param			order	body		dto.CreateOrderDto	true	"Cart"
+//	@Success		200		{object}	util.ApiResponse{data=[]service.ShippingRate}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/order/shipping-rates [post]
+func (h *OrderHandler) ShippingRates(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.CreateOrderDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	data.ShippingMethodID = nil
+	order, ok := h.buildOrder(w, r, data, false)
+	if !ok {
+		return
+	}
+	if order.ShippingAddress.Country == "" {
+		response.Status = http.StatusBadRequest
+		response.Message = "Shipping address is required"
+		util.WriteJson(w, response)
+		return
+	}
+	rates, err := h.ShippingService.Rates(order.ShippingAddress.Country, order.Products, order.Subtotal-order.DiscountAmount, order.Currency, order.ExchangeRate)
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while calculating shipping"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = rates
+	util.WriteJson(w, response)
+}
+
+// orderAddress loads the address with the given id, or the default address of
+// the user when id is nil. found is false when the user has no default
+// address. It writes the error response and returns false when the address
+// can't be used.
+func (h *OrderHandler) orderAddress(w http.ResponseWriter, userID uint, id *uint, getDefault func(uint) (model.Address, error)) (address model.Address, found bool, ok bool) {
+	var response util.ApiResponse
+	var err error
+	if id != nil {
+		address, err = h.AddressService.Get(strconv.Itoa(int(*id)))
+		if err == nil && address.UserID != userID {
+			err = gorm.ErrRecordNotFound
+		}
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Address not found"
+			util.WriteJson(w, response)
+			return model.Address{}, false, false
+		}
+	} else {
+		address, err = getDefault(userID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.Address{}, false, true
+		}
+	}
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting address"
+		util.WriteJson(w, response)
+		return model.Address{}, false, false
+	}
+	return address, true, true
+}
+
+func isCouponError(err error) bool {
+	return errors.Is(err, util.CouponNotFoundError) ||
+		errors.Is(err, util.CouponNotActiveError) ||
+		errors.Is(err, util.CouponUsageLimitError) ||
+		errors.Is(err, util.CouponUserLimitError) ||
+		errors.Is(err, util.CouponMinimumNotMetError) ||
+		errors.Is(err, util.CouponNotApplicableError)
 }
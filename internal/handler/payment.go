@@ -24,6 +24,21 @@ func NewPaymentHandler(paymentService service.PaymentService, orderService servi
 	return PaymentHandler{PaymentService: paymentService, OrderService: orderService, Validator: validator}
 }
 
+// Create godoc
+//
+//	@Tags			payment
+//	@Summary		Pay an order
+//	@Description	Charge the total amount of an order
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id				path		int		true	"Order ID"
+//	@Param			Idempotency-Key	header		string	false	"Idempotency key"
+//	@Success		200				{object}	util.ApiResponse{}
+//	@Failure		400				{object}	util.ApiResponse{}
+//	@Failure		409				{object}	util.ApiResponse{}
+//	@Failure		422				{object}	util.ApiResponse{}
+//	@Failure		500				{object}	util.ApiResponse{}
+//	@Router			/payment/{id} [post]
 func (h *PaymentHandler) Create(w http.ResponseWriter, r *http.Request) {
 	STRIPE_API := os.Getenv("STRIPE_API")
 
@@ -61,16 +76,63 @@ func (h *PaymentHandler) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	paid, err := h.PaymentService.IsPaid(order)
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting payment"
+		util.WriteJson(w, response)
+		return
+	}
+	if paid {
+		response.Status = http.StatusBadRequest
+		response.Message = "Order is already paid"
+		util.WriteJson(w, response)
+		return
+	}
+
+	// Claim the order so that concurrent requests can't charge it twice.
+	claimed, err := h.OrderService.UpdateStatus(order.ID, model.ORDER_PENDING, model.ORDER_PROCESSING)
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while updating order"
+		util.WriteJson(w, response)
+		return
+	}
+	if !claimed {
+		response.Status = http.StatusConflict
+		response.Message = "Order can't be paid in its current status"
+		util.WriteJson(w, response)
+		return
+	}
+
 	payment := model.Payment{
-		Amount:  float64(order.TotalAmount),
-		OrderID: order.ID,
-		Order:   order,
+		Amount:   float64(order.TotalAmount),
+		Currency: order.Currency,
+		OrderID:  order.ID,
+		Order:    order,
 	}
 
 	err = h.PaymentService.ProcessPayment(payment)
+	if err != nil {
+		if errors.Is(err, util.PaymentFailedError) {
+			h.OrderService.UpdateStatus(order.ID, model.ORDER_PROCESSING, model.ORDER_PENDING)
+			response.Status = http.StatusBadRequest
+			response.Message = util.PaymentFailedError.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		// The charge may have gone through, so the order stays processing
+		// until the payment is reconciled instead of being paid again.
+		response.Status = http.StatusInternalServerError
+		response.Message = "Payment couldn't be confirmed, the order is held for review"
+		util.WriteJson(w, response)
+		return
+	}
+
+	_, err = h.OrderService.UpdateStatus(order.ID, model.ORDER_PROCESSING, model.ORDER_PAID)
 	if err != nil {
 		response.Status = http.StatusInternalServerError
-		response.Message = "Error while saving payment"
+		response.Message = "Payment succeeded but order status couldn't be updated"
 		util.WriteJson(w, response)
 		return
 	}
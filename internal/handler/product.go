@@ -4,11 +4,15 @@ import (
 	"encoding/json"
 	"errors"
 	"net/http"
+	"net/url"
+	"slices"
 	"strconv"
+	"strings"
 
 	"github.com/fatihesergg/go_ecommerce/internal/dto"
 	"github.com/fatihesergg/go_ecommerce/internal/model"
 	"github.com/fatihesergg/go_ecommerce/internal/service"
+	"github.com/fatihesergg/go_ecommerce/internal/storage"
 	"github.com/fatihesergg/go_ecommerce/internal/util"
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
@@ -17,21 +21,24 @@ import (
 type ProductHandler struct {
 	CategoryService service.CategoryService
 	ProductService  service.ProductService
+	CurrencyService service.CurrencyService
+	PriceService    service.PriceService
 	Validator       *validator.Validate
 }
 
-func NewProductHandler(productService service.ProductService, categoryService service.CategoryService, validator *validator.Validate) ProductHandler {
-	return ProductHandler{ProductService: productService, CategoryService: categoryService, Validator: validator}
+func NewProductHandler(productService service.ProductService, categoryService service.CategoryService, currencyService service.CurrencyService, priceService service.PriceService, validator *validator.Validate) ProductHandler {
+	return ProductHandler{ProductService: productService, CategoryService: categoryService, CurrencyService: currencyService, PriceService: priceService, Validator: validator}
 }
 
 // Get godoc
 //
 //	@Tags			product
 //	@Summary		Show a product
-//	@Description	get product by ID
+//	@Description	get product by ID or slug. Old slugs redirect to the current one
 //	@Produce		json
-//	@Param			id	path		int	true	"Product ID"
-//	@Success		200	{object}	util.ApiResponse{data=model.Product}
+//	@Param			id			path		string	true	"Product ID or slug"
+//	@Param			currency	query		string	false	"Currency code"
+//	@Success		200			{object}	util.ApiResponse{data=model.Product}
 //	@Failure		400	{object}	util.ApiResponse{}
 //	@Failure		500	{object}	util.ApiResponse{}
 //	@Router			/product/{id} [get]
@@ -44,7 +51,18 @@ func (h ProductHandler) Get(w http.ResponseWriter, r *http.Request) {
 		util.WriteJson(w, response)
 		return
 	}
-	product, err := h.ProductService.Get(id)
+	var product model.Product
+	var err error
+	if _, convErr := strconv.Atoi(id); convErr == nil {
+		product, err = h.ProductService.Get(id)
+	} else {
+		var redirected bool
+		product, redirected, err = h.ProductService.GetBySlug(id)
+		if err == nil && redirected {
+			redirectToSlug(w, r, "/product/", product.Slug)
+			return
+		}
+	}
 	if err != nil {
 
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -59,9 +77,13 @@ func (h ProductHandler) Get(w http.ResponseWriter, r *http.Request) {
 		util.WriteJson(w, response)
 		return
 	}
+	products := []model.Product{product}
+	if !h.localize(w, r, products) {
+		return
+	}
 	response.Status = http.StatusOK
 	response.Message = "Success"
-	response.Data = product
+	response.Data = products[0]
 	util.WriteJson(w, response)
 }
 
@@ -71,15 +93,33 @@ func (h ProductHandler) Get(w http.ResponseWriter, r *http.Request) {
 //	@Summary		Show all product
 //	@Description	get products
 //	@Produce		json
-//	@Success		200	{object}	util.ApiResponse{data=[]model.Product}
-//	@Failure		400	{object}	util.ApiResponse{}
-//	@Failure		500	{object}	util.ApiResponse{}
+//	@Param			currency			query		string	false	"Currency code"
+//	@Param			min_rating			query		number	false	"Minimum average rating"
+//	@Param			sort				query		string	false	"Sort order"	Enums(rating, reviews)
+//	@Param			category_id			query		int		false	"Category ID"
+//	@Param			include_descendants	query		bool	false	"Include the products of the categories below the category"
+//	@Param			attr_{id}			query		string	false	"Comma separated values of an attribute; attr_{id}_min and attr_{id}_max bound number attributes"
+//	@Success		200					{object}	util.ApiResponse{data=[]model.Product}
+//	@Failure		400					{object}	util.ApiResponse{}
+//	@Failure		500					{object}	util.ApiResponse{}
 //	@Router			/product [get]
 func (h ProductHandler) GetAll(w http.ResponseWriter, r *http.Request) {
-	products, err := h.ProductService.GetAll()
+	filter, ok := productFilter(w, r)
+	if !ok {
+		return
+	}
+	if !h.categoryFilter(w, r, &filter) {
+		return
+	}
+	products, err := h.ProductService.GetAll(filter)
 	var response util.ApiResponse
 	if err != nil {
-
+		if errors.Is(err, util.InvalidAttributeError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			response.Status = http.StatusBadRequest
 			response.Message = "Product not found"
@@ -91,6 +131,9 @@ func (h ProductHandler) GetAll(w http.ResponseWriter, r *http.Request) {
 		util.WriteJson(w, response)
 		return
 	}
+	if !h.localize(w, r, products) {
+		return
+	}
 	response.Status = http.StatusOK
 	response.Message = "Success"
 	response.Data = products
@@ -144,14 +187,30 @@ func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
 		}
 	}
 	product := model.Product{
-		Name:       data.Name,
-		ImageURL:   data.ImageURL,
-		Price:      data.Price,
-		CategoryID: data.CategoryID,
-		Stock:      data.Stock,
+		Name:        data.Name,
+		Slug:        data.Slug,
+		SKU:         data.SKU,
+		ImageURL:    data.ImageURL,
+		Price:       data.Price,
+		CategoryID:  data.CategoryID,
+		Stock:       data.Stock,
+		TaxCategory: data.TaxCategory,
+		Weight:      data.Weight,
+		Length:      data.Length,
+		Width:       data.Width,
+		Height:      data.Height,
+		Description: data.Description,
+		Attributes:  productAttributes(data.Attributes),
 	}
-	err = h.ProductService.Create(product)
+	userID := actorID(r)
+	err = h.ProductService.Create(product, model.StockMovement{Reason: model.STOCK_OPENING, UserID: &userID})
 	if err != nil {
+		if errors.Is(err, util.SlugTakenError) || errors.Is(err, util.SKUTakenError) || errors.Is(err, util.InvalidAttributeError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
 		response.Status = http.StatusInternalServerError
 		response.Message = "Error while creating product"
 		util.WriteJson(w, response)
@@ -167,7 +226,7 @@ func (h *ProductHandler) Create(w http.ResponseWriter, r *http.Request) {
 //	@Tags			product
 //	@Summary		Update a product
 //
-//	@Description	Update a product
+//	@Description	Update a product. Stock is changed through stock adjustments
 //
 //	@Accept			json
 //
@@ -198,7 +257,7 @@ func (h *ProductHandler) Update(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	product := model.Product{ID: uint(data.ID), Name: data.Name, ImageURL: data.ImageURL, Price: data.Price, Stock: data.Stock, CategoryID: data.CategoryID}
+	product := model.Product{ID: uint(data.ID), Name: data.Name, Slug: data.Slug, SKU: data.SKU, ImageURL: data.ImageURL, Price: data.Price, CategoryID: data.CategoryID, TaxCategory: data.TaxCategory, Weight: data.Weight, Length: data.Length, Width: data.Width, Height: data.Height, Description: data.Description, Attributes: productAttributes(data.Attributes)}
 	err = h.ProductService.Update(product)
 	if err != nil {
 
@@ -208,6 +267,12 @@ func (h *ProductHandler) Update(w http.ResponseWriter, r *http.Request) {
 			util.WriteJson(w, response)
 			return
 		}
+		if errors.Is(err, util.SlugTakenError) || errors.Is(err, util.SKUTakenError) || errors.Is(err, util.InvalidAttributeError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
 		response.Status = http.StatusInternalServerError
 		response.Message = "Error while updating product."
 		util.WriteJson(w, response)
@@ -260,3 +325,566 @@ func (h *ProductHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	response.Message = "Success"
 	util.WriteJson(w, response)
 }
+
+// GetDeleted godoc
+//
+//	@Tags			product
+//	@Summary		Show deleted products
+//	@Description	get the deleted products, most recently deleted first
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	util.ApiResponse{data=[]model.Product}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/product/deleted [get]
+func (h *ProductHandler) GetDeleted(w http.ResponseWriter, r *http.Request) {
+	products, err := h.ProductService.GetDeleted()
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting products"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = products
+	util.WriteJson(w, response)
+}
+
+// Restore godoc
+//
+//	@Tags			product
+//	@Summary		Restore a product
+//	@Description	Bring back a deleted product with its attribute values. Its category must not be deleted
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Product ID"
+//	@Success		200	{object}	util.ApiResponse{data=model.Product}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/product/{id}/restore [post]
+func (h *ProductHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid product id"
+		util.WriteJson(w, response)
+		return
+	}
+	product, err := h.ProductService.Restore(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Deleted product not found"
+			util.WriteJson(w, response)
+			return
+		}
+		if errors.Is(err, util.ProductCategoryDeletedError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while restoring product"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = product
+	util.WriteJson(w, response)
+}
+
+// localize fills the was price and lowest price of the products and converts
+// their prices into the currency selected by the "currency" query parameter.
+// It writes the error response and returns false when the currency can't be
+// used.
+func (h ProductHandler) localize(w http.ResponseWriter, r *http.Request, products []model.Product) bool {
+	var response util.ApiResponse
+	if err := h.PriceService.Annotate(products); err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting price history"
+		util.WriteJson(w, response)
+		return false
+	}
+	err := h.CurrencyService.Localize(products, r.URL.Query().Get("currency"))
+	if err != nil {
+		if errors.Is(err, util.UnsupportedCurrencyError) {
+			response.Status = http.StatusBadRequest
+			response.Message = util.UnsupportedCurrencyError.Error()
+			util.WriteJson(w, response)
+			return false
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while converting prices"
+		util.WriteJson(w, response)
+		return false
+	}
+	return true
+}
+
+// GetPrices godoc
+//
+//	@Tags			product
+//	@Summary		Show the price list of a product
+//	@Description	get per-currency prices of a product
+//	@Produce		json
+//	@Param			id	path		int	true	"Product ID"
+//	@Success		200	{object}	util.ApiResponse{data=[]model.ProductPrice}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/product/{id}/price [get]
+func (h *ProductHandler) GetPrices(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid product id"
+		util.WriteJson(w, response)
+		return
+	}
+	prices, err := h.CurrencyService.GetProductPrices(uint(id))
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting prices"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = prices
+	util.WriteJson(w, response)
+}
+
+// SavePrice godoc
+//
+//	@Tags			product
+//	@Summary		Set a product price in a currency
+//	@Description	Create or update the price of a product in a currency
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			price	body		dto.ProductPriceDto	true	"Product Price"
+//	@Success		200		{object}	util.ApiResponse{}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/product/price [put]
+func (h *ProductHandler) SavePrice(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.ProductPriceDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+	_, err = h.ProductService.Get(strconv.Itoa(int(data.ProductID)))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Product not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting product"
+		util.WriteJson(w, response)
+		return
+	}
+	err = h.CurrencyService.SaveProductPrice(data.ProductID, data.Currency, data.Price)
+	if err != nil {
+		if errors.Is(err, util.UnsupportedCurrencyError) {
+			response.Status = http.StatusBadRequest
+			response.Message = util.UnsupportedCurrencyError.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while saving price"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// DeletePrice godoc
+//
+//	@Tags			product
+//	@Summary		Delete a product price in a currency
+//	@Description	Delete a price list entry so the converted base price is used again
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id			path		int		true	"Product ID"
+//	@Param			currency	path		string	true	"Currency code"
+//	@Success		200			{object}	util.ApiResponse{}
+//	@Failure		400			{object}	util.ApiResponse{}
+//	@Failure		500			{object}	util.ApiResponse{}
+//	@Router			/product/{id}/price/{currency} [delete]
+func (h *ProductHandler) DeletePrice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid product id"
+		util.WriteJson(w, response)
+		return
+	}
+	err = h.CurrencyService.DeleteProductPrice(uint(id), r.PathValue("currency"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Price not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while deleting price"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// GetPriceHistory godoc
+//
+//	@Tags			product
+//	@Summary		Show the price history of a product
+//	@Description	get the prices a product had in the base currency, newest first
+//	@Produce		json
+//	@Param			id	path		int	true	"Product ID"
+//	@Success		200	{object}	util.ApiResponse{data=[]model.PriceHistory}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/product/{id}/price/history [get]
+func (h *ProductHandler) GetPriceHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid product id"
+		util.WriteJson(w, response)
+		return
+	}
+	history, err := h.PriceService.History(uint(id))
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting price history"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = history
+	util.WriteJson(w, response)
+}
+
+// GetScheduledPrices godoc
+//
+//	@Tags			product
+//	@Summary		Show the scheduled prices of a product
+//	@Description	get the pending, active and past scheduled prices of a product
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Product ID"
+//	@Success		200	{object}	util.ApiResponse{data=[]model.ScheduledPrice}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/product/{id}/price/schedule [get]
+func (h *ProductHandler) GetScheduledPrices(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid product id"
+		util.WriteJson(w, response)
+		return
+	}
+	schedules, err := h.PriceService.Schedules(uint(id))
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting scheduled prices"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = schedules
+	util.WriteJson(w, response)
+}
+
+// SchedulePrice godoc
+//
+//	@Tags			product
+//	@Summary		Schedule a product price
+//	@Description	Put a product on a price from starts_at until ends_at, after which the price it had comes back. Scheduled prices of a product can't overlap
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			price	body		dto.ScheduledPriceCreateDto	true	"Scheduled Price"
+//	@Success		201		{object}	util.ApiResponse{data=model.ScheduledPrice}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/product/price/schedule [post]
+func (h *ProductHandler) SchedulePrice(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.ScheduledPriceCreateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	schedule := model.ScheduledPrice{
+		ProductID: data.ProductID,
+		Price:     data.Price,
+		StartsAt:  data.StartsAt,
+		EndsAt:    data.EndsAt,
+	}
+	schedule, err = h.PriceService.Schedule(schedule)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Product not found"
+			util.WriteJson(w, response)
+			return
+		}
+		if errors.Is(err, util.ScheduledPriceWindowError) || errors.Is(err, util.ScheduledPriceOverlapError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while scheduling price"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusCreated
+	response.Message = "Price scheduled successfully."
+	response.Data = schedule
+	util.WriteJson(w, response)
+}
+
+// CancelScheduledPrice godoc
+//
+//	@Tags			product
+//	@Summary		Cancel a scheduled price
+//	@Description	Cancel a scheduled price. A price the product is already on ends now and the price it had comes back
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Scheduled Price ID"
+//	@Success		200	{object}	util.ApiResponse{}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/product/price/schedule/{id} [delete]
+func (h *ProductHandler) CancelScheduledPrice(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid scheduled price id"
+		util.WriteJson(w, response)
+		return
+	}
+	err = h.PriceService.Cancel(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Scheduled price not found"
+			util.WriteJson(w, response)
+			return
+		}
+		if errors.Is(err, util.ScheduledPriceFinishedError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while cancelling scheduled price"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// productFilter reads the listing filter from the query. It writes the error
+// response and returns false when the query is invalid.
+func productFilter(w http.ResponseWriter, r *http.Request) (storage.ProductFilter, bool) {
+	var response util.ApiResponse
+	var filter storage.ProductFilter
+	query := r.URL.Query()
+	if value := query.Get("min_rating"); value != "" {
+		rating, err := strconv.ParseFloat(value, 64)
+		if err != nil || rating < 0 || rating > 5 {
+			response.Status = http.StatusBadRequest
+			response.Message = "Minimum rating must be between 0 and 5"
+			util.WriteJson(w, response)
+			return filter, false
+		}
+		filter.MinRating = rating
+	}
+	attributes, ok := attributeConditions(w, r)
+	if !ok {
+		return filter, false
+	}
+	filter.Attributes = attributes
+	filter.Sort = query.Get("sort")
+	if filter.Sort != "" && filter.Sort != storage.PRODUCT_SORT_RATING && filter.Sort != storage.PRODUCT_SORT_REVIEWS {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid sort"
+		util.WriteJson(w, response)
+		return filter, false
+	}
+	return filter, true
+}
+
+// categoryFilter narrows the product listing down to the category of the
+// query, and to the categories below it when include_descendants is set. It
+// writes the error response and returns false when the category is invalid.
+func (h ProductHandler) categoryFilter(w http.ResponseWriter, r *http.Request, filter *storage.ProductFilter) bool {
+	var response util.ApiResponse
+	query := r.URL.Query()
+	if query.Get("category_id") == "" {
+		return true
+	}
+	id, err := strconv.Atoi(query.Get("category_id"))
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid category id"
+		util.WriteJson(w, response)
+		return false
+	}
+	descendants, _ := strconv.ParseBool(query.Get("include_descendants"))
+
+	ids, err := h.CategoryService.Descendants(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Category not found"
+			util.WriteJson(w, response)
+			return false
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting category"
+		util.WriteJson(w, response)
+		return false
+	}
+	if !descendants {
+		ids = ids[:1]
+	}
+	filter.CategoryIDs = ids
+	return true
+}
+
+// redirectToSlug permanently redirects a lookup by an old slug to the current
+// slug, keeping the query.
+func redirectToSlug(w http.ResponseWriter, r *http.Request, prefix string, slug string) {
+	location := prefix + url.PathEscape(slug)
+	if r.URL.RawQuery != "" {
+		location += "?" + r.URL.RawQuery
+	}
+	http.Redirect(w, r, location, http.StatusMovedPermanently)
+}
+
+// productAttributes maps the attribute values of the body. It's nil when no
+// attributes were given, so the product keeps its values.
+func productAttributes(data []dto.ProductAttributeDto) []model.ProductAttributeValue {
+	if data == nil {
+		return nil
+	}
+	values := []model.ProductAttributeValue{}
+	for _, value := range data {
+		values = append(values, model.ProductAttributeValue{AttributeID: value.AttributeID, Value: value.Value})
+	}
+	return values
+}
+
+// attributeConditions reads the attribute filters of the query:
+// attr_<id>=<value>[,<value>...] keeps the products with one of the values and
+// attr_<id>_min / attr_<id>_max bound a number attribute. It writes the error
+// response and returns false when a filter is invalid.
+func attributeConditions(w http.ResponseWriter, r *http.Request) ([]storage.AttributeCondition, bool) {
+	var response util.ApiResponse
+	conditions := map[uint]*storage.AttributeCondition{}
+	ids := []uint{}
+	for key, values := range r.URL.Query() {
+		name, found := strings.CutPrefix(key, "attr_")
+		if !found || len(values) == 0 {
+			continue
+		}
+		bound := ""
+		if id, cut := strings.CutSuffix(name, "_min"); cut {
+			name, bound = id, "min"
+		} else if id, cut := strings.CutSuffix(name, "_max"); cut {
+			name, bound = id, "max"
+		}
+		id, err := strconv.Atoi(name)
+		if err != nil || id < 1 {
+			response.Status = http.StatusBadRequest
+			response.Message = "Invalid attribute filter " + key
+			util.WriteJson(w, response)
+			return nil, false
+		}
+		condition, ok := conditions[uint(id)]
+		if !ok {
+			condition = &storage.AttributeCondition{AttributeID: uint(id)}
+			conditions[uint(id)] = condition
+			ids = append(ids, uint(id))
+		}
+		if bound == "" {
+			for _, value := range strings.Split(values[0], ",") {
+				if value = strings.TrimSpace(value); value != "" {
+					condition.Values = append(condition.Values, value)
+				}
+			}
+			continue
+		}
+		number, err := strconv.ParseFloat(values[0], 64)
+		if err != nil {
+			response.Status = http.StatusBadRequest
+			response.Message = "Invalid attribute filter " + key
+			util.WriteJson(w, response)
+			return nil, false
+		}
+		if bound == "min" {
+			condition.Min = &number
+		} else {
+			condition.Max = &number
+		}
+	}
+
+	slices.Sort(ids)
+	result := []storage.AttributeCondition{}
+	for _, id := range ids {
+		result = append(result, *conditions[id])
+	}
+	return result, true
+}
@@ -0,0 +1,357 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/fatihesergg/go_ecommerce/internal/dto"
+	"github.com/fatihesergg/go_ecommerce/internal/model"
+	"github.com/fatihesergg/go_ecommerce/internal/service"
+	"github.com/fatihesergg/go_ecommerce/internal/util"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+type PromotionHandler struct {
+	PromotionService service.PromotionService
+	ProductService   service.ProductService
+	CategoryService  service.CategoryService
+	Validator        *validator.Validate
+}
+
+func NewPromotionHandler(promotionService service.PromotionService, productService service.ProductService, categoryService service.CategoryService, validator *validator.Validate) PromotionHandler {
+	return PromotionHandler{PromotionService: promotionService, ProductService: productService, CategoryService: categoryService, Validator: validator}
+}
+
+// Get godoc
+//
+//	@Tags			promotion
+//	@Summary		Show a promotion
+//	@Description	get promotion by ID
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Promotion ID"
+//	@Success		200	{object}	util.ApiResponse{data=model.Promotion}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/promotion/{id} [get]
+func (h *PromotionHandler) Get(w http.ResponseWriter, r *http.Request) {
+	_, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid promotion id"
+		util.WriteJson(w, response)
+		return
+	}
+	promotion, err := h.PromotionService.Get(r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Promotion not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting promotion"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = promotion
+	util.WriteJson(w, response)
+}
+
+// GetAll godoc
+//
+//	@Tags			promotion
+//	@Summary		Show all promotions
+//	@Description	get all promotions ordered by priority
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	util.ApiResponse{data=[]model.Promotion}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/promotion [get]
+func (h *PromotionHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	promotions, err := h.PromotionService.GetAll()
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting promotions"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = promotions
+	util.WriteJson(w, response)
+}
+
+// Create godoc
+//
+//	@Tags			promotion
+//	@Summary		Create a promotion
+//	@Description	Create a promotion
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			promotion	body		dto.PromotionCreateDto	true	"Create Promotion"
+//	@Success		200			{object}	util.ApiResponse{}
+//	@Failure		400			{object}	util.ApiResponse{}
+//	@Failure		500			{object}	util.ApiResponse{}
+//	@Router			/promotion [post]
+func (h *PromotionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.PromotionCreateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	promotion := model.Promotion{
+		Name:          data.Name,
+		Description:   data.Description,
+		Type:          model.PromotionType(data.Type),
+		Priority:      data.Priority,
+		Exclusive:     data.Exclusive,
+		Active:        data.Active,
+		StartsAt:      data.StartsAt,
+		EndsAt:        data.EndsAt,
+		BuyQuantity:   data.BuyQuantity,
+		GetQuantity:   data.GetQuantity,
+		DiscountType:  model.DiscountType(data.DiscountType),
+		DiscountValue: data.DiscountValue,
+		CategoryID:    data.CategoryID,
+		Tiers:         promotionTiers(data.Tiers),
+	}
+	if !h.checkPromotion(w, &promotion, data.ProductIDs) {
+		return
+	}
+
+	err = h.PromotionService.Create(promotion)
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while creating promotion"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusCreated
+	response.Message = "Promotion created successfully."
+	util.WriteJson(w, response)
+}
+
+// Update godoc
+//
+//	@Tags			promotion
+//	@Summary		Update a promotion
+//	@Description	Update a promotion
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			promotion	body		dto.PromotionUpdateDto	true	"Update Promotion"
+//	@Success		200			{object}	util.ApiResponse{}
+//	@Failure		400			{object}	util.ApiResponse{}
+//	@Failure		500			{object}	util.ApiResponse{}
+//	@Router			/promotion [put]
+func (h *PromotionHandler) Update(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.PromotionUpdateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	promotion := model.Promotion{
+		ID:            uint(data.ID),
+		Name:          data.Name,
+		Description:   data.Description,
+		Type:          model.PromotionType(data.Type),
+		Priority:      data.Priority,
+		Exclusive:     data.Exclusive,
+		Active:        data.Active,
+		StartsAt:      data.StartsAt,
+		EndsAt:        data.EndsAt,
+		BuyQuantity:   data.BuyQuantity,
+		GetQuantity:   data.GetQuantity,
+		DiscountType:  model.DiscountType(data.DiscountType),
+		DiscountValue: data.DiscountValue,
+		CategoryID:    data.CategoryID,
+		Tiers:         promotionTiers(data.Tiers),
+	}
+	if !h.checkPromotion(w, &promotion, data.ProductIDs) {
+		return
+	}
+
+	err = h.PromotionService.Update(promotion)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Promotion not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while updating promotion"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// Delete godoc
+//
+//	@Tags			promotion
+//	@Summary		Delete a promotion
+//	@Description	Delete a promotion
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Promotion ID"
+//	@Success		200	{object}	util.ApiResponse{}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/promotion/{id} [delete]
+func (h *PromotionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	_, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid promotion id"
+		util.WriteJson(w, response)
+		return
+	}
+	err = h.PromotionService.Delete(r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Promotion not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while deleting promotion"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+func promotionTiers(data []dto.PromotionTierDto) []model.PromotionTier {
+	var tiers []model.PromotionTier
+	for _, tier := range data {
+		tiers = append(tiers, model.PromotionTier{MinAmount: tier.MinAmount, DiscountValue: tier.DiscountValue})
+	}
+	return tiers
+}
+
+// checkPromotion validates the rules required by the promotion type and loads
+// its products. It writes the error response and returns false when the
+// promotion is invalid.
+func (h *PromotionHandler) checkPromotion(w http.ResponseWriter, promotion *model.Promotion, productIDs []uint) bool {
+	var response util.ApiResponse
+	message := ""
+	switch promotion.Type {
+	case model.PROMOTION_BUY_X_GET_Y:
+		if promotion.BuyQuantity == 0 || promotion.GetQuantity == 0 {
+			message = "Buy and get quantities are required"
+		}
+	case model.PROMOTION_THRESHOLD:
+		if len(promotion.Tiers) == 0 {
+			message = "At least one tier is required"
+		} else if promotion.DiscountType == "" {
+			message = "Discount type is required"
+		}
+	case model.PROMOTION_CATEGORY_SALE:
+		if promotion.CategoryID == nil {
+			message = "Category is required"
+		} else if promotion.DiscountType == "" || promotion.DiscountValue <= 0 {
+			message = "Discount type and value are required"
+		}
+	case model.PROMOTION_BUNDLE:
+		if len(productIDs) < 2 {
+			message = "A bundle needs at least two products"
+		} else if promotion.DiscountType == "" || promotion.DiscountValue <= 0 {
+			message = "Discount type and value are required"
+		}
+	}
+	if message == "" && promotion.DiscountType == model.DISCOUNT_PERCENTAGE {
+		if promotion.DiscountValue > 100 {
+			message = "Percentage discount can't be more than 100"
+		}
+		for _, tier := range promotion.Tiers {
+			if tier.DiscountValue > 100 {
+				message = "Percentage discount can't be more than 100"
+			}
+		}
+	}
+	if message == "" && promotion.StartsAt != nil && promotion.EndsAt != nil && !promotion.EndsAt.After(*promotion.StartsAt) {
+		message = "Promotion must end after it starts"
+	}
+	if message != "" {
+		response.Status = http.StatusBadRequest
+		response.Message = message
+		util.WriteJson(w, response)
+		return false
+	}
+
+	if promotion.CategoryID != nil {
+		_, err := h.CategoryService.Get(strconv.Itoa(int(*promotion.CategoryID)))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				response.Status = http.StatusBadRequest
+				response.Message = "Invalid category id"
+				util.WriteJson(w, response)
+				return false
+			}
+			response.Status = http.StatusInternalServerError
+			response.Message = "Error while getting category"
+			util.WriteJson(w, response)
+			return false
+		}
+	}
+	if len(productIDs) > 0 {
+		products, err := h.ProductService.GetByIDs(productIDs)
+		if err != nil {
+			response.Status = http.StatusInternalServerError
+			response.Message = "Error while getting products"
+			util.WriteJson(w, response)
+			return false
+		}
+		if len(products) != len(productIDs) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Invalid product id"
+			util.WriteJson(w, response)
+			return false
+		}
+		promotion.Products = products
+	}
+	return true
+}
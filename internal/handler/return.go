@@ -0,0 +1,491 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/fatihesergg/go_ecommerce/internal/dto"
+	"github.com/fatihesergg/go_ecommerce/internal/middleware"
+	"github.com/fatihesergg/go_ecommerce/internal/model"
+	"github.com/fatihesergg/go_ecommerce/internal/service"
+	"github.com/fatihesergg/go_ecommerce/internal/util"
+	"github.com/go-playground/validator/v10"
+	"github.com/stripe/stripe-go/v81"
+	"gorm.io/gorm"
+)
+
+type ReturnHandler struct {
+	ReturnService  service.ReturnService
+	OrderService   service.OrderService
+	PaymentService service.PaymentService
+	Validator      *validator.Validate
+}
+
+func NewReturnHandler(returnService service.ReturnService, orderService service.OrderService, paymentService service.PaymentService, validator *validator.Validate) ReturnHandler {
+	return ReturnHandler{ReturnService: returnService, OrderService: orderService, PaymentService: paymentService, Validator: validator}
+}
+
+// Create godoc
+//
+//	@Tags			return
+//	@Summary		Request a return
+//	@Description	Ask to send back shipped items of an order of the logged in user
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			return	body		dto.ReturnCreateDto	true	"Create Return"
+//	@Success		200		{object}	util.ApiResponse{data=model.ReturnRequest}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/me/returns [post]
+func (h *ReturnHandler) Create(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.ReturnCreateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	userID := r.Context().Value(middleware.AuthUserID).(string)
+	order, err := h.OrderService.Get(strconv.Itoa(int(data.OrderID)))
+	if err == nil && userID != strconv.Itoa(int(order.UserID)) {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Order not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting order"
+		util.WriteJson(w, response)
+		return
+	}
+
+	request := model.ReturnRequest{Note: data.Note}
+	for _, item := range data.Items {
+		request.Items = append(request.Items, model.ReturnItem{OrderItemID: item.OrderItemID, Quantity: item.Quantity, Reason: item.Reason})
+	}
+	request, err = h.ReturnService.Create(order, request)
+	if err != nil {
+		if errors.Is(err, util.OrderNotReturnableError) || errors.Is(err, util.ReturnItemNotInOrderError) || errors.Is(err, util.ReturnQuantityError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while creating return"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusCreated
+	response.Message = "Return requested successfully."
+	response.Data = request
+	util.WriteJson(w, response)
+}
+
+// GetMine godoc
+//
+//	@Tags			return
+//	@Summary		Show my returns
+//	@Description	get the returns of the logged in user
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	util.ApiResponse{data=[]model.ReturnRequest}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/me/returns [get]
+func (h *ReturnHandler) GetMine(w http.ResponseWriter, r *http.Request) {
+	userID := r.Context().Value(middleware.AuthUserID).(string)
+	userIDint, _ := strconv.Atoi(userID)
+	var response util.ApiResponse
+	requests, err := h.ReturnService.GetByUser(uint(userIDint))
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting returns"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = requests
+	util.WriteJson(w, response)
+}
+
+// GetOwn godoc
+//
+//	@Tags			return
+//	@Summary		Show my return
+//	@Description	get a return of the logged in user with its history
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Return ID"
+//	@Success		200	{object}	util.ApiResponse{data=model.ReturnRequest}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/me/returns/{id} [get]
+func (h *ReturnHandler) GetOwn(w http.ResponseWriter, r *http.Request) {
+	request, ok := h.loadReturn(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	var response util.ApiResponse
+	userID := r.Context().Value(middleware.AuthUserID).(string)
+	if userID != strconv.Itoa(int(request.UserID)) {
+		response.Status = http.StatusBadRequest
+		response.Message = "Return not found"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = request
+	util.WriteJson(w, response)
+}
+
+// GetAll godoc
+//
+//	@Tags			return
+//	@Summary		Show all returns
+//	@Description	get all returns, newest first
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	util.ApiResponse{data=[]model.ReturnRequest}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/return [get]
+func (h *ReturnHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	requests, err := h.ReturnService.GetAll()
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting returns"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = requests
+	util.WriteJson(w, response)
+}
+
+// Get godoc
+//
+//	@Tags			return
+//	@Summary		Show a return
+//	@Description	get return by ID with its history
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Return ID"
+//	@Success		200	{object}	util.ApiResponse{data=model.ReturnRequest}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/return/{id} [get]
+func (h *ReturnHandler) Get(w http.ResponseWriter, r *http.Request) {
+	request, ok := h.loadReturn(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	var response util.ApiResponse
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = request
+	util.WriteJson(w, response)
+}
+
+// Approve godoc
+//
+//	@Tags			return
+//	@Summary		Approve a return
+//	@Description	Approve a requested return so the customer can send the items
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id		path		int					true	"Return ID"
+//	@Param			note	body		dto.ReturnActionDto	false	"Note"
+//	@Success		200		{object}	util.ApiResponse{}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/return/{id}/approve [post]
+func (h *ReturnHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, model.RETURN_REQUESTED, model.RETURN_APPROVED)
+}
+
+// Reject godoc
+//
+//	@Tags			return
+//	@Summary		Reject a return
+//	@Description	Reject a requested return
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id		path		int					true	"Return ID"
+//	@Param			note	body		dto.ReturnActionDto	false	"Note"
+//	@Success		200		{object}	util.ApiResponse{}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/return/{id}/reject [post]
+func (h *ReturnHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, model.RETURN_REQUESTED, model.RETURN_REJECTED)
+}
+
+// Receive godoc
+//
+//	@Tags			return
+//	@Summary		Receive a return
+//	@Description	Mark the items of an approved return as received in the warehouse
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id		path		int					true	"Return ID"
+//	@Param			note	body		dto.ReturnActionDto	false	"Note"
+//	@Success		200		{object}	util.ApiResponse{}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/return/{id}/receive [post]
+func (h *ReturnHandler) Receive(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, model.RETURN_APPROVED, model.RETURN_RECEIVED)
+}
+
+// Inspect godoc
+//
+//	@Tags			return
+//	@Summary		Inspect a return
+//	@Description	Record which received items are accepted and restocked, then refund the accepted items
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id			path		int						true	"Return ID"
+//	@Param			inspection	body		dto.ReturnInspectDto	true	"Inspection"
+//	@Success		200			{object}	util.ApiResponse{data=model.ReturnRequest}
+//	@Failure		400			{object}	util.ApiResponse{}
+//	@Failure		500			{object}	util.ApiResponse{}
+//	@Router			/return/{id}/inspect [post]
+func (h *ReturnHandler) Inspect(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.ReturnInspectDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	request, ok := h.loadReturn(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	order, err := h.OrderService.Get(strconv.Itoa(int(request.OrderID)))
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting order"
+		util.WriteJson(w, response)
+		return
+	}
+
+	outcomes := map[uint]service.ReturnOutcome{}
+	for _, item := range data.Items {
+		outcomes[item.ID] = service.ReturnOutcome{Accepted: item.Accepted, Restock: item.Restock}
+	}
+	request, err = h.ReturnService.Inspect(request, order, outcomes, actorID(r), data.Note)
+	if err != nil {
+		if errors.Is(err, util.ReturnStatusError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while inspecting return"
+		util.WriteJson(w, response)
+		return
+	}
+	if request.Status == model.RETURN_INSPECTED {
+		if !h.refund(w, r, &request) {
+			return
+		}
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = request
+	util.WriteJson(w, response)
+}
+
+// Refund godoc
+//
+//	@Tags			return
+//	@Summary		Refund a return
+//	@Description	Retry the refund of an inspected return
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Return ID"
+//	@Success		200	{object}	util.ApiResponse{data=model.ReturnRequest}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/return/{id}/refund [post]
+func (h *ReturnHandler) Refund(w http.ResponseWriter, r *http.Request) {
+	request, ok := h.loadReturn(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	if !h.refund(w, r, &request) {
+		return
+	}
+	var response util.ApiResponse
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = request
+	util.WriteJson(w, response)
+}
+
+// refund pays back the refund amount of an inspected return. The return is
+// claimed first so concurrent requests can't refund it twice, and moved back
+// to inspected when the refund fails. It writes the error response and
+// returns false when the return isn't refunded.
+func (h *ReturnHandler) refund(w http.ResponseWriter, r *http.Request, request *model.ReturnRequest) bool {
+	var response util.ApiResponse
+	STRIPE_API := os.Getenv("STRIPE_API")
+	if STRIPE_API == "" {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Something went wrong"
+		util.WriteJson(w, response)
+		return false
+	}
+	stripe.Key = STRIPE_API
+
+	payment, err := h.PaymentService.GetByOrder(request.OrderID)
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting payment"
+		util.WriteJson(w, response)
+		return false
+	}
+
+	err = h.ReturnService.Transition(request.ID, model.RETURN_INSPECTED, model.RETURN_REFUNDED, actorID(r), "Refund issued")
+	if err != nil {
+		if errors.Is(err, util.ReturnStatusError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return false
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while updating return"
+		util.WriteJson(w, response)
+		return false
+	}
+
+	_, err = h.PaymentService.Refund(payment, request.RefundAmount, &request.ID)
+	if errors.Is(err, util.RefundNotRecordedError) {
+		// The money is back with the customer, so the return stays refunded.
+		middleware.LOGGER.Errorw("Error while recording refund", "return", request.ID, "error", err)
+		err = nil
+	}
+	if err != nil {
+		h.ReturnService.Transition(request.ID, model.RETURN_REFUNDED, model.RETURN_INSPECTED, actorID(r), "Refund failed")
+		if errors.Is(err, util.RefundLimitError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return false
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while refunding payment"
+		util.WriteJson(w, response)
+		return false
+	}
+	request.Status = model.RETURN_REFUNDED
+	return true
+}
+
+// transition moves the return of the request between two statuses with the
+// optional note of the body.
+func (h *ReturnHandler) transition(w http.ResponseWriter, r *http.Request, from model.ReturnStatus, to model.ReturnStatus) {
+	defer r.Body.Close()
+	var data dto.ReturnActionDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil && !errors.Is(err, io.EOF) {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+
+	request, ok := h.loadReturn(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	err := h.ReturnService.Transition(request.ID, from, to, actorID(r), data.Note)
+	if err != nil {
+		if errors.Is(err, util.ReturnStatusError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while updating return"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// loadReturn writes the error response and returns false when the return
+// can't be loaded.
+func (h *ReturnHandler) loadReturn(w http.ResponseWriter, id string) (model.ReturnRequest, bool) {
+	var response util.ApiResponse
+	if _, err := strconv.Atoi(id); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid return id"
+		util.WriteJson(w, response)
+		return model.ReturnRequest{}, false
+	}
+	request, err := h.ReturnService.Get(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Return not found"
+			util.WriteJson(w, response)
+			return model.ReturnRequest{}, false
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting return"
+		util.WriteJson(w, response)
+		return model.ReturnRequest{}, false
+	}
+	return request, true
+}
+
+func actorID(r *http.Request) uint {
+	userID, _ := r.Context().Value(middleware.AuthUserID).(string)
+	id, _ := strconv.Atoi(userID)
+	return uint(id)
+}
@@ -4,13 +4,18 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
+	"unicode/utf8"
 
 	"github.com/fatihesergg/go_ecommerce/internal/dto"
 	"github.com/fatihesergg/go_ecommerce/internal/middleware"
 	"github.com/fatihesergg/go_ecommerce/internal/model"
 	"github.com/fatihesergg/go_ecommerce/internal/service"
+	"github.com/fatihesergg/go_ecommerce/internal/storage"
 	"github.com/fatihesergg/go_ecommerce/internal/util"
 	"github.com/go-playground/validator/v10"
 	"gorm.io/gorm"
@@ -31,39 +36,88 @@ func NewReviewHandler(review service.ReviewService, user service.UserService, pr
 //
 //	@Tags			review
 //	@Summary		Show a review
-//	@Description	get review by ID
+//	@Description	get an approved review by ID
 //	@Produce		json
 //	@Param			id	path		int	true	"Review ID"
-//	@Success		200	{object}	util.ApiResponse{data=model.Review}
+//	@Success		200	{object}	util.ApiResponse{data=dto.ReviewResponseDto}
 //	@Failure		400	{object}	util.ApiResponse{}
 //	@Failure		500	{object}	util.ApiResponse{}
 //	@Router			/review/{id} [get]
 func (h *ReviewHandler) Get(w http.ResponseWriter, r *http.Request) {
+	review, ok := h.approvedReview(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	var response util.ApiResponse
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = reviewResponse(review)
+	util.WriteJson(w, response)
+}
+
+// GetByProduct godoc
+//
+//	@Tags			review
+//	@Summary		Show the reviews of a product
+//	@Description	get a page of the approved reviews of a product
+//	@Produce		json
+//	@Param			id			path		int		true	"Product ID"
+//	@Param			page		query		int		false	"Page number"	default(1)
+//	@Param			page_size	query		int		false	"Page size"		default(10)
+//	@Param			sort		query		string	false	"Sort order"	Enums(newest, highest, lowest, helpful)
+//	@Param			rating		query		int		false	"Only reviews with this many stars"
+//	@Success		200			{object}	util.ApiResponse{data=dto.ReviewPageDto}
+//	@Failure		400			{object}	util.ApiResponse{}
+//	@Failure		500			{object}	util.ApiResponse{}
+//	@Router			/product/{id}/reviews [get]
+func (h *ReviewHandler) GetByProduct(w http.ResponseWriter, r *http.Request) {
 	_, err := strconv.Atoi(r.PathValue("id"))
 	var response util.ApiResponse
 	if err != nil {
 		response.Status = http.StatusBadRequest
-		response.Message = "Invalid review id"
+		response.Message = "Invalid product id"
 		util.WriteJson(w, response)
 		return
 	}
+	filter, ok := reviewFilter(w, r)
+	if !ok {
+		return
+	}
 
-	review, err := h.ReviewService.Get(r.PathValue("id"))
+	product, err := h.ProductService.Get(r.PathValue("id"))
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			response.Status = http.StatusBadRequest
-			response.Message = "Review not found"
+			response.Message = "Product not found"
 			util.WriteJson(w, response)
 			return
 		}
 		response.Status = http.StatusInternalServerError
-		response.Message = "Error while getting review"
+		response.Message = "Error while getting product"
 		util.WriteJson(w, response)
 		return
 	}
+	reviews, total, err := h.ReviewService.GetByProduct(product.ID, filter)
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting reviews"
+		util.WriteJson(w, response)
+		return
+	}
+
+	page := dto.ReviewPageDto{
+		Reviews:    []dto.ReviewResponseDto{},
+		Page:       filter.Page,
+		PageSize:   filter.PageSize,
+		Total:      total,
+		TotalPages: totalPages(total, filter.PageSize),
+	}
+	for _, review := range reviews {
+		page.Reviews = append(page.Reviews, reviewResponse(review))
+	}
 	response.Status = http.StatusOK
 	response.Message = "Success"
-	response.Data = review
+	response.Data = page
 	util.WriteJson(w, response)
 }
 
@@ -127,9 +181,16 @@ func (h *ReviewHandler) Create(w http.ResponseWriter, r *http.Request) {
 		UserID:    user.ID,
 		User:      user,
 		Comment:   data.Comment,
+		Rating:    data.Rating,
 	}
 	err = h.ReviewService.Create(review)
 	if err != nil {
+		if errors.Is(err, util.ReviewExistsError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
 		response.Status = http.StatusInternalServerError
 		response.Message = "Error while creating review"
 		util.WriteJson(w, response)
@@ -198,12 +259,9 @@ func (h *ReviewHandler) Update(w http.ResponseWriter, r *http.Request) {
 	}
 
 	review := model.Review{
-		ID:        uint(data.ID),
-		Comment:   data.Comment,
-		UserID:    exist.UserID,
-		User:      exist.User,
-		ProductID: uint(data.ProductID),
-		Product:   exist.Product,
+		ID:      uint(data.ID),
+		Comment: data.Comment,
+		Rating:  data.Rating,
 	}
 
 	err = h.ReviewService.Update(review)
@@ -279,3 +337,613 @@ func (h *ReviewHandler) Delete(w http.ResponseWriter, r *http.Request) {
 	response.Message = "Success"
 	util.WriteJson(w, response)
 }
+
+// Vote godoc
+//
+//	@Tags			review
+//	@Summary		Vote on a review
+//	@Description	Mark a review as helpful or unhelpful. A new vote replaces the earlier vote of the user
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id		path		int					true	"Review ID"
+//	@Param			vote	body		dto.ReviewVoteDto	true	"Vote"
+//	@Success		200		{object}	util.ApiResponse{}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/review/{id}/vote [post]
+func (h *ReviewHandler) Vote(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.ReviewVoteDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	if err := h.Validator.Struct(data); err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	review, ok := h.approvedReview(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	err := h.ReviewService.Vote(review, actorID(r), *data.Helpful)
+	if err != nil {
+		if errors.Is(err, util.ReviewOwnVoteError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while voting on review"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// Unvote godoc
+//
+//	@Tags			review
+//	@Summary		Remove a vote
+//	@Description	Remove the vote of the logged in user on a review
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Review ID"
+//	@Success		200	{object}	util.ApiResponse{}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/review/{id}/vote [delete]
+func (h *ReviewHandler) Unvote(w http.ResponseWriter, r *http.Request) {
+	review, ok := h.approvedReview(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	var response util.ApiResponse
+	err := h.ReviewService.Unvote(review, actorID(r))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Vote not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while removing vote"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// Report godoc
+//
+//	@Tags			review
+//	@Summary		Report a review
+//	@Description	Report an abusive review. Reviews with enough reports go back to moderation
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id		path		int					true	"Review ID"
+//	@Param			report	body		dto.ReviewReportDto	true	"Report"
+//	@Success		200		{object}	util.ApiResponse{}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/review/{id}/report [post]
+func (h *ReviewHandler) Report(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.ReviewReportDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	if err := h.Validator.Struct(data); err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	review, ok := h.approvedReview(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	report := model.ReviewReport{UserID: actorID(r), Reason: data.Reason, Note: data.Note}
+	err := h.ReviewService.Report(review, report)
+	if err != nil {
+		if errors.Is(err, util.ReviewOwnVoteError) || errors.Is(err, util.ReviewReportedError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while reporting review"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Review reported successfully."
+	util.WriteJson(w, response)
+}
+
+// approvedReview loads a published review. It writes the error response and
+// returns false when the review can't be used.
+func (h *ReviewHandler) approvedReview(w http.ResponseWriter, id string) (model.Review, bool) {
+	review, ok := h.loadReview(w, id)
+	if ok && review.Status != model.REVIEW_APPROVED {
+		var response util.ApiResponse
+		response.Status = http.StatusBadRequest
+		response.Message = "Review not found"
+		util.WriteJson(w, response)
+		return model.Review{}, false
+	}
+	return review, ok
+}
+
+// loadReview writes the error response and returns false when the review
+// can't be loaded.
+func (h *ReviewHandler) loadReview(w http.ResponseWriter, id string) (model.Review, bool) {
+	var response util.ApiResponse
+	if _, err := strconv.Atoi(id); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid review id"
+		util.WriteJson(w, response)
+		return model.Review{}, false
+	}
+	review, err := h.ReviewService.Get(id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Review not found"
+			util.WriteJson(w, response)
+			return model.Review{}, false
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting review"
+		util.WriteJson(w, response)
+		return model.Review{}, false
+	}
+	return review, true
+}
+
+// CreateReply godoc
+//
+//	@Tags			review
+//	@Summary		Reply to a review
+//	@Description	Post the public reply of the store to a review and notify the reviewer
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id		path		int					true	"Review ID"
+//	@Param			reply	body		dto.ReviewReplyDto	true	"Reply"
+//	@Success		200		{object}	util.ApiResponse{data=model.ReviewReply}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/review/{id}/reply [post]
+func (h *ReviewHandler) CreateReply(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.ReviewReplyDto
+	var response util.ApiResponse
+	if !h.decodeReply(w, r, &data) {
+		return
+	}
+	review, ok := h.loadReview(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	reply, err := h.ReviewService.Reply(review, actorID(r), data.Body)
+	if err != nil {
+		if errors.Is(err, util.ReviewReplyExistsError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while creating reply"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusCreated
+	response.Message = "Reply created successfully."
+	response.Data = reply
+	util.WriteJson(w, response)
+}
+
+// UpdateReply godoc
+//
+//	@Tags			review
+//	@Summary		Update a reply
+//	@Description	Update the reply of the store to a review
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id		path		int					true	"Review ID"
+//	@Param			reply	body		dto.ReviewReplyDto	true	"Reply"
+//	@Success		200		{object}	util.ApiResponse{data=model.ReviewReply}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/review/{id}/reply [put]
+func (h *ReviewHandler) UpdateReply(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.ReviewReplyDto
+	var response util.ApiResponse
+	if !h.decodeReply(w, r, &data) {
+		return
+	}
+	review, ok := h.loadReview(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	reply, err := h.ReviewService.UpdateReply(review, actorID(r), data.Body)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Reply not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while updating reply"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = reply
+	util.WriteJson(w, response)
+}
+
+// DeleteReply godoc
+//
+//	@Tags			review
+//	@Summary		Delete a reply
+//	@Description	Delete the reply of the store to a review
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Review ID"
+//	@Success		200	{object}	util.ApiResponse{}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/review/{id}/reply [delete]
+func (h *ReviewHandler) DeleteReply(w http.ResponseWriter, r *http.Request) {
+	review, ok := h.loadReview(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	var response util.ApiResponse
+	err := h.ReviewService.DeleteReply(review)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Reply not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while deleting reply"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// decodeReply reads and validates the reply of the body. It writes the error
+// response and returns false when the body is invalid.
+func (h *ReviewHandler) decodeReply(w http.ResponseWriter, r *http.Request, data *dto.ReviewReplyDto) bool {
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return false
+	}
+	if err := h.Validator.Struct(data); err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return false
+	}
+	return true
+}
+
+// GetPending godoc
+//
+//	@Tags			review
+//	@Summary		Show the moderation queue
+//	@Description	get the reviews waiting for a moderator, oldest first
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	util.ApiResponse{data=[]model.Review}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/review/pending [get]
+func (h *ReviewHandler) GetPending(w http.ResponseWriter, r *http.Request) {
+	reviews, err := h.ReviewService.GetByStatus(model.REVIEW_PENDING)
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting reviews"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = reviews
+	util.WriteJson(w, response)
+}
+
+// Approve godoc
+//
+//	@Tags			review
+//	@Summary		Approve a review
+//	@Description	Publish a review and count it in the rating of its product
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id		path		int						true	"Review ID"
+//	@Param			reason	body		dto.ReviewModerationDto	false	"Reason"
+//	@Success		200		{object}	util.ApiResponse{}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/review/{id}/approve [post]
+func (h *ReviewHandler) Approve(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, model.REVIEW_APPROVED)
+}
+
+// Reject godoc
+//
+//	@Tags			review
+//	@Summary		Reject a review
+//	@Description	Hide a review from the product page and its rating
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id		path		int						true	"Review ID"
+//	@Param			reason	body		dto.ReviewModerationDto	true	"Reason"
+//	@Success		200		{object}	util.ApiResponse{}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/review/{id}/reject [post]
+func (h *ReviewHandler) Reject(w http.ResponseWriter, r *http.Request) {
+	h.moderate(w, r, model.REVIEW_REJECTED)
+}
+
+// moderate sets the status of the review with the reason of the body. A
+// rejection needs a reason.
+func (h *ReviewHandler) moderate(w http.ResponseWriter, r *http.Request, status model.ReviewStatus) {
+	defer r.Body.Close()
+	var data dto.ReviewModerationDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil && !errors.Is(err, io.EOF) {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	if err := h.Validator.Struct(data); err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+	if status == model.REVIEW_REJECTED && strings.TrimSpace(data.Reason) == "" {
+		response.Status = http.StatusBadRequest
+		response.Message = "A reason is required to reject a review"
+		util.WriteJson(w, response)
+		return
+	}
+
+	review, ok := h.loadReview(w, r.PathValue("id"))
+	if !ok {
+		return
+	}
+	err := h.ReviewService.Moderate(review, status, data.Reason)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Review not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while moderating review"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// GetDeleted godoc
+//
+//	@Tags			review
+//	@Summary		Show deleted reviews
+//	@Description	get the deleted reviews, most recently deleted first
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	util.ApiResponse{data=[]model.Review}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/review/deleted [get]
+func (h *ReviewHandler) GetDeleted(w http.ResponseWriter, r *http.Request) {
+	reviews, err := h.ReviewService.GetDeleted()
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting reviews"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = reviews
+	util.WriteJson(w, response)
+}
+
+// Restore godoc
+//
+//	@Tags			review
+//	@Summary		Restore a review
+//	@Description	Bring back a deleted review with its votes, reports and reply
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Review ID"
+//	@Success		200	{object}	util.ApiResponse{data=model.Review}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/review/{id}/restore [post]
+func (h *ReviewHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid review id"
+		util.WriteJson(w, response)
+		return
+	}
+	review, err := h.ReviewService.Restore(uint(id))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Deleted review not found"
+			util.WriteJson(w, response)
+			return
+		}
+		if errors.Is(err, util.ReviewExistsError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while restoring review"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = review
+	util.WriteJson(w, response)
+}
+
+const (
+	defaultReviewPageSize = 10
+	maxReviewPageSize     = 50
+)
+
+// reviewFilter reads the page, sort and rating of the review listing from the
+// query. It writes the error response and returns false when the query is
+// invalid.
+func reviewFilter(w http.ResponseWriter, r *http.Request) (storage.ReviewFilter, bool) {
+	var response util.ApiResponse
+	filter := storage.ReviewFilter{Page: 1, PageSize: defaultReviewPageSize, Sort: storage.REVIEW_SORT_NEWEST}
+	query := r.URL.Query()
+	var message string
+	filter.Page, filter.PageSize, message = pageQuery(query, defaultReviewPageSize, maxReviewPageSize)
+	if value := query.Get("rating"); value != "" {
+		rating, err := strconv.Atoi(value)
+		if err != nil || rating < 1 || rating > 5 {
+			message = "Rating must be between 1 and 5"
+		}
+		filter.Rating = rating
+	}
+	if value := query.Get("sort"); value != "" {
+		switch value {
+		case storage.REVIEW_SORT_NEWEST, storage.REVIEW_SORT_HIGHEST, storage.REVIEW_SORT_LOWEST, storage.REVIEW_SORT_HELPFUL:
+			filter.Sort = value
+		default:
+			message = "Invalid sort"
+		}
+	}
+	if message != "" {
+		response.Status = http.StatusBadRequest
+		response.Message = message
+		util.WriteJson(w, response)
+		return filter, false
+	}
+	return filter, true
+}
+
+// pageQuery reads the page and page_size of a listing from the query. The
+// message is empty unless one of them is invalid.
+func pageQuery(query url.Values, defaultSize int, maxSize int) (int, int, string) {
+	page, size, message := 1, defaultSize, ""
+	if value := query.Get("page"); value != "" {
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 1 {
+			message = "Page must be a positive number"
+		}
+		page = number
+	}
+	if value := query.Get("page_size"); value != "" {
+		number, err := strconv.Atoi(value)
+		if err != nil || number < 1 || number > maxSize {
+			message = fmt.Sprintf("Page size must be between 1 and %d", maxSize)
+		}
+		size = number
+	}
+	return page, size, message
+}
+
+// totalPages returns how many pages of the given size the total fills.
+func totalPages(total int64, size int) int {
+	return int((total + int64(size) - 1) / int64(size))
+}
+
+func reviewResponse(review model.Review) dto.ReviewResponseDto {
+	response := dto.ReviewResponseDto{
+		ID:               review.ID,
+		Comment:          review.Comment,
+		Rating:           review.Rating,
+		HelpfulCount:     review.HelpfulCount,
+		UnhelpfulCount:   review.UnhelpfulCount,
+		VerifiedPurchase: review.VerifiedPurchase,
+		ProductID:        review.ProductID,
+		UserID:           review.UserID,
+		ReviewerName:     reviewerName(review.User),
+		CreatedAt:        review.CreatedAt,
+		UpdatedAt:        review.UpdatedAt,
+	}
+	if review.Reply != nil {
+		response.Reply = &dto.ReviewReplyResponseDto{
+			Body:      review.Reply.Body,
+			CreatedAt: review.Reply.CreatedAt,
+			UpdatedAt: review.Reply.UpdatedAt,
+		}
+	}
+	return response
+}
+
+// reviewerName shows the first name and the initial of the last name of a
+// reviewer, falling back to the user name.
+func reviewerName(user model.User) string {
+	if user.Name == "" {
+		return user.UserName
+	}
+	name := user.Name
+	if initial, _ := utf8.DecodeRuneInString(user.LastName); initial != utf8.RuneError {
+		name += " " + string(initial) + "."
+	}
+	return name
+}
@@ -0,0 +1,260 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/fatihesergg/go_ecommerce/internal/dto"
+	"github.com/fatihesergg/go_ecommerce/internal/middleware"
+	"github.com/fatihesergg/go_ecommerce/internal/model"
+	"github.com/fatihesergg/go_ecommerce/internal/service"
+	"github.com/fatihesergg/go_ecommerce/internal/util"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+type ShipmentHandler struct {
+	ShipmentService service.ShipmentService
+	OrderService    service.OrderService
+	Validator       *validator.Validate
+}
+
+func NewShipmentHandler(shipmentService service.ShipmentService, orderService service.OrderService, validator *validator.Validate) ShipmentHandler {
+	return ShipmentHandler{ShipmentService: shipmentService, OrderService: orderService, Validator: validator}
+}
+
+// Get godoc
+//
+//	@Tags			shipment
+//	@Summary		Show a shipment
+//	@Description	get shipment by ID
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Shipment ID"
+//	@Success		200	{object}	util.ApiResponse{data=model.Shipment}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/shipment/{id} [get]
+func (h *ShipmentHandler) Get(w http.ResponseWriter, r *http.Request) {
+	_, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid shipment id"
+		util.WriteJson(w, response)
+		return
+	}
+	shipment, err := h.ShipmentService.Get(r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Shipment not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting shipment"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = shipment
+	util.WriteJson(w, response)
+}
+
+// Create godoc
+//
+//	@Tags			shipment
+//	@Summary		Ship items of an order
+//	@Description	Create a shipment for some or all of the items left to ship in a paid order
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			shipment	body		dto.ShipmentCreateDto	true	"Create Shipment"
+//	@Success		200			{object}	util.ApiResponse{data=model.Shipment}
+//	@Failure		400			{object}	util.ApiResponse{}
+//	@Failure		500			{object}	util.ApiResponse{}
+//	@Router			/shipment [post]
+func (h *ShipmentHandler) Create(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.ShipmentCreateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	order, err := h.OrderService.Get(strconv.Itoa(int(data.OrderID)))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Order not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting order"
+		util.WriteJson(w, response)
+		return
+	}
+
+	shipment := model.Shipment{
+		Carrier:        data.Carrier,
+		TrackingNumber: data.TrackingNumber,
+		TrackingURL:    data.TrackingURL,
+	}
+	for _, item := range data.Items {
+		shipment.Items = append(shipment.Items, model.ShipmentItem{OrderItemID: item.OrderItemID, Quantity: item.Quantity})
+	}
+	shipment, err = h.ShipmentService.Create(order, shipment)
+	if err != nil {
+		if errors.Is(err, util.OrderNotShippableError) || errors.Is(err, util.ShipmentQuantityError) || errors.Is(err, util.ShipmentItemNotInOrderError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while creating shipment"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusCreated
+	response.Message = "Shipment created successfully."
+	response.Data = shipment
+	util.WriteJson(w, response)
+}
+
+// Update godoc
+//
+//	@Tags			shipment
+//	@Summary		Update a shipment
+//	@Description	Update the carrier details and status of a shipment
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			shipment	body		dto.ShipmentUpdateDto	true	"Update Shipment"
+//	@Success		200			{object}	util.ApiResponse{data=model.Shipment}
+//	@Failure		400			{object}	util.ApiResponse{}
+//	@Failure		500			{object}	util.ApiResponse{}
+//	@Router			/shipment [put]
+func (h *ShipmentHandler) Update(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.ShipmentUpdateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	shipment := model.Shipment{
+		ID:             uint(data.ID),
+		Carrier:        data.Carrier,
+		TrackingNumber: data.TrackingNumber,
+		TrackingURL:    data.TrackingURL,
+		Status:         model.ShipmentStatus(data.Status),
+	}
+	shipment, err = h.ShipmentService.Update(shipment)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Shipment not found"
+			util.WriteJson(w, response)
+			return
+		}
+		if errors.Is(err, util.ShipmentDeliveredError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while updating shipment"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = shipment
+	util.WriteJson(w, response)
+}
+
+// Tracking godoc
+//
+//	@Tags			shipment
+//	@Summary		Track an order
+//	@Description	Show the status and shipments of an order of the logged in user
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Order ID"
+//	@Success		200	{object}	util.ApiResponse{data=dto.OrderTrackingDto}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/order/{id}/tracking [get]
+func (h *ShipmentHandler) Tracking(w http.ResponseWriter, r *http.Request) {
+	_, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid order id"
+		util.WriteJson(w, response)
+		return
+	}
+	order, err := h.OrderService.Get(r.PathValue("id"))
+	userID := r.Context().Value(middleware.AuthUserID).(string)
+	if err == nil && userID != strconv.Itoa(int(order.UserID)) {
+		err = gorm.ErrRecordNotFound
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Order not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting order"
+		util.WriteJson(w, response)
+		return
+	}
+
+	shipments, err := h.ShipmentService.GetByOrder(order.ID)
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting shipments"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = dto.OrderTrackingDto{
+		OrderID:         order.ID,
+		Status:          order.Status,
+		ShippingAddress: order.ShippingAddress,
+		Shipments:       shipments,
+	}
+	util.WriteJson(w, response)
+}
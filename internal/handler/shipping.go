@@ -0,0 +1,453 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/fatihesergg/go_ecommerce/internal/dto"
+	"github.com/fatihesergg/go_ecommerce/internal/model"
+	"github.com/fatihesergg/go_ecommerce/internal/service"
+	"github.com/fatihesergg/go_ecommerce/internal/util"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+type ShippingHandler struct {
+	ShippingService service.ShippingService
+	Validator       *validator.Validate
+}
+
+func NewShippingHandler(service service.ShippingService, validator *validator.Validate) ShippingHandler {
+	return ShippingHandler{ShippingService: service, Validator: validator}
+}
+
+// GetZones godoc
+//
+//	@Tags			shipping
+//	@Summary		Show all shipping zones
+//	@Description	get all shipping zones with their countries and methods
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	util.ApiResponse{data=[]model.ShippingZone}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/shipping-zone [get]
+func (h *ShippingHandler) GetZones(w http.ResponseWriter, r *http.Request) {
+	zones, err := h.ShippingService.GetZones()
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting shipping zones"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = zones
+	util.WriteJson(w, response)
+}
+
+// GetZone godoc
+//
+//	@Tags			shipping
+//	@Summary		Show a shipping zone
+//	@Description	get shipping zone by ID
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Shipping Zone ID"
+//	@Success		200	{object}	util.ApiResponse{data=model.ShippingZone}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/shipping-zone/{id} [get]
+func (h *ShippingHandler) GetZone(w http.ResponseWriter, r *http.Request) {
+	_, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid shipping zone id"
+		util.WriteJson(w, response)
+		return
+	}
+	zone, err := h.ShippingService.GetZone(r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Shipping zone not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting shipping zone"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = zone
+	util.WriteJson(w, response)
+}
+
+// CreateZone godoc
+//
+//	@Tags			shipping
+//	@Summary		Create a shipping zone
+//	@Description	Create a shipping zone
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			zone	body		dto.ShippingZoneCreateDto	true	"Create Shipping Zone"
+//	@Success		200		{object}	util.ApiResponse{}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/shipping-zone [post]
+func (h *ShippingHandler) CreateZone(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.ShippingZoneCreateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	zone := model.ShippingZone{Name: data.Name}
+	if !h.checkZone(w, &zone, data.Countries) {
+		return
+	}
+	err = h.ShippingService.CreateZone(zone)
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while creating shipping zone"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusCreated
+	response.Message = "Shipping zone created successfully."
+	util.WriteJson(w, response)
+}
+
+// UpdateZone godoc
+//
+//	@Tags			shipping
+//	@Summary		Update a shipping zone
+//	@Description	Update a shipping zone and replace its countries
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			zone	body		dto.ShippingZoneUpdateDto	true	"Update Shipping Zone"
+//	@Success		200		{object}	util.ApiResponse{}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/shipping-zone [put]
+func (h *ShippingHandler) UpdateZone(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.ShippingZoneUpdateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	zone := model.ShippingZone{ID: uint(data.ID), Name: data.Name}
+	if !h.checkZone(w, &zone, data.Countries) {
+		return
+	}
+	err = h.ShippingService.UpdateZone(zone)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Shipping zone not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while updating shipping zone"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// DeleteZone godoc
+//
+//	@Tags			shipping
+//	@Summary		Delete a shipping zone
+//	@Description	Delete a shipping zone with its methods
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Shipping Zone ID"
+//	@Success		200	{object}	util.ApiResponse{}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/shipping-zone/{id} [delete]
+func (h *ShippingHandler) DeleteZone(w http.ResponseWriter, r *http.Request) {
+	_, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid shipping zone id"
+		util.WriteJson(w, response)
+		return
+	}
+	err = h.ShippingService.DeleteZone(r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Shipping zone not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while deleting shipping zone"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// CreateMethod godoc
+//
+//	@Tags			shipping
+//	@Summary		Create a shipping method
+//	@Description	Create a shipping method in a zone
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			method	body		dto.ShippingMethodCreateDto	true	"Create Shipping Method"
+//	@Success		200		{object}	util.ApiResponse{}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/shipping-method [post]
+func (h *ShippingHandler) CreateMethod(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.ShippingMethodCreateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	method := model.ShippingMethod{
+		ZoneID:    data.ZoneID,
+		Name:      data.Name,
+		Type:      model.ShippingRateType(data.Type),
+		BaseRate:  data.BaseRate,
+		PerKgRate: data.PerKgRate,
+		FreeOver:  data.FreeOver,
+		Active:    data.Active,
+	}
+	if !h.checkMethod(w, method) {
+		return
+	}
+	err = h.ShippingService.CreateMethod(method)
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while creating shipping method"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusCreated
+	response.Message = "Shipping method created successfully."
+	util.WriteJson(w, response)
+}
+
+// UpdateMethod godoc
+//
+//	@Tags			shipping
+//	@Summary		Update a shipping method
+//	@Description	Update a shipping method
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			method	body		dto.ShippingMethodUpdateDto	true	"Update Shipping Method"
+//	@Success		200		{object}	util.ApiResponse{}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/shipping-method [put]
+func (h *ShippingHandler) UpdateMethod(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.ShippingMethodUpdateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	method := model.ShippingMethod{
+		ID:        uint(data.ID),
+		ZoneID:    data.ZoneID,
+		Name:      data.Name,
+		Type:      model.ShippingRateType(data.Type),
+		BaseRate:  data.BaseRate,
+		PerKgRate: data.PerKgRate,
+		FreeOver:  data.FreeOver,
+		Active:    data.Active,
+	}
+	if !h.checkMethod(w, method) {
+		return
+	}
+	err = h.ShippingService.UpdateMethod(method)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Shipping method not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while updating shipping method"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// DeleteMethod godoc
+//
+//	@Tags			shipping
+//	@Summary		Delete a shipping method
+//	@Description	Delete a shipping method
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Shipping Method ID"
+//	@Success		200	{object}	util.ApiResponse{}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/shipping-method/{id} [delete]
+func (h *ShippingHandler) DeleteMethod(w http.ResponseWriter, r *http.Request) {
+	_, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid shipping method id"
+		util.WriteJson(w, response)
+		return
+	}
+	err = h.ShippingService.DeleteMethod(r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Shipping method not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while deleting shipping method"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// checkZone sets the countries of a zone, making sure none of them already
+// belongs to another zone. It writes the error response and returns false
+// when the zone is invalid.
+func (h *ShippingHandler) checkZone(w http.ResponseWriter, zone *model.ShippingZone, countries []string) bool {
+	var response util.ApiResponse
+	seen := map[string]bool{}
+	for _, country := range countries {
+		country = strings.ToUpper(country)
+		if seen[country] {
+			continue
+		}
+		seen[country] = true
+
+		exist, err := h.ShippingService.GetZoneByCountry(country)
+		if err == nil && exist.ID != zone.ID {
+			response.Status = http.StatusBadRequest
+			response.Message = fmt.Sprintf("%s already belongs to shipping zone %s", country, exist.Name)
+			util.WriteJson(w, response)
+			return false
+		}
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusInternalServerError
+			response.Message = "Error while getting shipping zone"
+			util.WriteJson(w, response)
+			return false
+		}
+		zone.Countries = append(zone.Countries, model.ShippingZoneCountry{Country: country})
+	}
+	return true
+}
+
+// checkMethod validates the rules required by the rate type of a method. It
+// writes the error response and returns false when the method is invalid.
+func (h *ShippingHandler) checkMethod(w http.ResponseWriter, method model.ShippingMethod) bool {
+	var response util.ApiResponse
+	if method.Type == model.SHIPPING_WEIGHT && method.PerKgRate <= 0 {
+		response.Status = http.StatusBadRequest
+		response.Message = "Per kg rate is required"
+		util.WriteJson(w, response)
+		return false
+	}
+	if method.Type == model.SHIPPING_FREE_OVER && method.FreeOver <= 0 {
+		response.Status = http.StatusBadRequest
+		response.Message = "Free over amount is required"
+		util.WriteJson(w, response)
+		return false
+	}
+	_, err := h.ShippingService.GetZone(strconv.Itoa(int(method.ZoneID)))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Invalid shipping zone id"
+			util.WriteJson(w, response)
+			return false
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting shipping zone"
+		util.WriteJson(w, response)
+		return false
+	}
+	return true
+}
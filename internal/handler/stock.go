@@ -0,0 +1,212 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/fatihesergg/go_ecommerce/internal/dto"
+	"github.com/fatihesergg/go_ecommerce/internal/model"
+	"github.com/fatihesergg/go_ecommerce/internal/service"
+	"github.com/fatihesergg/go_ecommerce/internal/util"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+type StockHandler struct {
+	StockService service.StockService
+	Validator    *validator.Validate
+}
+
+func NewStockHandler(service service.StockService, validator *validator.Validate) StockHandler {
+	return StockHandler{StockService: service, Validator: validator}
+}
+
+// Adjust godoc
+//
+//	@Tags			stock
+//	@Summary		Adjust the stock of a product
+//	@Description	Change the stock of a product by a quantity, negative to take stock out, or set it to the counted stock. The change is recorded as an adjustment with the note
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			adjustment	body		dto.StockAdjustmentDto	true	"Stock Adjustment"
+//	@Success		200			{object}	util.ApiResponse{data=model.StockMovement}
+//	@Failure		400			{object}	util.ApiResponse{}
+//	@Failure		500			{object}	util.ApiResponse{}
+//	@Router			/stock/adjustment [post]
+func (h *StockHandler) Adjust(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.StockAdjustmentDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+
+	userID := actorID(r)
+	movement := model.StockMovement{ProductID: data.ProductID, Reason: model.STOCK_ADJUSTMENT, Note: data.Note, UserID: &userID}
+	if data.Stock != nil {
+		movement, err = h.StockService.Set(movement, *data.Stock)
+	} else {
+		movement.Quantity = *data.Quantity
+		movement, err = h.StockService.Adjust(movement)
+	}
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Product not found"
+			util.WriteJson(w, response)
+			return
+		}
+		if errors.Is(err, util.OutOfStockError) || errors.Is(err, util.StockAdjustmentError) {
+			response.Status = http.StatusBadRequest
+			response.Message = err.Error()
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while adjusting stock"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = movement
+	util.WriteJson(w, response)
+}
+
+// Report godoc
+//
+//	@Tags			stock
+//	@Summary		Show the stock history of a product
+//	@Description	get the stock movements of a product, newest first, with the stock that came in and went out between from and to
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id		path		int		true	"Product ID"
+//	@Param			from	query		string	false	"First day, as YYYY-MM-DD"
+//	@Param			to		query		string	false	"Last day, as YYYY-MM-DD"
+//	@Success		200		{object}	util.ApiResponse{data=dto.StockReportDto}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/product/{id}/stock [get]
+func (h *StockHandler) Report(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid product id"
+		util.WriteJson(w, response)
+		return
+	}
+	from, to, ok := dateRange(w, r)
+	if !ok {
+		return
+	}
+	report, err := h.StockService.Report(uint(id), from, to)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Product not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting stock history"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = report
+	util.WriteJson(w, response)
+}
+
+// GetDiscrepancies godoc
+//
+//	@Tags			stock
+//	@Summary		Show stock discrepancies
+//	@Description	get the products whose stock doesn't match the sum of their stock movements
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	util.ApiResponse{data=[]storage.StockDiscrepancy}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/stock/discrepancies [get]
+func (h *StockHandler) GetDiscrepancies(w http.ResponseWriter, r *http.Request) {
+	discrepancies, err := h.StockService.GetDiscrepancies()
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while checking stock"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = discrepancies
+	util.WriteJson(w, response)
+}
+
+// Reconcile godoc
+//
+//	@Tags			stock
+//	@Summary		Reconcile stock
+//	@Description	Set the stock of the products that don't match their stock movements back to the sum of the movements, or to 0 with an adjustment when the sum is negative. The products are returned with the stock they had
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	util.ApiResponse{data=[]storage.StockDiscrepancy}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/stock/reconcile [post]
+func (h *StockHandler) Reconcile(w http.ResponseWriter, r *http.Request) {
+	discrepancies, err := h.StockService.Reconcile(actorID(r))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while reconciling stock"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = discrepancies
+	util.WriteJson(w, response)
+}
+
+// dateRange reads the "from" and "to" days of the query. to is returned as the
+// start of the day after it so the whole day is included. It writes the error
+// response and returns false when a day is invalid.
+func dateRange(w http.ResponseWriter, r *http.Request) (from time.Time, to time.Time, ok bool) {
+	var response util.ApiResponse
+	query := r.URL.Query()
+	var err error
+	if value := query.Get("from"); value != "" {
+		if from, err = time.Parse(time.DateOnly, value); err != nil {
+			response.Status = http.StatusBadRequest
+			response.Message = "Invalid from date"
+			util.WriteJson(w, response)
+			return from, to, false
+		}
+	}
+	if value := query.Get("to"); value != "" {
+		if to, err = time.Parse(time.DateOnly, value); err != nil {
+			response.Status = http.StatusBadRequest
+			response.Message = "Invalid to date"
+			util.WriteJson(w, response)
+			return from, to, false
+		}
+		to = to.AddDate(0, 0, 1)
+	}
+	return from, to, true
+}
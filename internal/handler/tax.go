@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/fatihesergg/go_ecommerce/internal/dto"
+	"github.com/fatihesergg/go_ecommerce/internal/model"
+	"github.com/fatihesergg/go_ecommerce/internal/service"
+	"github.com/fatihesergg/go_ecommerce/internal/util"
+	"github.com/go-playground/validator/v10"
+	"gorm.io/gorm"
+)
+
+type TaxHandler struct {
+	TaxService service.TaxService
+	Validator  *validator.Validate
+}
+
+func NewTaxHandler(service service.TaxService, validator *validator.Validate) TaxHandler {
+	return TaxHandler{TaxService: service, Validator: validator}
+}
+
+// GetAll godoc
+//
+//	@Tags			tax
+//	@Summary		Show all tax rates
+//	@Description	get all tax rates
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Success		200	{object}	util.ApiResponse{data=[]model.TaxRate}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/tax-rate [get]
+func (h *TaxHandler) GetAll(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.TaxService.GetAll()
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while getting tax rates"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	response.Data = rates
+	util.WriteJson(w, response)
+}
+
+// Create godoc
+//
+//	@Tags			tax
+//	@Summary		Create a tax rate
+//	@Description	Create a tax rate
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			rate	body		dto.TaxRateCreateDto	true	"Create Tax Rate"
+//	@Success		200		{object}	util.ApiResponse{}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/tax-rate [post]
+func (h *TaxHandler) Create(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.TaxRateCreateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+	rate := model.TaxRate{
+		Name:        data.Name,
+		Country:     data.Country,
+		Region:      data.Region,
+		TaxCategory: data.TaxCategory,
+		Rate:        data.Rate,
+		Inclusive:   data.Inclusive,
+	}
+	err = h.TaxService.Create(rate)
+	if err != nil {
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while creating tax rate"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusCreated
+	response.Message = "Tax rate created successfully."
+	util.WriteJson(w, response)
+}
+
+// Update godoc
+//
+//	@Tags			tax
+//	@Summary		Update a tax rate
+//	@Description	Update a tax rate
+//	@Accept			json
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			rate	body		dto.TaxRateUpdateDto	true	"Update Tax Rate"
+//	@Success		200		{object}	util.ApiResponse{}
+//	@Failure		400		{object}	util.ApiResponse{}
+//	@Failure		500		{object}	util.ApiResponse{}
+//	@Router			/tax-rate [put]
+func (h *TaxHandler) Update(w http.ResponseWriter, r *http.Request) {
+	defer r.Body.Close()
+	var data dto.TaxRateUpdateDto
+	var response util.ApiResponse
+	if err := json.NewDecoder(r.Body).Decode(&data); err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = util.JsonDecodeError.Error()
+		util.WriteJson(w, response)
+		return
+	}
+	err := h.Validator.Struct(data)
+	if err != nil {
+		ve := err.(validator.ValidationErrors)
+		response.Status = http.StatusBadRequest
+		response.Message = util.GetErrorMessages(ve)
+		util.WriteJson(w, response)
+		return
+	}
+	rate := model.TaxRate{
+		ID:          uint(data.ID),
+		Name:        data.Name,
+		Country:     data.Country,
+		Region:      data.Region,
+		TaxCategory: data.TaxCategory,
+		Rate:        data.Rate,
+		Inclusive:   data.Inclusive,
+	}
+	err = h.TaxService.Update(rate)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Tax rate not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while updating tax rate"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
+
+// Delete godoc
+//
+//	@Tags			tax
+//	@Summary		Delete a tax rate
+//	@Description	Delete a tax rate
+//	@Produce		json
+//	@Security		BearerAuth
+//	@Param			id	path		int	true	"Tax Rate ID"
+//	@Success		200	{object}	util.ApiResponse{}
+//	@Failure		400	{object}	util.ApiResponse{}
+//	@Failure		500	{object}	util.ApiResponse{}
+//	@Router			/tax-rate/{id} [delete]
+func (h *TaxHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	_, err := strconv.Atoi(r.PathValue("id"))
+	var response util.ApiResponse
+	if err != nil {
+		response.Status = http.StatusBadRequest
+		response.Message = "Invalid tax rate id"
+		util.WriteJson(w, response)
+		return
+	}
+	err = h.TaxService.Delete(r.PathValue("id"))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			response.Status = http.StatusBadRequest
+			response.Message = "Tax rate not found"
+			util.WriteJson(w, response)
+			return
+		}
+		response.Status = http.StatusInternalServerError
+		response.Message = "Error while deleting tax rate"
+		util.WriteJson(w, response)
+		return
+	}
+	response.Status = http.StatusOK
+	response.Message = "Success"
+	util.WriteJson(w, response)
+}
@@ -1,9 +1,15 @@
 package middleware
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"io"
 	"net/http"
+	"strconv"
 
+	"github.com/fatihesergg/go_ecommerce/internal/service"
 	"github.com/fatihesergg/go_ecommerce/internal/util"
 	"go.uber.org/zap"
 )
@@ -14,6 +20,12 @@ const (
 	AuthUserID = "userID"
 )
 
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+)
+
 func LoggerMiddleware(handler http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		LOGGER.Infow("New Request", "URL", r.URL, "Method", r.Method, "Addr", r.RemoteAddr, "Header", r.Header)
@@ -56,3 +68,82 @@ func CheckPermission(role string, claims *util.JwtTokenClaims) bool {
 	}
 	return true
 }
+
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (rec *responseRecorder) Write(b []byte) (int, error) {
+	rec.body.Write(b)
+	return rec.ResponseWriter.Write(b)
+}
+
+// Idempotent replays the stored response of a request repeated with the same
+// Idempotency-Key header. It must be wrapped by RequireLogin.
+func Idempotent(idempotencyService service.IdempotencyService, handler http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		if key == "" {
+			handler(w, r)
+			return
+		}
+		var response util.ApiResponse
+		if len(key) > maxIdempotencyKeyLength {
+			response.Status = http.StatusBadRequest
+			response.Message = "Idempotency-Key is too long"
+			util.WriteJson(w, response)
+			return
+		}
+
+		body, err := io.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			response.Status = http.StatusBadRequest
+			response.Message = "Error while reading request"
+			util.WriteJson(w, response)
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		userID, _ := r.Context().Value(AuthUserID).(string)
+		userIDint, _ := strconv.Atoi(userID)
+		record, err := idempotencyService.Begin(uint(userIDint), key, r.Method, r.URL.Path, body)
+		if err != nil {
+			switch {
+			case errors.Is(err, util.IdempotencyKeyMismatchError):
+				response.Status = http.StatusUnprocessableEntity
+				response.Message = err.Error()
+			case errors.Is(err, util.IdempotencyKeyInProgressError):
+				response.Status = http.StatusConflict
+				response.Message = err.Error()
+			default:
+				response.Status = http.StatusInternalServerError
+				response.Message = "Error while checking Idempotency-Key"
+			}
+			util.WriteJson(w, response)
+			return
+		}
+		if record.Completed {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.Write(record.Response)
+			return
+		}
+
+		recorder := &responseRecorder{ResponseWriter: w}
+		handler(recorder, r)
+
+		// Server errors are not stored so that the client can retry them.
+		var result util.ApiResponse
+		if err := json.Unmarshal(recorder.body.Bytes(), &result); err != nil || result.Status >= http.StatusInternalServerError {
+			if err := idempotencyService.Release(record); err != nil {
+				LOGGER.Errorw("Error while releasing idempotency key", "key", key, "error", err)
+			}
+			return
+		}
+		if err := idempotencyService.Complete(record, recorder.body.Bytes()); err != nil {
+			LOGGER.Errorw("Error while storing idempotent response", "key", key, "error", err)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package model
 
 import (
 	"time"
+
+	"gorm.io/gorm"
 )
 
 type Status int
@@ -13,11 +15,157 @@ const (
 	FAILED
 )
 
+type OrderStatus string
+
+const (
+	ORDER_PENDING    OrderStatus = "pending"
+	ORDER_PROCESSING OrderStatus = "processing"
+	ORDER_PAID       OrderStatus = "paid"
+
+	ORDER_PARTIALLY_SHIPPED OrderStatus = "partially_shipped"
+	ORDER_SHIPPED           OrderStatus = "shipped"
+	ORDER_DELIVERED         OrderStatus = "delivered"
+
+	ORDER_CANCELLED OrderStatus = "cancelled"
+)
+
+type ShipmentStatus string
+
+const (
+	SHIPMENT_SHIPPED    ShipmentStatus = "shipped"
+	SHIPMENT_IN_TRANSIT ShipmentStatus = "in_transit"
+	SHIPMENT_DELIVERED  ShipmentStatus = "delivered"
+)
+
+type DiscountType string
+
+const (
+	DISCOUNT_PERCENTAGE DiscountType = "percentage"
+	DISCOUNT_FIXED      DiscountType = "fixed"
+)
+
+type PromotionType string
+
+const (
+	PROMOTION_BUY_X_GET_Y   PromotionType = "buy_x_get_y"
+	PROMOTION_THRESHOLD     PromotionType = "threshold"
+	PROMOTION_CATEGORY_SALE PromotionType = "category_sale"
+	PROMOTION_BUNDLE        PromotionType = "bundle"
+)
+
+type ReturnStatus string
+
+const (
+	RETURN_REQUESTED ReturnStatus = "requested"
+	RETURN_APPROVED  ReturnStatus = "approved"
+	RETURN_REJECTED  ReturnStatus = "rejected"
+	RETURN_RECEIVED  ReturnStatus = "received"
+	RETURN_INSPECTED ReturnStatus = "inspected"
+	RETURN_REFUNDED  ReturnStatus = "refunded"
+	RETURN_CLOSED    ReturnStatus = "closed"
+)
+
+type ReviewStatus string
+
+const (
+	REVIEW_PENDING  ReviewStatus = "pending"
+	REVIEW_APPROVED ReviewStatus = "approved"
+	REVIEW_REJECTED ReviewStatus = "rejected"
+)
+
+type NotificationType string
+
+const (
+	NOTIFICATION_REVIEW_REPLY NotificationType = "review_reply"
+)
+
+type AttributeType string
+
+const (
+	ATTRIBUTE_TEXT    AttributeType = "text"
+	ATTRIBUTE_NUMBER  AttributeType = "number"
+	ATTRIBUTE_ENUM    AttributeType = "enum"
+	ATTRIBUTE_BOOLEAN AttributeType = "boolean"
+)
+
+type SlugEntity string
+
+const (
+	SLUG_PRODUCT  SlugEntity = "product"
+	SLUG_CATEGORY SlugEntity = "category"
+)
+
+type ImportFormat string
+
+const (
+	IMPORT_CSV  ImportFormat = "csv"
+	IMPORT_JSON ImportFormat = "json"
+)
+
+type ImportStatus string
+
+const (
+	IMPORT_PENDING   ImportStatus = "pending"
+	IMPORT_RUNNING   ImportStatus = "running"
+	IMPORT_COMPLETED ImportStatus = "completed"
+	IMPORT_FAILED    ImportStatus = "failed"
+)
+
+type ExportFormat string
+
+const (
+	EXPORT_CSV  ExportFormat = "csv"
+	EXPORT_JSON ExportFormat = "json"
+	EXPORT_XML  ExportFormat = "xml"
+)
+
+type PriceSource string
+
+const (
+	PRICE_MANUAL    PriceSource = "manual"
+	PRICE_SCHEDULED PriceSource = "scheduled"
+	PRICE_BASELINE  PriceSource = "baseline"
+)
+
+type ScheduledPriceStatus string
+
+const (
+	SCHEDULED_PRICE_PENDING   ScheduledPriceStatus = "pending"
+	SCHEDULED_PRICE_ACTIVE    ScheduledPriceStatus = "active"
+	SCHEDULED_PRICE_ENDED     ScheduledPriceStatus = "ended"
+	SCHEDULED_PRICE_CANCELLED ScheduledPriceStatus = "cancelled"
+)
+
+type StockReason string
+
+const (
+	STOCK_OPENING      StockReason = "opening"
+	STOCK_SALE         StockReason = "sale"
+	STOCK_CANCELLATION StockReason = "cancellation"
+	STOCK_RETURN       StockReason = "return"
+	STOCK_ADJUSTMENT   StockReason = "adjustment"
+	STOCK_IMPORT       StockReason = "import"
+)
+
+type ShippingRateType string
+
+const (
+	SHIPPING_FLAT      ShippingRateType = "flat"
+	SHIPPING_WEIGHT    ShippingRateType = "weight"
+	SHIPPING_FREE_OVER ShippingRateType = "free_over"
+)
+
+// BaseCurrency is the currency product prices are stored in.
+const BaseCurrency = "USD"
+
 type Category struct {
-	ID        uint      `gorm:"primaryKey" json:"id"`
-	Name      string    `json:"name" `
-	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
-	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+	ID        uint           `gorm:"primaryKey" json:"id"`
+	Name      string         `json:"name" `
+	Slug      string         `gorm:"uniqueIndex" json:"slug"`
+	ParentID  *uint          `gorm:"index" json:"parent_id"`
+	CreatedAt time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at" swaggertype:"string" format:"date-time"`
 }
 
 type User struct {
@@ -33,52 +181,560 @@ type User struct {
 }
 
 type Review struct {
+	ID               uint           `gorm:"primaryKey" json:"id"`
+	Comment          string         `json:"comment" `
+	Rating           int            `json:"rating"`
+	HelpfulCount     int            `json:"helpful_count"`
+	UnhelpfulCount   int            `json:"unhelpful_count"`
+	ReportCount      int            `json:"report_count"`
+	VerifiedPurchase bool           `json:"verified_purchase"`
+	Status           ReviewStatus   `gorm:"default:approved;index" json:"status"`
+	ModerationReason string         `json:"moderation_reason"`
+	ModeratedAt      *time.Time     `json:"moderated_at"`
+	ProductID        uint           `gorm:"uniqueIndex:idx_review_user_product_active,where:deleted_at IS NULL" json:"product_id" `
+	Product          Product        `gorm:"foreignKey:ProductID" json:"-"`
+	UserID           uint           `gorm:"uniqueIndex:idx_review_user_product_active,where:deleted_at IS NULL" json:"user_id" `
+	User             User           `gorm:"foreignKey:UserID" json:"-"`
+	Reply            *ReviewReply   `gorm:"foreignKey:ReviewID" json:"reply"`
+	CreatedAt        time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt        time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt        gorm.DeletedAt `gorm:"index" json:"deleted_at" swaggertype:"string" format:"date-time"`
+}
+
+// ReviewVote is the helpful or unhelpful vote of a user on a review.
+type ReviewVote struct {
 	ID        uint      `gorm:"primaryKey" json:"id"`
-	Comment   string    `json:"comment" `
-	ProductID uint      `json:"product_id" `
-	Product   Product   `gorm:"foreignKey:ProductID" json:"-"`
-	UserID    uint      `json:"user_id" `
-	User      User      `gorm:"foreignKey:UserID" json:"-"`
+	ReviewID  uint      `gorm:"uniqueIndex:idx_review_vote_user" json:"review_id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_review_vote_user" json:"user_id"`
+	Helpful   bool      `json:"helpful"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ReviewReply is the public answer of the staff to a review.
+type ReviewReply struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ReviewID  uint      `gorm:"uniqueIndex" json:"review_id"`
+	UserID    uint      `json:"user_id"`
+	Body      string    `json:"body"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
+type ReviewReport struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ReviewID  uint      `gorm:"uniqueIndex:idx_review_report_user" json:"review_id"`
+	UserID    uint      `gorm:"uniqueIndex:idx_review_report_user" json:"user_id"`
+	Reason    string    `json:"reason"`
+	Note      string    `json:"note"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// Product is an item of the catalog. Stock only changes through stock
+// movements. WasPrice is the price before the scheduled price the product is
+// on, and LowestPrice30Days the lowest price it had in the 30 days before its
+// current price; both are only filled for display.
 type Product struct {
-	ID         uint      `gorm:"primaryKey" json:"id"`
-	Name       string    `json:"name" `
-	ImageURL   *string   `json:"image_url"`
-	Price      float64   `json:"price" `
-	Stock      uint      `json:"stock" `
-	CategoryID uint      `json:"category_id" `
-	Category   Category  `gorm:"foreignKey:CategoryID" json:"-"`
-	CreatedAt  time.Time `gorm:"autoCreateTime" json:"created_at"`
+	ID                uint                    `gorm:"primaryKey" json:"id"`
+	Name              string                  `json:"name" `
+	Slug              string                  `gorm:"uniqueIndex" json:"slug"`
+	SKU               *string                 `gorm:"uniqueIndex" json:"sku"`
+	Description       string                  `gorm:"type:text" json:"description"`
+	ImageURL          *string                 `json:"image_url"`
+	Price             float64                 `json:"price" `
+	Stock             uint                    `json:"stock" `
+	CategoryID        uint                    `json:"category_id" `
+	Category          Category                `gorm:"foreignKey:CategoryID" json:"-"`
+	TaxCategory       string                  `json:"tax_category"`
+	Weight            float64                 `json:"weight"`
+	Length            float64                 `json:"length"`
+	Width             float64                 `json:"width"`
+	Height            float64                 `json:"height"`
+	Rating            ProductRating           `gorm:"foreignKey:ProductID" json:"rating"`
+	Attributes        []ProductAttributeValue `gorm:"foreignKey:ProductID" json:"attributes"`
+	Currency          string                  `gorm:"-" json:"currency"`
+	WasPrice          *float64                `gorm:"-" json:"was_price"`
+	LowestPrice30Days *float64                `gorm:"-" json:"lowest_price_30_days"`
+	CreatedAt         time.Time               `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time               `gorm:"autoUpdateTime" json:"updated_at"`
+	DeletedAt         gorm.DeletedAt          `gorm:"index" json:"deleted_at" swaggertype:"string" format:"date-time"`
+}
+
+// StockMovement is a change of the stock of a product. Quantity is negative
+// when stock went out and StockAfter is the stock left after the change, so
+// the stock of a product is the sum of its movements. UserID is who made the
+// change and the order, return or import it came from is kept with it.
+type StockMovement struct {
+	ID          uint        `gorm:"primaryKey" json:"id"`
+	ProductID   uint        `gorm:"index:idx_stock_movement_product" json:"product_id"`
+	Quantity    int         `json:"quantity"`
+	StockAfter  uint        `json:"stock_after"`
+	Reason      StockReason `json:"reason"`
+	Note        string      `json:"note"`
+	UserID      *uint       `gorm:"index" json:"user_id"`
+	OrderID     *uint       `gorm:"index" json:"order_id"`
+	ReturnID    *uint       `json:"return_id"`
+	ImportJobID *uint       `json:"import_job_id"`
+	CreatedAt   time.Time   `gorm:"autoCreateTime;index:idx_stock_movement_product" json:"created_at"`
+}
+
+// AttributeDefinition is a typed attribute of the products of a category and
+// of the categories below it. Options lists the allowed values of an enum.
+type AttributeDefinition struct {
+	ID         uint          `gorm:"primaryKey" json:"id"`
+	CategoryID uint          `gorm:"index" json:"category_id"`
+	Name       string        `json:"name"`
+	Type       AttributeType `json:"type"`
+	Unit       string        `json:"unit"`
+	Options    []string      `gorm:"serializer:json" json:"options"`
+	Required   bool          `json:"required"`
+	CreatedAt  time.Time     `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt  time.Time     `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ProductAttributeValue is the value of an attribute for a product. Number
+// attributes also keep the value as a number for range filters.
+type ProductAttributeValue struct {
+	ID          uint                `gorm:"primaryKey" json:"-"`
+	ProductID   uint                `gorm:"uniqueIndex:idx_product_attribute" json:"-"`
+	AttributeID uint                `gorm:"uniqueIndex:idx_product_attribute;index" json:"attribute_id"`
+	Attribute   AttributeDefinition `gorm:"foreignKey:AttributeID" json:"attribute"`
+	Value       string              `json:"value"`
+	NumberValue *float64            `json:"-"`
+}
+
+// ProductRating is the aggregate of the review ratings of a product, kept up
+// to date whenever a review is written.
+type ProductRating struct {
+	ID         uint      `gorm:"primaryKey" json:"-"`
+	ProductID  uint      `gorm:"uniqueIndex" json:"product_id"`
+	Average    float64   `gorm:"index" json:"average"`
+	Count      int       `json:"count"`
+	OneStar    int       `json:"one_star"`
+	TwoStars   int       `json:"two_stars"`
+	ThreeStars int       `json:"three_stars"`
+	FourStars  int       `json:"four_stars"`
+	FiveStars  int       `json:"five_stars"`
 	UpdatedAt  time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
-type OrderItem struct {
-	ID        uint    `gorm:"primaryKey" json:"id"`
-	ProductID uint    `json:"product_id" `
-	Product   Product `gorm:"foreignKey:ProductID"  json:"-"`
-	Quantity  int     `json:"quantity"`
-	OrderID   int
+// ProductPrice overrides the converted price of a product in a given currency.
+type ProductPrice struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	ProductID uint      `gorm:"uniqueIndex:idx_product_price_currency" json:"product_id"`
+	Product   Product   `gorm:"foreignKey:ProductID" json:"-"`
+	Currency  string    `gorm:"uniqueIndex:idx_product_price_currency" json:"currency"`
+	Price     float64   `json:"price"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// PriceHistory is a price a product had from CreatedAt until the next entry.
+type PriceHistory struct {
+	ID               uint        `gorm:"primaryKey" json:"id"`
+	ProductID        uint        `gorm:"index:idx_price_history_product" json:"product_id"`
+	Price            float64     `json:"price"`
+	Source           PriceSource `json:"source"`
+	ScheduledPriceID *uint       `json:"scheduled_price_id"`
+	CreatedAt        time.Time   `gorm:"autoCreateTime;index:idx_price_history_product" json:"created_at"`
+}
+
+// ScheduledPrice is a price a product takes from StartsAt until EndsAt, when
+// the price it had before, kept in PreviousPrice, comes back. Without EndsAt
+// the price stays.
+type ScheduledPrice struct {
+	ID            uint                 `gorm:"primaryKey" json:"id"`
+	ProductID     uint                 `gorm:"index" json:"product_id"`
+	Price         float64              `json:"price"`
+	PreviousPrice *float64             `json:"previous_price"`
+	StartsAt      time.Time            `gorm:"index" json:"starts_at"`
+	EndsAt        *time.Time           `json:"ends_at"`
+	Status        ScheduledPriceStatus `gorm:"default:pending;index" json:"status"`
+	AppliedAt     *time.Time           `json:"applied_at"`
+	EndedAt       *time.Time           `json:"ended_at"`
+	CreatedAt     time.Time            `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ExchangeRate is the amount of Currency equal to one unit of BaseCurrency.
+type ExchangeRate struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	Currency  string    `gorm:"uniqueIndex" json:"currency"`
+	Rate      float64   `json:"rate"`
 	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
 
+// OrderItem is a line of an order. The name, SKU, unit price and taxes of
+// the product are captured at checkout so later changes to the product don't
+// change past orders.
+type OrderItem struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	ProductID      uint           `json:"product_id" `
+	Product        Product        `gorm:"foreignKey:ProductID"  json:"-"`
+	ProductName    string         `json:"product_name"`
+	SKU            string         `json:"sku"`
+	Quantity       int            `json:"quantity"`
+	UnitPrice      float64        `json:"unit_price"`
+	DiscountAmount float64        `json:"discount_amount"`
+	TaxAmount      float64        `json:"tax_amount"`
+	Total          float64        `json:"total"`
+	Taxes          []OrderItemTax `gorm:"foreignKey:OrderItemID" json:"taxes"`
+	OrderID        int
+	CreatedAt      time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
 type Payment struct {
 	ID            uint    `gorm:"primaryKey" json:"id"`
 	TransactionId string  `json:"transaction_id" `
 	Amount        float64 `json:"amount" `
+	Currency      string  `json:"currency"`
 	Status        Status  `json:"status" `
 	OrderID       uint
 	Order         Order     `gorm:"foreignKey:OrderID"`
 	CreatedAt     time.Time `gorm:"autoCreateTime" json:"created_at"`
 	UpdatedAt     time.Time `gorm:"autoUpdateTime" json:"updated_at"`
 }
+
+// Refund is money sent back for a payment, for example when a return is
+// accepted.
+type Refund struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	PaymentID       uint      `gorm:"index" json:"payment_id"`
+	Payment         Payment   `gorm:"foreignKey:PaymentID" json:"-"`
+	ReturnRequestID *uint     `gorm:"index" json:"return_request_id"`
+	TransactionId   string    `json:"transaction_id"`
+	Amount          float64   `json:"amount"`
+	Currency        string    `json:"currency"`
+	Status          Status    `json:"status"`
+	CreatedAt       time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt       time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Order amounts are in Currency. TotalAmount is Subtotal minus DiscountAmount
+// plus the exclusive taxes and ShippingAmount; inclusive taxes are only
+// reported in TaxAmount.
 type Order struct {
-	ID          uint        `gorm:"primaryKey" json:"id"`
-	UserID      uint        `json:"user_id"  `
-	User        User        `gorm:"foreignKey:UserID" json:"-"`
-	Products    []OrderItem `json:"products" gorm:"foreignKey:OrderID" `
-	TotalAmount float64     `json:"total_amount" `
+	ID               uint            `gorm:"primaryKey" json:"id"`
+	UserID           uint            `json:"user_id"  `
+	User             User            `gorm:"foreignKey:UserID" json:"-"`
+	Products         []OrderItem     `json:"products" gorm:"foreignKey:OrderID" `
+	Discounts        []OrderDiscount `json:"discounts" gorm:"foreignKey:OrderID"`
+	Subtotal         float64         `json:"subtotal"`
+	DiscountAmount   float64         `json:"discount_amount"`
+	TaxAmount        float64         `json:"tax_amount"`
+	ShippingMethodID *uint           `json:"shipping_method_id"`
+	ShippingMethod   string          `json:"shipping_method"`
+	ShippingAmount   float64         `json:"shipping_amount"`
+	TotalAmount      float64         `json:"total_amount" `
+	Currency         string          `json:"currency"`
+	ExchangeRate     float64         `json:"exchange_rate"`
+	Status           OrderStatus     `gorm:"default:pending" json:"status"`
+	ShippingAddress  AddressSnapshot `gorm:"embedded;embeddedPrefix:shipping_" json:"shipping_address"`
+	BillingAddress   AddressSnapshot `gorm:"embedded;embeddedPrefix:billing_" json:"billing_address"`
+	CreatedAt        time.Time       `gorm:"autoCreateTime;default:CURRENT_TIMESTAMP" json:"created_at"`
+}
+
+type Address struct {
+	ID                uint      `gorm:"primaryKey" json:"id"`
+	UserID            uint      `gorm:"index" json:"user_id"`
+	User              User      `gorm:"foreignKey:UserID" json:"-"`
+	FullName          string    `json:"full_name"`
+	Line1             string    `json:"line1"`
+	Line2             string    `json:"line2"`
+	City              string    `json:"city"`
+	Region            string    `json:"region"`
+	PostalCode        string    `json:"postal_code"`
+	Country           string    `json:"country"`
+	Phone             string    `json:"phone"`
+	IsDefaultShipping bool      `json:"is_default_shipping"`
+	IsDefaultBilling  bool      `json:"is_default_billing"`
+	CreatedAt         time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt         time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// Shipment is a parcel sent for an order. An order can be split over several
+// shipments, each carrying some quantity of its items.
+type Shipment struct {
+	ID             uint           `gorm:"primaryKey" json:"id"`
+	OrderID        uint           `gorm:"index" json:"order_id"`
+	Order          Order          `gorm:"foreignKey:OrderID" json:"-"`
+	Carrier        string         `json:"carrier"`
+	TrackingNumber string         `json:"tracking_number"`
+	TrackingURL    string         `json:"tracking_url"`
+	Status         ShipmentStatus `gorm:"default:shipped" json:"status"`
+	Items          []ShipmentItem `gorm:"foreignKey:ShipmentID" json:"items"`
+	ShippedAt      *time.Time     `json:"shipped_at"`
+	DeliveredAt    *time.Time     `json:"delivered_at"`
+	CreatedAt      time.Time      `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time      `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+type ShipmentItem struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	ShipmentID  uint      `gorm:"index" json:"shipment_id"`
+	OrderItemID uint      `gorm:"index" json:"order_item_id"`
+	OrderItem   OrderItem `gorm:"foreignKey:OrderItemID" json:"-"`
+	Quantity    int       `json:"quantity"`
+}
+
+// ReturnRequest is a request of a customer to send back shipped items of an
+// order. RefundAmount is set when the returned items are inspected.
+type ReturnRequest struct {
+	ID           uint            `gorm:"primaryKey" json:"id"`
+	OrderID      uint            `gorm:"index" json:"order_id"`
+	Order        Order           `gorm:"foreignKey:OrderID" json:"-"`
+	UserID       uint            `gorm:"index" json:"user_id"`
+	User         User            `gorm:"foreignKey:UserID" json:"-"`
+	Status       ReturnStatus    `gorm:"default:requested" json:"status"`
+	Note         string          `json:"note"`
+	RefundAmount float64         `json:"refund_amount"`
+	Currency     string          `json:"currency"`
+	Items        []ReturnItem    `gorm:"foreignKey:ReturnRequestID" json:"items"`
+	History      []ReturnHistory `gorm:"foreignKey:ReturnRequestID" json:"history"`
+	CreatedAt    time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt    time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// ReturnItem is a quantity of an order item sent back. Accepted and Restocked
+// are decided when the item is inspected.
+type ReturnItem struct {
+	ID              uint      `gorm:"primaryKey" json:"id"`
+	ReturnRequestID uint      `gorm:"index" json:"return_request_id"`
+	OrderItemID     uint      `gorm:"index" json:"order_item_id"`
+	OrderItem       OrderItem `gorm:"foreignKey:OrderItemID" json:"-"`
+	Quantity        int       `json:"quantity"`
+	Reason          string    `json:"reason"`
+	Accepted        bool      `json:"accepted"`
+	Restocked       bool      `json:"restocked"`
+}
+
+// ReturnHistory records every status a return went through and who moved it.
+type ReturnHistory struct {
+	ID              uint         `gorm:"primaryKey" json:"id"`
+	ReturnRequestID uint         `gorm:"index" json:"return_request_id"`
+	Status          ReturnStatus `json:"status"`
+	Note            string       `json:"note"`
+	ActorID         uint         `json:"actor_id"`
+	CreatedAt       time.Time    `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ShippingZone groups the countries that share the same shipping methods. A
+// country belongs to one zone at most.
+type ShippingZone struct {
+	ID        uint                  `gorm:"primaryKey" json:"id"`
+	Name      string                `json:"name"`
+	Countries []ShippingZoneCountry `gorm:"foreignKey:ZoneID" json:"countries"`
+	Methods   []ShippingMethod      `gorm:"foreignKey:ZoneID" json:"methods"`
+	CreatedAt time.Time             `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time             `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+type ShippingZoneCountry struct {
+	ID      uint   `gorm:"primaryKey" json:"-"`
+	ZoneID  uint   `gorm:"index" json:"-"`
+	Country string `gorm:"uniqueIndex" json:"country"`
+}
+
+// ShippingMethod rates are in BaseCurrency. BaseRate is the price of a flat
+// rate or free over method and the starting price of a weight based method,
+// which adds PerKgRate for every kilogram. Free over methods cost nothing
+// once the discounted subtotal reaches FreeOver.
+type ShippingMethod struct {
+	ID        uint             `gorm:"primaryKey" json:"id"`
+	ZoneID    uint             `gorm:"index" json:"zone_id"`
+	Zone      ShippingZone     `gorm:"foreignKey:ZoneID" json:"-"`
+	Name      string           `json:"name"`
+	Type      ShippingRateType `json:"type"`
+	BaseRate  float64          `json:"base_rate"`
+	PerKgRate float64          `json:"per_kg_rate"`
+	FreeOver  float64          `json:"free_over"`
+	Active    bool             `json:"active"`
+	CreatedAt time.Time        `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt time.Time        `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// AddressSnapshot is the copy of an address stored on an order, so editing
+// the address book doesn't change where past orders were sent.
+type AddressSnapshot struct {
+	FullName   string `json:"full_name"`
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2"`
+	City       string `json:"city"`
+	Region     string `json:"region"`
+	PostalCode string `json:"postal_code"`
+	Country    string `json:"country"`
+	Phone      string `json:"phone"`
+}
+
+// OrderItemTax is a tax line of an order item. Inclusive taxes are part of
+// the item price, exclusive taxes are added to it.
+type OrderItemTax struct {
+	ID          uint    `gorm:"primaryKey" json:"id"`
+	OrderItemID uint    `json:"order_item_id"`
+	Name        string  `json:"name"`
+	Rate        float64 `json:"rate"`
+	Inclusive   bool    `json:"inclusive"`
+	Amount      float64 `json:"amount"`
+}
+
+// TaxRate is the percentage charged for a tax category in a country or in a
+// region of it. Empty Region and TaxCategory match every region and category.
+type TaxRate struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `json:"name"`
+	Country     string    `gorm:"index" json:"country"`
+	Region      string    `json:"region"`
+	TaxCategory string    `json:"tax_category"`
+	Rate        float64   `json:"rate"`
+	Inclusive   bool      `json:"inclusive"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// OrderDiscount is a discount line of an order. Discounts are stored apart
+// from the items so that Subtotal - DiscountAmount = TotalAmount stays auditable.
+// ProductID is set when the discount was granted to a single order item.
+type OrderDiscount struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	OrderID     uint      `json:"order_id"`
+	CouponID    *uint     `json:"coupon_id"`
+	PromotionID *uint     `json:"promotion_id"`
+	ProductID   *uint     `json:"product_id"`
+	Code        string    `json:"code"`
+	Description string    `json:"description"`
+	Amount      float64   `json:"amount"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type Coupon struct {
+	ID             uint         `gorm:"primaryKey" json:"id"`
+	Code           string       `gorm:"uniqueIndex" json:"code"`
+	Type           DiscountType `json:"type"`
+	Value          float64      `json:"value"`
+	MinOrderAmount float64      `json:"min_order_amount"`
+	UsageLimit     uint         `json:"usage_limit"`
+	PerUserLimit   uint         `json:"per_user_limit"`
+	UsedCount      uint         `json:"used_count"`
+	StartsAt       *time.Time   `json:"starts_at"`
+	EndsAt         *time.Time   `json:"ends_at"`
+	Active         bool         `json:"active"`
+	Products       []Product    `gorm:"many2many:coupon_products" json:"products"`
+	Categories     []Category   `gorm:"many2many:coupon_categories" json:"categories"`
+	CreatedAt      time.Time    `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt      time.Time    `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+type CouponUsage struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	CouponID  uint      `gorm:"index" json:"coupon_id"`
+	UserID    uint      `gorm:"index" json:"user_id"`
+	OrderID   uint      `json:"order_id"`
+	CreatedAt time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// Promotion is a discount applied automatically to every matching cart.
+// Exclusive promotions are tried first by descending Priority, and the first
+// one that applies replaces every other promotion. Otherwise the
+// non-exclusive promotions stack in order of descending Priority.
+type Promotion struct {
+	ID            uint            `gorm:"primaryKey" json:"id"`
+	Name          string          `json:"name"`
+	Description   string          `json:"description"`
+	Type          PromotionType   `json:"type"`
+	Priority      int             `json:"priority"`
+	Exclusive     bool            `json:"exclusive"`
+	Active        bool            `json:"active"`
+	StartsAt      *time.Time      `json:"starts_at"`
+	EndsAt        *time.Time      `json:"ends_at"`
+	BuyQuantity   uint            `json:"buy_quantity"`
+	GetQuantity   uint            `json:"get_quantity"`
+	DiscountType  DiscountType    `json:"discount_type"`
+	DiscountValue float64         `json:"discount_value"`
+	CategoryID    *uint           `json:"category_id"`
+	Category      *Category       `gorm:"foreignKey:CategoryID" json:"-"`
+	Products      []Product       `gorm:"many2many:promotion_products" json:"products"`
+	Tiers         []PromotionTier `gorm:"foreignKey:PromotionID" json:"tiers"`
+	CreatedAt     time.Time       `gorm:"autoCreateTime" json:"created_at"`
+	UpdatedAt     time.Time       `gorm:"autoUpdateTime" json:"updated_at"`
+}
+
+// PromotionTier is a spend threshold of a threshold promotion.
+type PromotionTier struct {
+	ID            uint    `gorm:"primaryKey" json:"id"`
+	PromotionID   uint    `json:"promotion_id"`
+	MinAmount     float64 `json:"min_amount"`
+	DiscountValue float64 `json:"discount_value"`
+}
+
+// IdempotencyKey stores the response of a request so that retries sent with
+// the same Idempotency-Key header are replayed instead of executed again.
+type IdempotencyKey struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"uniqueIndex:idx_idempotency_user_key" json:"user_id"`
+	Key         string    `gorm:"uniqueIndex:idx_idempotency_user_key" json:"key"`
+	Method      string    `json:"method"`
+	Path        string    `json:"path"`
+	Fingerprint string    `json:"fingerprint"`
+	Response    []byte    `json:"-"`
+	Completed   bool      `json:"completed"`
+	ExpiresAt   time.Time `json:"expires_at"`
+	CreatedAt   time.Time `gorm:"autoCreateTime" json:"created_at"`
+}
+
+type Notification struct {
+	ID          uint             `gorm:"primaryKey" json:"id"`
+	UserID      uint             `gorm:"index" json:"user_id"`
+	Type        NotificationType `json:"type"`
+	Message     string           `json:"message"`
+	ReferenceID uint             `json:"reference_id"`
+	ReadAt      *time.Time       `json:"read_at"`
+	CreatedAt   time.Time        `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// SlugRedirect keeps an old slug of a product or category pointing to it
+// after the slug changed.
+type SlugRedirect struct {
+	ID        uint       `gorm:"primaryKey" json:"id"`
+	Entity    SlugEntity `gorm:"uniqueIndex:idx_slug_redirect" json:"entity"`
+	Slug      string     `gorm:"uniqueIndex:idx_slug_redirect" json:"slug"`
+	EntityID  uint       `json:"entity_id"`
+	CreatedAt time.Time  `gorm:"autoCreateTime" json:"created_at"`
+}
+
+// ImportJob is a bulk product import running in the background. Rows are
+// counted as they are processed and the rows that failed are kept in Errors.
+type ImportJob struct {
+	ID         uint             `gorm:"primaryKey" json:"id"`
+	UserID     *uint            `gorm:"index" json:"user_id"`
+	FileName   string           `json:"file_name"`
+	Format     ImportFormat     `json:"format"`
+	Status     ImportStatus     `json:"status"`
+	Total      int              `json:"total"`
+	Processed  int              `json:"processed"`
+	Created    int              `json:"created"`
+	Updated    int              `json:"updated"`
+	Failed     int              `json:"failed"`
+	Errors     []ImportRowError `gorm:"serializer:json" json:"errors"`
+	Message    string           `json:"message"`
+	CreatedAt  time.Time        `gorm:"autoCreateTime" json:"created_at"`
+	FinishedAt *time.Time       `json:"finished_at"`
+}
+
+// ImportRowError is why a row of an import failed. Row is the line of a CSV
+// file, or the position in a JSON array, counting from 1.
+type ImportRowError struct {
+	Row     int    `json:"row"`
+	SKU     string `json:"sku"`
+	Message string `json:"message"`
+}
+
+// ProductFeed is the last generated export of the catalog in a format, served
+// to marketplaces from a stable URL.
+type ProductFeed struct {
+	ID           uint         `gorm:"primaryKey" json:"id"`
+	Format       ExportFormat `gorm:"uniqueIndex" json:"format"`
+	Content      []byte       `json:"-"`
+	ProductCount int          `json:"product_count"`
+	GeneratedAt  time.Time    `json:"generated_at"`
 }
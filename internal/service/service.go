@@ -1,22 +1,51 @@
 package service
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"regexp"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
+	"unicode"
+	"unicode/utf8"
 
+	"github.com/fatihesergg/go_ecommerce/internal/dto"
 	"github.com/fatihesergg/go_ecommerce/internal/model"
 	"github.com/fatihesergg/go_ecommerce/internal/storage"
 	"github.com/fatihesergg/go_ecommerce/internal/util"
+	"github.com/go-playground/validator/v10"
 	"github.com/stripe/stripe-go/v81"
 	"github.com/stripe/stripe-go/v81/paymentintent"
+	"github.com/stripe/stripe-go/v81/refund"
+	"gorm.io/gorm"
 )
 
 type CategoryService struct {
-	Repository storage.CategoryRepository
+	Repository         storage.CategoryRepository
+	RedirectRepository storage.SlugRedirectRepository
 }
 
 type ProductService struct {
-	Repository storage.ProductRepository
+	Repository          storage.ProductRepository
+	RedirectRepository  storage.SlugRedirectRepository
+	AttributeRepository storage.AttributeRepository
+	CategoryRepository  storage.CategoryRepository
+}
+
+type AttributeService struct {
+	Repository         storage.AttributeRepository
+	CategoryRepository storage.CategoryRepository
 }
 
 type UserService struct {
@@ -24,7 +53,9 @@ type UserService struct {
 }
 
 type ReviewService struct {
-	Repository storage.ReviewRepository
+	Repository      storage.ReviewRepository
+	OrderRepository storage.OrderRepository
+	Screener        ReviewScreener
 }
 
 type OrderService struct {
@@ -32,23 +63,98 @@ type OrderService struct {
 }
 
 type PaymentService struct {
-	Repository storage.PaymentRepository
+	Repository       storage.PaymentRepository
+	RefundRepository storage.RefundRepository
+}
+
+type CouponService struct {
+	Repository storage.CouponRepository
+}
+
+type PromotionService struct {
+	Repository storage.PromotionRepository
+}
+
+type TaxService struct {
+	Repository storage.TaxRateRepository
+}
+
+type AddressService struct {
+	Repository storage.AddressRepository
+}
+
+type ReturnService struct {
+	Repository storage.ReturnRepository
+}
+
+type ShipmentService struct {
+	Repository      storage.ShipmentRepository
+	OrderRepository storage.OrderRepository
+}
+
+type ImportService struct {
+	Repository         storage.ImportJobRepository
+	ProductService     ProductService
+	CategoryRepository storage.CategoryRepository
+	Validator          *validator.Validate
+}
+
+// FeedService exports the catalog. StoreURL is the public URL of the store
+// the links of the exported products start with.
+type FeedService struct {
+	Repository         storage.ProductFeedRepository
+	ProductRepository  storage.ProductRepository
+	CategoryRepository storage.CategoryRepository
+	PriceService       PriceService
+	StoreURL           string
+}
+
+type NotificationService struct {
+	Repository storage.NotificationRepository
+}
+
+type ShippingService struct {
+	ZoneRepository   storage.ShippingZoneRepository
+	MethodRepository storage.ShippingMethodRepository
+}
+
+type IdempotencyService struct {
+	Repository storage.IdempotencyRepository
+}
+
+type PriceService struct {
+	HistoryRepository  storage.PriceHistoryRepository
+	ScheduleRepository storage.ScheduledPriceRepository
+}
+
+type StockService struct {
+	Repository        storage.StockMovementRepository
+	ProductRepository storage.ProductRepository
+}
+
+type CurrencyService struct {
+	RateRepository  storage.ExchangeRateRepository
+	PriceRepository storage.ProductPriceRepository
+}
+
+func NewPaymentService(repostiory storage.PaymentRepository, refundRepository storage.RefundRepository) *PaymentService {
+	return &PaymentService{Repository: repostiory, RefundRepository: refundRepository}
 }
 
-func NewPaymentService(repostiory storage.PaymentRepository) *PaymentService {
-	return &PaymentService{Repository: repostiory}
+func NewReviewService(repository storage.ReviewRepository, orderRepository storage.OrderRepository, screener ReviewScreener) *ReviewService {
+	return &ReviewService{Repository: repository, OrderRepository: orderRepository, Screener: screener}
 }
 
-func NewReviewService(repository storage.ReviewRepository) *ReviewService {
-	return &ReviewService{Repository: repository}
+func NewProductService(repository storage.ProductRepository, redirectRepository storage.SlugRedirectRepository, attributeRepository storage.AttributeRepository, categoryRepository storage.CategoryRepository) *ProductService {
+	return &ProductService{Repository: repository, RedirectRepository: redirectRepository, AttributeRepository: attributeRepository, CategoryRepository: categoryRepository}
 }
 
-func NewProductService(repository storage.ProductRepository) *ProductService {
-	return &ProductService{Repository: repository}
+func NewAttributeService(repository storage.AttributeRepository, categoryRepository storage.CategoryRepository) *AttributeService {
+	return &AttributeService{Repository: repository, CategoryRepository: categoryRepository}
 }
 
-func NewCategoryService(repository storage.CategoryRepository) *CategoryService {
-	return &CategoryService{Repository: repository}
+func NewCategoryService(repository storage.CategoryRepository, redirectRepository storage.SlugRedirectRepository) *CategoryService {
+	return &CategoryService{Repository: repository, RedirectRepository: redirectRepository}
 }
 
 func NewUserService(repository storage.UserRepository) *UserService {
@@ -59,6 +165,62 @@ func NewOrderService(repository storage.OrderRepository) *OrderService {
 	return &OrderService{Repository: repository}
 }
 
+func NewCouponService(repository storage.CouponRepository) *CouponService {
+	return &CouponService{Repository: repository}
+}
+
+func NewPromotionService(repository storage.PromotionRepository) *PromotionService {
+	return &PromotionService{Repository: repository}
+}
+
+func NewTaxService(repository storage.TaxRateRepository) *TaxService {
+	return &TaxService{Repository: repository}
+}
+
+func NewAddressService(repository storage.AddressRepository) *AddressService {
+	return &AddressService{Repository: repository}
+}
+
+func NewReturnService(repository storage.ReturnRepository) *ReturnService {
+	return &ReturnService{Repository: repository}
+}
+
+func NewShipmentService(repository storage.ShipmentRepository, orderRepository storage.OrderRepository) *ShipmentService {
+	return &ShipmentService{Repository: repository, OrderRepository: orderRepository}
+}
+
+func NewShippingService(zoneRepository storage.ShippingZoneRepository, methodRepository storage.ShippingMethodRepository) *ShippingService {
+	return &ShippingService{ZoneRepository: zoneRepository, MethodRepository: methodRepository}
+}
+
+func NewImportService(repository storage.ImportJobRepository, productService ProductService, categoryRepository storage.CategoryRepository, validator *validator.Validate) *ImportService {
+	return &ImportService{Repository: repository, ProductService: productService, CategoryRepository: categoryRepository, Validator: validator}
+}
+
+func NewFeedService(repository storage.ProductFeedRepository, productRepository storage.ProductRepository, categoryRepository storage.CategoryRepository, priceService PriceService, storeURL string) *FeedService {
+	return &FeedService{Repository: repository, ProductRepository: productRepository, CategoryRepository: categoryRepository, PriceService: priceService, StoreURL: storeURL}
+}
+
+func NewNotificationService(repository storage.NotificationRepository) *NotificationService {
+	return &NotificationService{Repository: repository}
+}
+
+func NewIdempotencyService(repository storage.IdempotencyRepository) *IdempotencyService {
+	return &IdempotencyService{Repository: repository}
+}
+
+func NewPriceService(historyRepository storage.PriceHistoryRepository, scheduleRepository storage.ScheduledPriceRepository) *PriceService {
+	return &PriceService{HistoryRepository: historyRepository, ScheduleRepository: scheduleRepository}
+}
+
+func NewStockService(repository storage.StockMovementRepository, productRepository storage.ProductRepository) *StockService {
+	return &StockService{Repository: repository, ProductRepository: productRepository}
+}
+
+func NewCurrencyService(rateRepository storage.ExchangeRateRepository, priceRepository storage.ProductPriceRepository) *CurrencyService {
+	return &CurrencyService{RateRepository: rateRepository, PriceRepository: priceRepository}
+}
+
 // Category Service
 func (cs *CategoryService) Get(id string) (model.Category, error) {
 	return cs.Repository.Get(id)
@@ -68,9 +230,23 @@ func (cs *CategoryService) GetAll() ([]model.Category, error) {
 	return cs.Repository.GetAll()
 }
 
-func (cs *CategoryService) Create(name string) error {
-	category := model.Category{Name: name}
-	return cs.Repository.Create(category)
+func (cs *CategoryService) GetByIDs(ids []uint) ([]model.Category, error) {
+	return cs.Repository.GetByIDs(ids)
+}
+
+// Create saves a category at the root of the tree or under an existing
+// parent.
+func (cs *CategoryService) Create(category model.Category) error {
+	taken := func(slug string) (bool, error) {
+		return cs.Repository.SlugTaken(slug, 0)
+	}
+	return saveWithSlug(func() (string, error) {
+		return newSlug(category.Slug, category.Name, string(model.SLUG_CATEGORY), taken)
+	}, taken, func(slug string) error {
+		created := category
+		created.Slug = slug
+		return cs.Repository.Create(created)
+	})
 }
 
 func (cs *CategoryService) Update(category model.Category) error {
@@ -79,7 +255,138 @@ func (cs *CategoryService) Update(category model.Category) error {
 		return err
 	}
 	exist.Name = category.Name
-	return cs.Repository.Update(exist)
+	current := exist.Slug
+	taken := func(slug string) (bool, error) {
+		return cs.Repository.SlugTaken(slug, exist.ID)
+	}
+	return saveWithSlug(func() (string, error) {
+		return changedSlug(current, category.Slug, exist.Name, string(model.SLUG_CATEGORY), taken)
+	}, taken, func(slug string) error {
+		exist.Slug = slug
+		return cs.Repository.Update(exist)
+	})
+}
+
+// GetBySlug returns the category with the slug. A category found through an
+// old slug is returned with redirected set.
+func (cs *CategoryService) GetBySlug(slug string) (category model.Category, redirected bool, err error) {
+	category, err = cs.Repository.GetBySlug(slug)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return category, false, err
+	}
+	redirect, err := cs.RedirectRepository.Get(model.SLUG_CATEGORY, slug)
+	if err != nil {
+		return category, false, err
+	}
+	category, err = cs.Get(strconv.Itoa(int(redirect.EntityID)))
+	return category, true, err
+}
+
+// BackfillSlugs gives a slug to the categories created before slugs existed.
+func (cs *CategoryService) BackfillSlugs() error {
+	categories, err := cs.Repository.GetWithoutSlug()
+	if err != nil {
+		return err
+	}
+	for _, category := range categories {
+		taken := func(slug string) (bool, error) {
+			return cs.Repository.SlugTaken(slug, category.ID)
+		}
+		err := saveWithSlug(func() (string, error) {
+			return newSlug("", category.Name, string(model.SLUG_CATEGORY), taken)
+		}, taken, func(slug string) error {
+			return cs.Repository.SetSlug(category.ID, slug)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CategoryNode is a category with the categories below it.
+type CategoryNode struct {
+	model.Category
+	Children []CategoryNode `json:"children"`
+}
+
+// Tree returns the root categories with their subtrees, ordered by name.
+func (cs *CategoryService) Tree() ([]CategoryNode, error) {
+	categories, err := cs.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(categories, func(i, j int) bool {
+		return categories[i].Name < categories[j].Name
+	})
+	children := map[uint][]model.Category{}
+	roots := []model.Category{}
+	for _, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, category)
+			continue
+		}
+		children[*category.ParentID] = append(children[*category.ParentID], category)
+	}
+
+	var build func(category model.Category) CategoryNode
+	build = func(category model.Category) CategoryNode {
+		node := CategoryNode{Category: category, Children: []CategoryNode{}}
+		for _, child := range children[category.ID] {
+			node.Children = append(node.Children, build(child))
+		}
+		return node
+	}
+	result := []CategoryNode{}
+	for _, root := range roots {
+		result = append(result, build(root))
+	}
+	return result, nil
+}
+
+// Breadcrumb returns the path from the root category down to the category.
+func (cs *CategoryService) Breadcrumb(id uint) ([]model.Category, error) {
+	path, err := cs.Repository.Ancestors(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(path) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return path, nil
+}
+
+// Descendants returns the ID of the category and of all the categories below
+// it.
+func (cs *CategoryService) Descendants(id uint) ([]uint, error) {
+	ids, err := cs.Repository.Descendants(id)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return ids, nil
+}
+
+// Move puts a category with its subtree under a new parent, or at the root
+// when parentID is nil.
+func (cs *CategoryService) Move(id uint, parentID *uint) error {
+	return cs.Repository.Move(id, parentID)
+}
+
+// Delete removes a category, moving what still uses it to reassignTo when
+// given.
+func (cs *CategoryService) Delete(id uint, reassignTo *uint) (storage.CategoryDeletion, error) {
+	return cs.Repository.Delete(id, reassignTo)
+}
+
+func (cs *CategoryService) GetDeleted() ([]model.Category, error) {
+	return cs.Repository.GetDeleted()
+}
+
+func (cs *CategoryService) Restore(id uint) (model.Category, error) {
+	return cs.Repository.Restore(id)
 }
 
 // Product Service
@@ -87,15 +394,137 @@ func (ps *ProductService) Get(id string) (model.Product, error) {
 	return ps.Repository.Get(id)
 }
 
-func (ps *ProductService) GetAll() ([]model.Product, error) {
-	return ps.Repository.GetAll()
+// GetAll returns the products matching the filter. The values of attribute
+// filters are normalized the way product values are saved so that they match
+// however they are written.
+func (ps *ProductService) GetAll(filter storage.ProductFilter) ([]model.Product, error) {
+	attributes, err := ps.attributeConditions(filter.Attributes)
+	if err != nil {
+		return nil, err
+	}
+	filter.Attributes = attributes
+	return ps.Repository.GetAll(filter)
+}
+
+// attributeConditions checks the attribute filters against the attributes
+// they name and normalizes their values.
+func (ps *ProductService) attributeConditions(conditions []storage.AttributeCondition) ([]storage.AttributeCondition, error) {
+	if len(conditions) == 0 {
+		return conditions, nil
+	}
+	ids := []uint{}
+	for _, condition := range conditions {
+		ids = append(ids, condition.AttributeID)
+	}
+	definitions, err := ps.AttributeRepository.GetByIDs(ids)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[uint]model.AttributeDefinition{}
+	for _, definition := range definitions {
+		byID[definition.ID] = definition
+	}
+
+	result := []storage.AttributeCondition{}
+	for _, condition := range conditions {
+		definition, ok := byID[condition.AttributeID]
+		if !ok {
+			return nil, fmt.Errorf("%w: attribute %d doesn't exist", util.InvalidAttributeError, condition.AttributeID)
+		}
+		if (condition.Min != nil || condition.Max != nil) && definition.Type != model.ATTRIBUTE_NUMBER {
+			return nil, fmt.Errorf("%w: %s isn't a number", util.InvalidAttributeError, definition.Name)
+		}
+		values := []string{}
+		for _, value := range condition.Values {
+			value, _, err := attributeValue(definition, value)
+			if err != nil {
+				return nil, err
+			}
+			values = append(values, value)
+		}
+		condition.Values = values
+		result = append(result, condition)
+	}
+	return result, nil
+}
+
+func (ps *ProductService) GetByIDs(ids []uint) ([]model.Product, error) {
+	return ps.Repository.GetByIDs(ids)
+}
+
+// Create saves the product. Its stock is recorded as the movement.
+func (ps *ProductService) Create(product model.Product, movement model.StockMovement) error {
+	attributes, err := ps.validateAttributes(product.CategoryID, product.Attributes, false)
+	if err != nil {
+		return err
+	}
+	product.Attributes = attributes
+	product.SKU = skuOf(product.SKU)
+	if err := ps.checkSKU(product.SKU, 0); err != nil {
+		return err
+	}
+	taken := func(slug string) (bool, error) {
+		return ps.Repository.SlugTaken(slug, 0)
+	}
+	return saveWithSlug(func() (string, error) {
+		return newSlug(product.Slug, product.Name, string(model.SLUG_PRODUCT), taken)
+	}, taken, func(slug string) error {
+		created := product
+		created.Slug = slug
+		return ps.Repository.Create(created, movement)
+	})
+}
+
+// GetBySlug returns the product with the slug. A product found through an old
+// slug is returned with redirected set.
+func (ps *ProductService) GetBySlug(slug string) (product model.Product, redirected bool, err error) {
+	product, err = ps.Repository.GetBySlug(slug)
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return product, false, err
+	}
+	redirect, err := ps.RedirectRepository.Get(model.SLUG_PRODUCT, slug)
+	if err != nil {
+		return product, false, err
+	}
+	product, err = ps.Get(strconv.Itoa(int(redirect.EntityID)))
+	return product, true, err
 }
 
-func (ps *ProductService) Create(product model.Product) error {
-	return ps.Repository.Create(product)
+// BackfillSlugs gives a slug to the products created before slugs existed.
+func (ps *ProductService) BackfillSlugs() error {
+	products, err := ps.Repository.GetWithoutSlug()
+	if err != nil {
+		return err
+	}
+	for _, product := range products {
+		taken := func(slug string) (bool, error) {
+			return ps.Repository.SlugTaken(slug, product.ID)
+		}
+		err := saveWithSlug(func() (string, error) {
+			return newSlug("", product.Name, string(model.SLUG_PRODUCT), taken)
+		}, taken, func(slug string) error {
+			return ps.Repository.SetSlug(product.ID, slug)
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
+// Update saves the product. The SKU is kept unless one is given, and an empty
+// one clears it.
 func (ps ProductService) Update(product model.Product) error {
+	return ps.update(product, nil, 0)
+}
+
+// UpdateWithStock saves the product and sets its stock, recorded as the
+// movement, in one transaction.
+func (ps ProductService) UpdateWithStock(product model.Product, movement model.StockMovement, stock uint) error {
+	return ps.update(product, &movement, stock)
+}
+
+func (ps ProductService) update(product model.Product, movement *model.StockMovement, stock uint) error {
 	exist, err := ps.Get(strconv.Itoa(int(product.ID)))
 	if err != nil {
 		return err
@@ -104,15 +533,258 @@ func (ps ProductService) Update(product model.Product) error {
 	exist.ImageURL = product.ImageURL
 	exist.Name = product.Name
 	exist.Price = product.Price
-	exist.Stock = product.Stock
 	exist.CategoryID = product.CategoryID
-	return ps.Repository.Update(exist)
+	exist.TaxCategory = product.TaxCategory
+	exist.Weight = product.Weight
+	exist.Length = product.Length
+	exist.Width = product.Width
+	exist.Height = product.Height
+	exist.Description = product.Description
+	if product.SKU != nil {
+		exist.SKU = skuOf(product.SKU)
+	}
+	if err := ps.checkSKU(exist.SKU, exist.ID); err != nil {
+		return err
+	}
+	// Values kept from before are dropped when the product moved to a category
+	// they don't apply to.
+	keep := product.Attributes == nil
+	if !keep {
+		exist.Attributes = product.Attributes
+	}
+	exist.Attributes, err = ps.validateAttributes(exist.CategoryID, exist.Attributes, keep)
+	if err != nil {
+		return err
+	}
+	current := exist.Slug
+	taken := func(slug string) (bool, error) {
+		return ps.Repository.SlugTaken(slug, exist.ID)
+	}
+	return saveWithSlug(func() (string, error) {
+		return changedSlug(current, product.Slug, exist.Name, string(model.SLUG_PRODUCT), taken)
+	}, taken, func(slug string) error {
+		exist.Slug = slug
+		if movement != nil {
+			return ps.Repository.UpdateWithStock(exist, *movement, stock)
+		}
+		return ps.Repository.Update(exist)
+	})
+}
+
+func (ps *ProductService) GetBySKU(sku string) (model.Product, error) {
+	return ps.Repository.GetBySKU(sku)
+}
+
+// checkSKU returns SKUTakenError when another product has the SKU.
+func (ps *ProductService) checkSKU(sku *string, exceptID uint) error {
+	if sku == nil {
+		return nil
+	}
+	taken, err := ps.Repository.SKUTaken(*sku, exceptID)
+	if err != nil {
+		return err
+	}
+	if taken {
+		return util.SKUTakenError
+	}
+	return nil
+}
+
+// skuOf trims a SKU. Blank SKUs are nil so products without one don't clash
+// on the unique index.
+func skuOf(sku *string) *string {
+	if sku == nil {
+		return nil
+	}
+	trimmed := strings.TrimSpace(*sku)
+	if trimmed == "" {
+		return nil
+	}
+	return &trimmed
+}
+
+// validateAttributes checks the attribute values of a product against the
+// attributes of its category and of the categories above it, and returns the
+// values normalized. Empty values are dropped, and so are the values of other
+// categories when dropUnknown is set.
+func (ps *ProductService) validateAttributes(categoryID uint, values []model.ProductAttributeValue, dropUnknown bool) ([]model.ProductAttributeValue, error) {
+	definitions, err := categoryAttributes(ps.CategoryRepository, ps.AttributeRepository, categoryID)
+	if err != nil {
+		return nil, err
+	}
+	byID := map[uint]model.AttributeDefinition{}
+	for _, definition := range definitions {
+		byID[definition.ID] = definition
+	}
+
+	result := []model.ProductAttributeValue{}
+	seen := map[uint]bool{}
+	for _, value := range values {
+		definition, ok := byID[value.AttributeID]
+		if !ok && dropUnknown {
+			continue
+		}
+		if !ok {
+			return nil, fmt.Errorf("%w: attribute %d doesn't apply to the category of the product", util.InvalidAttributeError, value.AttributeID)
+		}
+		value.Value = strings.TrimSpace(value.Value)
+		if value.Value == "" {
+			continue
+		}
+		if seen[definition.ID] {
+			return nil, fmt.Errorf("%w: %s is given more than once", util.InvalidAttributeError, definition.Name)
+		}
+		seen[definition.ID] = true
+
+		value.Value, value.NumberValue, err = attributeValue(definition, value.Value)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, model.ProductAttributeValue{AttributeID: definition.ID, Value: value.Value, NumberValue: value.NumberValue})
+	}
+	for _, definition := range definitions {
+		if definition.Required && !seen[definition.ID] {
+			return nil, fmt.Errorf("%w: %s is required", util.InvalidAttributeError, definition.Name)
+		}
+	}
+	return result, nil
+}
+
+// attributeValue checks a value against the type of the attribute and returns
+// it the way it is saved: numbers and booleans in one format, with the number
+// itself, and enum values in the case of the option.
+func attributeValue(definition model.AttributeDefinition, value string) (string, *float64, error) {
+	switch definition.Type {
+	case model.ATTRIBUTE_NUMBER:
+		number, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return value, nil, fmt.Errorf("%w: %s must be a number", util.InvalidAttributeError, definition.Name)
+		}
+		return strconv.FormatFloat(number, 'f', -1, 64), &number, nil
+	case model.ATTRIBUTE_BOOLEAN:
+		flag, err := strconv.ParseBool(value)
+		if err != nil {
+			return value, nil, fmt.Errorf("%w: %s must be true or false", util.InvalidAttributeError, definition.Name)
+		}
+		return strconv.FormatBool(flag), nil, nil
+	case model.ATTRIBUTE_ENUM:
+		option := slices.IndexFunc(definition.Options, func(option string) bool {
+			return strings.EqualFold(option, value)
+		})
+		if option < 0 {
+			return value, nil, fmt.Errorf("%w: %s must be one of %s", util.InvalidAttributeError, definition.Name, strings.Join(definition.Options, ", "))
+		}
+		return definition.Options[option], nil, nil
+	}
+	return value, nil, nil
+}
+
+// categoryAttributes returns the attributes of a category and of the
+// categories above it.
+func categoryAttributes(categoryRepository storage.CategoryRepository, attributeRepository storage.AttributeRepository, categoryID uint) ([]model.AttributeDefinition, error) {
+	path, err := categoryRepository.Ancestors(categoryID)
+	if err != nil {
+		return nil, err
+	}
+	ids := []uint{}
+	for _, category := range path {
+		ids = append(ids, category.ID)
+	}
+	if len(ids) == 0 {
+		return []model.AttributeDefinition{}, nil
+	}
+	return attributeRepository.GetByCategories(ids)
+}
+
+// slugOf turns a value into a slug. Slugs are never only digits so they can't
+// be mistaken for IDs.
+func slugOf(value string, fallback string) string {
+	slug := util.Slugify(value)
+	if slug == "" {
+		return fallback
+	}
+	if strings.Trim(slug, "0123456789") == "" {
+		return fallback + "-" + slug
+	}
+	return slug
+}
+
+// newSlug returns the slug asked for, which must be free, or a free slug made
+// from the name, numbered when the name is already in use.
+func newSlug(requested string, name string, fallback string, taken func(slug string) (bool, error)) (string, error) {
+	if requested != "" {
+		slug := slugOf(requested, fallback)
+		exists, err := taken(slug)
+		if err != nil {
+			return "", err
+		}
+		if exists {
+			return "", util.SlugTakenError
+		}
+		return slug, nil
+	}
+	base := slugOf(name, fallback)
+	slug := base
+	for i := 2; ; i++ {
+		exists, err := taken(slug)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			return slug, nil
+		}
+		slug = fmt.Sprintf("%s-%d", base, i)
+	}
+}
+
+// slugAttempts is how many times a save is tried when other saves keep taking
+// the slug it picked.
+const slugAttempts = 3
+
+// saveWithSlug saves with the slug pick returns. The slug is only checked
+// before the save, so when another save takes it in between the unique index
+// rejects this one and a new slug is picked.
+func saveWithSlug(pick func() (string, error), taken func(slug string) (bool, error), save func(slug string) error) error {
+	for attempt := 1; ; attempt++ {
+		slug, err := pick()
+		if err != nil {
+			return err
+		}
+		err = save(slug)
+		if !errors.Is(err, gorm.ErrDuplicatedKey) || attempt == slugAttempts {
+			return err
+		}
+		exists, takenErr := taken(slug)
+		if takenErr != nil {
+			return takenErr
+		}
+		if !exists {
+			return err
+		}
+	}
+}
+
+// changedSlug returns the slug after an update. The current slug is kept unless
+// another one is asked for, so renaming doesn't break links.
+func changedSlug(current string, requested string, name string, fallback string, taken func(slug string) (bool, error)) (string, error) {
+	if current != "" && (requested == "" || slugOf(requested, fallback) == current) {
+		return current, nil
+	}
+	return newSlug(requested, name, fallback, taken)
 }
 
 func (ps ProductService) Delete(id string) error {
 	return ps.Repository.Delete(id)
 }
 
+func (ps *ProductService) GetDeleted() ([]model.Product, error) {
+	return ps.Repository.GetDeleted()
+}
+
+func (ps *ProductService) Restore(id uint) (model.Product, error) {
+	return ps.Repository.Restore(id)
+}
+
 // User Service
 
 func (us *UserService) Get(id string) (model.User, error) {
@@ -143,65 +815,2357 @@ func (rs *ReviewService) Get(id string) (model.Review, error) {
 	return rs.Repository.Get(id)
 }
 
+func (rs *ReviewService) GetByProduct(productID uint, filter storage.ReviewFilter) ([]model.Review, int64, error) {
+	return rs.Repository.GetByProduct(productID, filter)
+}
+
+func (rs *ReviewService) GetByStatus(status model.ReviewStatus) ([]model.Review, error) {
+	return rs.Repository.GetByStatus(status)
+}
+
+// Create saves the first review of a user for a product, badged as a
+// verified purchase when the user has bought the product. The screener
+// decides whether the review is published right away.
 func (rs *ReviewService) Create(review model.Review) error {
-	return rs.Repository.Create(review)
+	_, err := rs.Repository.GetByUserAndProduct(review.UserID, review.ProductID)
+	if err == nil {
+		return util.ReviewExistsError
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	review.VerifiedPurchase, err = rs.OrderRepository.HasPurchased(review.UserID, review.ProductID)
+	if err != nil {
+		return err
+	}
+	review.Status, review.ModerationReason = rs.Screener.Screen(review.Comment)
+	err = rs.Repository.Create(review)
+	// A review created at the same time by the same user fails on the
+	// unique index.
+	if errors.Is(err, gorm.ErrDuplicatedKey) {
+		return util.ReviewExistsError
+	}
+	return err
 }
 
+// Update changes the comment and rating of a review and screens it again. A
+// review always stays on the product it was written for. Only a review the
+// screener approved stays approved; a review a moderator decided on, or one
+// that isn't approved, waits for a moderator instead, so an edit never undoes
+// a moderator's decision.
 func (rs *ReviewService) Update(review model.Review) error {
-	return rs.Repository.Update(review)
+	exist, err := rs.Get(strconv.Itoa(int(review.ID)))
+	if err != nil {
+		return err
+	}
+	autoApproved := exist.Status == model.REVIEW_APPROVED && exist.ModeratedAt == nil
+	exist.Comment = review.Comment
+	exist.Rating = review.Rating
+	exist.Status, exist.ModerationReason = rs.Screener.Screen(exist.Comment)
+	if exist.Status == model.REVIEW_APPROVED && !autoApproved {
+		exist.Status = model.REVIEW_PENDING
+		exist.ModerationReason = "Edited after moderation"
+	}
+	return rs.Repository.Update(exist)
 }
 
-func (rs *ReviewService) Delete(id string) error {
-	return rs.Repository.Delete(id)
+// Moderate approves or rejects a review on behalf of a moderator.
+func (rs *ReviewService) Moderate(review model.Review, status model.ReviewStatus, reason string) error {
+	return rs.Repository.Moderate(review, status, reason)
 }
 
-func (os *OrderService) Get(id string) (model.Order, error) {
-	return os.Repository.Get(id)
+// REVIEW_REPORT_THRESHOLD is the number of reports that sends an approved
+// review back to the moderation queue.
+const REVIEW_REPORT_THRESHOLD = 3
+
+// Vote records whether a user found a review helpful. Authors can't vote on
+// their own reviews.
+func (rs *ReviewService) Vote(review model.Review, userID uint, helpful bool) error {
+	if review.UserID == userID {
+		return util.ReviewOwnVoteError
+	}
+	return rs.Repository.Vote(model.ReviewVote{ReviewID: review.ID, UserID: userID, Helpful: helpful})
 }
 
-func (os *OrderService) GetAll() ([]model.Order, error) {
-	return os.Repository.GetAll()
+func (rs *ReviewService) Unvote(review model.Review, userID uint) error {
+	return rs.Repository.Unvote(review.ID, userID)
 }
 
-func (os *OrderService) Create(order model.Order) error {
-	return os.Repository.Create(order)
+// Report records an abuse report of a user on a review.
+func (rs *ReviewService) Report(review model.Review, report model.ReviewReport) error {
+	if review.UserID == report.UserID {
+		return util.ReviewOwnVoteError
+	}
+	report.ReviewID = review.ID
+	return rs.Repository.Report(review, report, REVIEW_REPORT_THRESHOLD)
+}
+
+// ScreeningRule checks the comment of a review. It returns the status the
+// review should get with the reason, or REVIEW_APPROVED when the comment
+// passes the rule.
+type ScreeningRule interface {
+	Check(comment string) (model.ReviewStatus, string)
+}
+
+// ReviewScreener runs the comment of a review through its rules. The strictest
+// outcome wins: one rejection rejects the review and one hold sends it to the
+// moderation queue.
+type ReviewScreener struct {
+	Rules []ScreeningRule
+}
+
+// NewReviewScreener returns the default screening pipeline. Banned words reject
+// a review; links and comments outside the length limits hold it for a
+// moderator.
+func NewReviewScreener(bannedWords []string, minLength int, maxLength int) ReviewScreener {
+	return ReviewScreener{Rules: []ScreeningRule{
+		NewBannedWordRule(bannedWords, model.REVIEW_REJECTED),
+		LinkRule{Status: model.REVIEW_PENDING},
+		LengthRule{Min: minLength, Max: maxLength, Status: model.REVIEW_PENDING},
+	}}
+}
+
+// Screen returns the initial status of a review and the reasons of the rules
+// it failed.
+func (s ReviewScreener) Screen(comment string) (model.ReviewStatus, string) {
+	status := model.REVIEW_APPROVED
+	reasons := []string{}
+	for _, rule := range s.Rules {
+		result, reason := rule.Check(comment)
+		if result == model.REVIEW_APPROVED {
+			continue
+		}
+		reasons = append(reasons, reason)
+		if result == model.REVIEW_REJECTED || status == model.REVIEW_APPROVED {
+			status = result
+		}
+	}
+	return status, strings.Join(reasons, "; ")
+}
+
+// BannedWordRule flags comments containing any of its words. Words are
+// matched whole and case-insensitively.
+type BannedWordRule struct {
+	Words  map[string]bool
+	Status model.ReviewStatus
+}
+
+func NewBannedWordRule(words []string, status model.ReviewStatus) BannedWordRule {
+	rule := BannedWordRule{Words: map[string]bool{}, Status: status}
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" {
+			rule.Words[word] = true
+		}
+	}
+	return rule
+}
+
+func (rule BannedWordRule) Check(comment string) (model.ReviewStatus, string) {
+	words := strings.FieldsFunc(strings.ToLower(comment), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for _, word := range words {
+		if rule.Words[word] {
+			return rule.Status, "Contains banned words"
+		}
+	}
+	return model.REVIEW_APPROVED, ""
+}
+
+var linkPattern = regexp.MustCompile(`(?i)(https?://|www\.)\S+|\b[a-z0-9-]+\.(com|net|org|io|co|tr|info|biz)\b`)
+
+// LinkRule flags comments containing links.
+type LinkRule struct {
+	Status model.ReviewStatus
+}
+
+func (rule LinkRule) Check(comment string) (model.ReviewStatus, string) {
+	if linkPattern.MatchString(comment) {
+		return rule.Status, "Contains links"
+	}
+	return model.REVIEW_APPROVED, ""
+}
+
+// LengthRule flags comments shorter than Min or longer than Max characters.
+// A zero limit isn't checked.
+type LengthRule struct {
+	Min    int
+	Max    int
+	Status model.ReviewStatus
+}
+
+func (rule LengthRule) Check(comment string) (model.ReviewStatus, string) {
+	length := utf8.RuneCountInString(strings.TrimSpace(comment))
+	if rule.Min > 0 && length < rule.Min {
+		return rule.Status, fmt.Sprintf("Shorter than %d characters", rule.Min)
+	}
+	if rule.Max > 0 && length > rule.Max {
+		return rule.Status, fmt.Sprintf("Longer than %d characters", rule.Max)
+	}
+	return model.REVIEW_APPROVED, ""
+}
+
+func (rs *ReviewService) Delete(id string) error {
+	return rs.Repository.Delete(id)
+}
+
+func (rs *ReviewService) GetDeleted() ([]model.Review, error) {
+	return rs.Repository.GetDeleted()
+}
+
+func (rs *ReviewService) Restore(id uint) (model.Review, error) {
+	return rs.Repository.Restore(id)
+}
+
+// Reply posts the answer of a staff member to a review and notifies the
+// reviewer.
+func (rs *ReviewService) Reply(review model.Review, userID uint, body string) (model.ReviewReply, error) {
+	if review.Reply != nil {
+		return model.ReviewReply{}, util.ReviewReplyExistsError
+	}
+	reply := model.ReviewReply{ReviewID: review.ID, UserID: userID, Body: body}
+	notification := model.Notification{
+		UserID:      review.UserID,
+		Type:        model.NOTIFICATION_REVIEW_REPLY,
+		Message:     "The store replied to your review",
+		ReferenceID: review.ID,
+	}
+	return rs.Repository.CreateReply(reply, notification)
+}
+
+func (rs *ReviewService) UpdateReply(review model.Review, userID uint, body string) (model.ReviewReply, error) {
+	if review.Reply == nil {
+		return model.ReviewReply{}, gorm.ErrRecordNotFound
+	}
+	reply := *review.Reply
+	reply.UserID = userID
+	reply.Body = body
+	return rs.Repository.UpdateReply(reply)
+}
+
+func (rs *ReviewService) DeleteReply(review model.Review) error {
+	if review.Reply == nil {
+		return gorm.ErrRecordNotFound
+	}
+	return rs.Repository.DeleteReply(*review.Reply)
+}
+
+// Attribute Service
+
+func (as *AttributeService) Get(id string) (model.AttributeDefinition, error) {
+	return as.Repository.Get(id)
+}
+
+// GetByCategory returns the attributes products of the category have,
+// including the ones inherited from the categories above it.
+func (as *AttributeService) GetByCategory(categoryID uint) ([]model.AttributeDefinition, error) {
+	if _, err := as.CategoryRepository.Get(strconv.Itoa(int(categoryID))); err != nil {
+		return nil, err
+	}
+	return categoryAttributes(as.CategoryRepository, as.Repository, categoryID)
+}
+
+func (as *AttributeService) Create(attribute model.AttributeDefinition) (model.AttributeDefinition, error) {
+	if _, err := as.CategoryRepository.Get(strconv.Itoa(int(attribute.CategoryID))); err != nil {
+		return attribute, err
+	}
+	attribute, err := normalizeAttribute(attribute)
+	if err != nil {
+		return attribute, err
+	}
+	return as.Repository.Create(attribute)
+}
+
+// Update changes the name, unit, options and whether an attribute is
+// required. The category and type of an attribute can't change. Products lose
+// their value for an enum option that is removed.
+func (as *AttributeService) Update(attribute model.AttributeDefinition) (model.AttributeDefinition, error) {
+	exist, err := as.Get(strconv.Itoa(int(attribute.ID)))
+	if err != nil {
+		return attribute, err
+	}
+	exist.Name = attribute.Name
+	exist.Unit = attribute.Unit
+	exist.Options = attribute.Options
+	exist.Required = attribute.Required
+	exist, err = normalizeAttribute(exist)
+	if err != nil {
+		return exist, err
+	}
+	return as.Repository.Update(exist)
+}
+
+func (as *AttributeService) Delete(id string) error {
+	return as.Repository.Delete(id)
+}
+
+func (as *AttributeService) PruneOptions() error {
+	return as.Repository.PruneOptions()
+}
+
+// normalizeAttribute checks the options of an enum and clears the fields that
+// don't apply to the type of the attribute.
+func normalizeAttribute(attribute model.AttributeDefinition) (model.AttributeDefinition, error) {
+	if attribute.Type != model.ATTRIBUTE_NUMBER {
+		attribute.Unit = ""
+	}
+	if attribute.Type != model.ATTRIBUTE_ENUM {
+		attribute.Options = nil
+		return attribute, nil
+	}
+	options := []string{}
+	for _, option := range attribute.Options {
+		option = strings.TrimSpace(option)
+		if option == "" {
+			continue
+		}
+		if slices.ContainsFunc(options, func(exist string) bool { return strings.EqualFold(exist, option) }) {
+			return attribute, fmt.Errorf("%w: option %s is given more than once", util.InvalidAttributeError, option)
+		}
+		options = append(options, option)
+	}
+	if len(options) == 0 {
+		return attribute, fmt.Errorf("%w: enum attributes need at least one option", util.InvalidAttributeError)
+	}
+	attribute.Options = options
+	return attribute, nil
+}
+
+// Import Service
+
+// ImportRow is a row read from an import file. Columns are the columns the
+// file gives, the others keep their value when a product is updated. Err is
+// set when the row couldn't be read.
+type ImportRow struct {
+	Row     int
+	Data    dto.ProductImportDto
+	Columns []string
+	Err     error
+}
+
+// importColumns are the columns a CSV import may have besides the attr_<id>
+// attribute columns.
+var importColumns = []string{"sku", "name", "slug", "description", "image_url", "price", "stock", "category_id", "category", "tax_category", "weight", "length", "width", "height"}
+
+// ParseImport reads the rows of an import file. CSV files start with a header
+// naming the columns, attribute values go in attr_<id> columns. JSON files
+// are an array of rows shaped like the body of POST /product.
+func ParseImport(format model.ImportFormat, r io.Reader) ([]ImportRow, error) {
+	switch format {
+	case model.IMPORT_CSV:
+		return parseImportCSV(r)
+	case model.IMPORT_JSON:
+		return parseImportJSON(r)
+	}
+	return nil, util.ImportFormatError
+}
+
+func parseImportJSON(r io.Reader) ([]ImportRow, error) {
+	var records []json.RawMessage
+	if err := json.NewDecoder(r).Decode(&records); err != nil {
+		return nil, fmt.Errorf("%w: %v", util.ImportFileError, err)
+	}
+	rows := []ImportRow{}
+	for i, record := range records {
+		row := ImportRow{Row: i + 1}
+		var fields map[string]json.RawMessage
+		if row.Err = json.Unmarshal(record, &fields); row.Err == nil {
+			row.Err = json.Unmarshal(record, &row.Data)
+		}
+		for field := range fields {
+			row.Columns = append(row.Columns, strings.ToLower(field))
+		}
+		slices.Sort(row.Columns)
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseImportCSV(r io.Reader) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", util.ImportFileError, err)
+	}
+	for i, column := range header {
+		column = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(column, "\ufeff")))
+		id, attribute := strings.CutPrefix(column, "attr_")
+		if attribute {
+			_, err = strconv.ParseUint(id, 10, 32)
+		}
+		if (attribute && err != nil) || (!attribute && !slices.Contains(importColumns, column)) {
+			return nil, fmt.Errorf("%w: unknown column %q", util.ImportFileError, column)
+		}
+		header[i] = column
+	}
+	if !slices.Contains(header, "sku") {
+		return nil, fmt.Errorf("%w: sku column is missing", util.ImportFileError)
+	}
+
+	rows := []ImportRow{}
+	for {
+		record, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			rows = append(rows, ImportRow{Row: parseErr.StartLine, Err: parseErr.Err})
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", util.ImportFileError, err)
+		}
+		line, _ := reader.FieldPos(0)
+		row := ImportRow{Row: line, Columns: header}
+		if len(record) != len(header) {
+			row.Err = fmt.Errorf("row has %d columns, header has %d", len(record), len(header))
+		} else {
+			row.Data, row.Err = importRecord(header, record)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// importRecord maps a CSV record to a row. Empty cells are left unset.
+func importRecord(header []string, record []string) (dto.ProductImportDto, error) {
+	var data dto.ProductImportDto
+	for i, column := range header {
+		value := strings.TrimSpace(record[i])
+		if value == "" {
+			continue
+		}
+		var err error
+		switch column {
+		case "sku":
+			data.SKU = &value
+		case "name":
+			data.Name = value
+		case "slug":
+			data.Slug = value
+		case "description":
+			data.Description = value
+		case "image_url":
+			data.ImageURL = &value
+		case "price":
+			data.Price, err = strconv.ParseFloat(value, 64)
+		case "stock":
+			var stock uint64
+			stock, err = strconv.ParseUint(value, 10, 32)
+			data.Stock = uint(stock)
+		case "category_id":
+			var id uint64
+			id, err = strconv.ParseUint(value, 10, 32)
+			data.CategoryID = uint(id)
+		case "category":
+			data.Category = value
+		case "tax_category":
+			data.TaxCategory = value
+		case "weight":
+			data.Weight, err = strconv.ParseFloat(value, 64)
+		case "length":
+			data.Length, err = strconv.ParseFloat(value, 64)
+		case "width":
+			data.Width, err = strconv.ParseFloat(value, 64)
+		case "height":
+			data.Height, err = strconv.ParseFloat(value, 64)
+		default:
+			id, _ := strconv.ParseUint(strings.TrimPrefix(column, "attr_"), 10, 32)
+			data.Attributes = append(data.Attributes, dto.ProductAttributeDto{AttributeID: uint(id), Value: value})
+		}
+		if err != nil {
+			return data, fmt.Errorf("%s: invalid value %q", column, value)
+		}
+	}
+	return data, nil
+}
+
+// Create saves a pending import job for the rows.
+func (is *ImportService) Create(job model.ImportJob, rows []ImportRow) (model.ImportJob, error) {
+	job.Status = model.IMPORT_PENDING
+	job.Total = len(rows)
+	job.Errors = []model.ImportRowError{}
+	return is.Repository.Create(job)
+}
+
+// Start saves an import job for the rows and processes them in the
+// background. The job is returned right away so its progress can be followed.
+func (is *ImportService) Start(job model.ImportJob, rows []ImportRow) (model.ImportJob, error) {
+	job, err := is.Create(job, rows)
+	if err != nil {
+		return job, err
+	}
+	go is.Run(job, rows)
+	return job, nil
+}
+
+// Run processes the rows of an import job. Products are matched by SKU: known
+// SKUs are updated and new ones created. A row that fails is added to the
+// errors of the job without stopping the others, and progress is saved after
+// every row.
+func (is *ImportService) Run(job model.ImportJob, rows []ImportRow) model.ImportJob {
+	job.Status = model.IMPORT_RUNNING
+	if err := is.Repository.Update(job); err != nil {
+		return is.finish(job, err)
+	}
+	categories, err := is.CategoryRepository.GetAll()
+	if err != nil {
+		return is.finish(job, err)
+	}
+
+	for _, row := range rows {
+		created, err := is.importRow(job, categories, row)
+		switch {
+		case err != nil:
+			job.Failed++
+			rowError := model.ImportRowError{Row: row.Row, Message: err.Error()}
+			if row.Data.SKU != nil {
+				rowError.SKU = *row.Data.SKU
+			}
+			job.Errors = append(job.Errors, rowError)
+		case created:
+			job.Created++
+		default:
+			job.Updated++
+		}
+		job.Processed++
+		if err := is.Repository.Update(job); err != nil {
+			return is.finish(job, err)
+		}
+	}
+	return is.finish(job, nil)
+}
+
+func (is *ImportService) Get(id string) (model.ImportJob, error) {
+	return is.Repository.Get(id)
+}
+
+// FailUnfinished marks the jobs left pending or running by a restart as
+// failed, since their rows only lived in memory.
+func (is *ImportService) FailUnfinished() error {
+	return is.Repository.FailUnfinished("Interrupted by a restart")
+}
+
+func (is *ImportService) finish(job model.ImportJob, err error) model.ImportJob {
+	now := time.Now()
+	job.Status = model.IMPORT_COMPLETED
+	if err != nil {
+		job.Status = model.IMPORT_FAILED
+		job.Message = err.Error()
+	}
+	job.FinishedAt = &now
+	if err := is.Repository.Update(job); err != nil {
+		job.Status = model.IMPORT_FAILED
+		job.Message = err.Error()
+	}
+	return job
+}
+
+// importRow validates a row like the body of POST /product and saves it. The
+// stock of the row is recorded as an import movement. Existing products keep
+// the values of the columns the file leaves out. It reports whether the
+// product was created rather than updated.
+func (is *ImportService) importRow(job model.ImportJob, categories []model.Category, row ImportRow) (bool, error) {
+	if row.Err != nil {
+		return false, row.Err
+	}
+	data := row.Data
+	sku := skuOf(data.SKU)
+	if sku == nil {
+		return false, util.SKURequiredError
+	}
+	categoryID, err := importCategory(categories, data)
+	if err != nil {
+		return false, err
+	}
+	data.CategoryID = categoryID
+	if err := is.Validator.Struct(data); err != nil {
+		var ve validator.ValidationErrors
+		if errors.As(err, &ve) {
+			return false, errors.New(strings.ReplaceAll(strings.TrimSpace(util.GetErrorMessages(ve)), "\n", "; "))
+		}
+		return false, err
+	}
+
+	product := model.Product{
+		Name:        data.Name,
+		Slug:        data.Slug,
+		SKU:         sku,
+		ImageURL:    data.ImageURL,
+		Price:       data.Price,
+		CategoryID:  data.CategoryID,
+		Stock:       data.Stock,
+		TaxCategory: data.TaxCategory,
+		Weight:      data.Weight,
+		Length:      data.Length,
+		Width:       data.Width,
+		Height:      data.Height,
+		Description: data.Description,
+	}
+	// Rows without attributes keep the values the product already has.
+	if data.Attributes != nil {
+		product.Attributes = []model.ProductAttributeValue{}
+		for _, value := range data.Attributes {
+			product.Attributes = append(product.Attributes, model.ProductAttributeValue{AttributeID: value.AttributeID, Value: value.Value})
+		}
+	}
+
+	movement := model.StockMovement{Reason: model.STOCK_IMPORT, UserID: job.UserID, ImportJobID: &job.ID}
+	exist, err := is.ProductService.GetBySKU(*sku)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return true, is.ProductService.Create(product, movement)
+	}
+	if err != nil {
+		return false, err
+	}
+	product.ID = exist.ID
+	keep := func(column string) bool {
+		return !slices.Contains(row.Columns, column)
+	}
+	if keep("description") {
+		product.Description = exist.Description
+	}
+	if keep("image_url") {
+		product.ImageURL = exist.ImageURL
+	}
+	if keep("tax_category") {
+		product.TaxCategory = exist.TaxCategory
+	}
+	if keep("weight") {
+		product.Weight = exist.Weight
+	}
+	if keep("length") {
+		product.Length = exist.Length
+	}
+	if keep("width") {
+		product.Width = exist.Width
+	}
+	if keep("height") {
+		product.Height = exist.Height
+	}
+	movement.ProductID = exist.ID
+	return false, is.ProductService.UpdateWithStock(product, movement, data.Stock)
+}
+
+// importCategory returns the category of a row, given by category_id or by
+// the ID or name of the category column. Names must match a single category.
+func importCategory(categories []model.Category, data dto.ProductImportDto) (uint, error) {
+	reference := data.Category
+	if data.CategoryID != 0 {
+		reference = strconv.Itoa(int(data.CategoryID))
+	}
+	if reference == "" {
+		return 0, nil
+	}
+	if id, err := strconv.Atoi(reference); err == nil {
+		for _, category := range categories {
+			if category.ID == uint(id) {
+				return category.ID, nil
+			}
+		}
+		return 0, fmt.Errorf("%w: no category with id %d", util.ImportCategoryError, id)
+	}
+	var matches []uint
+	for _, category := range categories {
+		if strings.EqualFold(category.Name, reference) {
+			matches = append(matches, category.ID)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return 0, fmt.Errorf("%w: no category named %q", util.ImportCategoryError, reference)
+	case 1:
+		return matches[0], nil
+	}
+	return 0, fmt.Errorf("%w: more than one category is named %q, use its id", util.ImportCategoryError, reference)
+}
+
+// Feed Service
+
+// Export returns the catalog in a format, built from the current products.
+func (fs *FeedService) Export(format model.ExportFormat) ([]byte, int, error) {
+	if !slices.Contains([]model.ExportFormat{model.EXPORT_CSV, model.EXPORT_JSON, model.EXPORT_XML}, format) {
+		return nil, 0, util.ExportFormatError
+	}
+	products, err := fs.exportProducts()
+	if err != nil {
+		return nil, 0, err
+	}
+	var content []byte
+	switch format {
+	case model.EXPORT_CSV:
+		content, err = exportCSV(products)
+	case model.EXPORT_JSON:
+		content, err = json.Marshal(products)
+	case model.EXPORT_XML:
+		content, err = fs.exportXML(products)
+	}
+	return content, len(products), err
+}
+
+// Generate builds the feed of a format and saves it so it's served until the
+// next generation.
+func (fs *FeedService) Generate(format model.ExportFormat) (model.ProductFeed, error) {
+	content, count, err := fs.Export(format)
+	if err != nil {
+		return model.ProductFeed{}, err
+	}
+	return fs.Repository.Save(model.ProductFeed{Format: format, Content: content, ProductCount: count, GeneratedAt: time.Now()})
+}
+
+// GenerateAll builds the feeds of every format.
+func (fs *FeedService) GenerateAll() ([]model.ProductFeed, error) {
+	feeds := []model.ProductFeed{}
+	for _, format := range []model.ExportFormat{model.EXPORT_CSV, model.EXPORT_JSON, model.EXPORT_XML} {
+		feed, err := fs.Generate(format)
+		if err != nil {
+			return feeds, err
+		}
+		feeds = append(feeds, feed)
+	}
+	return feeds, nil
+}
+
+// Get returns the saved feed of a format, generating it when it was never
+// generated.
+func (fs *FeedService) Get(format model.ExportFormat) (model.ProductFeed, error) {
+	feed, err := fs.Repository.Get(format)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fs.Generate(format)
+	}
+	return feed, err
+}
+
+// exportProducts returns the products with their category paths, absolute
+// URLs and sale prices.
+func (fs *FeedService) exportProducts() ([]dto.ProductExportDto, error) {
+	categories, err := fs.CategoryRepository.GetAll()
+	if err != nil {
+		return nil, err
+	}
+	products, err := fs.ProductRepository.GetAll(storage.ProductFilter{})
+	if err != nil {
+		return nil, err
+	}
+	if err := fs.PriceService.Annotate(products); err != nil {
+		return nil, err
+	}
+	byID := map[uint]model.Category{}
+	for _, category := range categories {
+		byID[category.ID] = category
+	}
+
+	result := []dto.ProductExportDto{}
+	for _, product := range products {
+		item := dto.ProductExportDto{
+			ID:           product.ID,
+			Name:         product.Name,
+			Slug:         product.Slug,
+			Description:  product.Description,
+			URL:          fs.absoluteURL("/product/" + product.Slug),
+			Price:        product.Price,
+			Currency:     model.BaseCurrency,
+			Availability: "in_stock",
+			CategoryID:   product.CategoryID,
+			CategoryPath: categoryPath(byID, product.CategoryID),
+		}
+		if product.SKU != nil {
+			item.SKU = *product.SKU
+		}
+		if product.ImageURL != nil && *product.ImageURL != "" {
+			item.ImageURL = fs.absoluteURL(*product.ImageURL)
+		}
+		if product.WasPrice != nil {
+			salePrice := product.Price
+			item.Price = *product.WasPrice
+			item.SalePrice = &salePrice
+		}
+		if product.Stock == 0 {
+			item.Availability = "out_of_stock"
+		}
+		result = append(result, item)
+	}
+	return result, nil
+}
+
+// absoluteURL prefixes the store URL to paths. URLs that are already
+// absolute are kept.
+func (fs *FeedService) absoluteURL(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+	return strings.TrimSuffix(fs.StoreURL, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// categoryPath returns the names of a category and of the categories above
+// it, from the root down, joined by " > ".
+func categoryPath(categories map[uint]model.Category, id uint) string {
+	names := []string{}
+	seen := map[uint]bool{}
+	category, ok := categories[id]
+	for ok && !seen[category.ID] {
+		seen[category.ID] = true
+		names = append([]string{category.Name}, names...)
+		if category.ParentID == nil {
+			break
+		}
+		category, ok = categories[*category.ParentID]
+	}
+	return strings.Join(names, " > ")
+}
+
+func exportCSV(products []dto.ProductExportDto) ([]byte, error) {
+	var buffer bytes.Buffer
+	writer := csv.NewWriter(&buffer)
+	writer.Write([]string{"id", "sku", "name", "slug", "description", "url", "image_url", "price", "sale_price", "currency", "availability", "category_id", "category_path"})
+	for _, product := range products {
+		salePrice := ""
+		if product.SalePrice != nil {
+			salePrice = strconv.FormatFloat(*product.SalePrice, 'f', 2, 64)
+		}
+		writer.Write([]string{
+			strconv.Itoa(int(product.ID)),
+			product.SKU,
+			product.Name,
+			product.Slug,
+			product.Description,
+			product.URL,
+			product.ImageURL,
+			strconv.FormatFloat(product.Price, 'f', 2, 64),
+			salePrice,
+			product.Currency,
+			product.Availability,
+			strconv.Itoa(int(product.CategoryID)),
+			product.CategoryPath,
+		})
+	}
+	writer.Flush()
+	return buffer.Bytes(), writer.Error()
+}
+
+func (fs *FeedService) exportXML(products []dto.ProductExportDto) ([]byte, error) {
+	feed := dto.MerchantFeedDto{
+		Version: "2.0",
+		G:       "http://base.google.com/ns/1.0",
+		Channel: dto.MerchantFeedChannelDto{
+			Title:       "Products",
+			Link:        fs.StoreURL,
+			Description: "Product feed",
+			Items:       []dto.MerchantFeedItemDto{},
+		},
+	}
+	for _, product := range products {
+		item := dto.MerchantFeedItemDto{
+			ID:           strconv.Itoa(int(product.ID)),
+			Title:        product.Name,
+			Description:  product.Description,
+			Link:         product.URL,
+			ImageLink:    product.ImageURL,
+			Availability: product.Availability,
+			Price:        fmt.Sprintf("%.2f %s", product.Price, product.Currency),
+			ProductType:  product.CategoryPath,
+			MPN:          product.SKU,
+			Condition:    "new",
+		}
+		if product.SalePrice != nil {
+			item.SalePrice = fmt.Sprintf("%.2f %s", *product.SalePrice, product.Currency)
+		}
+		if item.Description == "" {
+			item.Description = product.Name
+		}
+		if item.MPN == "" {
+			item.IdentifierExists = "no"
+		}
+		feed.Channel.Items = append(feed.Channel.Items, item)
+	}
+	content, err := xml.MarshalIndent(feed, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), content...), nil
+}
+
+// Notification Service
+
+func (ns *NotificationService) GetByUser(userID uint, page int, pageSize int) ([]model.Notification, int64, error) {
+	return ns.Repository.GetByUser(userID, page, pageSize)
+}
+
+func (ns *NotificationService) CountUnread(userID uint) (int64, error) {
+	return ns.Repository.CountUnread(userID)
+}
+
+func (ns *NotificationService) MarkRead(id uint, userID uint) error {
+	return ns.Repository.MarkRead(id, userID)
+}
+
+func (ns *NotificationService) MarkAllRead(userID uint) error {
+	return ns.Repository.MarkAllRead(userID)
+}
+
+func (os *OrderService) Get(id string) (model.Order, error) {
+	return os.Repository.Get(id)
+}
+
+func (os *OrderService) GetAll() ([]model.Order, error) {
+	return os.Repository.GetAll()
+}
+
+func (os *OrderService) Create(order model.Order) (model.Order, error) {
+	return os.Repository.Create(order)
 }
 
 func (os *OrderService) Update(order model.Order) error {
 	return os.Repository.Update(order)
 }
 
-func (ps *PaymentService) SavePayment(model model.Payment) error {
-	return ps.Repository.Create(model)
+// BackfillSnapshots captures the product name and SKU of the order items
+// saved before they were captured at checkout.
+func (os *OrderService) BackfillSnapshots() error {
+	return os.Repository.BackfillSnapshots()
+}
+
+// Cancel cancels a pending order, puts its items back in stock and releases
+// its coupons.
+func (os *OrderService) Cancel(id uint, actorID uint) error {
+	return os.Repository.Cancel(id, &actorID)
+}
+
+// ExpirePending cancels the orders left pending for longer than maxAge, so
+// the stock and coupons of abandoned checkouts come back. Orders paid or
+// cancelled in the meantime are skipped. It returns how many were cancelled.
+func (os *OrderService) ExpirePending(maxAge time.Duration) (int, error) {
+	ids, err := os.Repository.GetExpired(time.Now().Add(-maxAge))
+	if err != nil {
+		return 0, err
+	}
+	expired := 0
+	for _, id := range ids {
+		err := os.Repository.Cancel(id, nil)
+		if errors.Is(err, util.OrderNotCancellableError) {
+			continue
+		}
+		if err != nil {
+			return expired, err
+		}
+		expired++
+	}
+	return expired, nil
+}
+
+func (os *OrderService) UpdateStatus(id uint, from model.OrderStatus, to model.OrderStatus) (bool, error) {
+	return os.Repository.UpdateStatus(id, from, to)
+}
+
+func (ps *PaymentService) SavePayment(model model.Payment) error {
+	return ps.Repository.Create(model)
+}
+
+// IsPaid reports whether the order already has a successful payment.
+func (ps *PaymentService) IsPaid(order model.Order) (bool, error) {
+	if order.Status == model.ORDER_PAID {
+		return true, nil
+	}
+	_, err := ps.Repository.GetSuccessfulByOrder(order.ID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// GetByOrder returns the successful payment of an order.
+func (ps *PaymentService) GetByOrder(orderID uint) (model.Payment, error) {
+	return ps.Repository.GetSuccessfulByOrder(orderID)
+}
+
+// Refund sends back part of a successful payment through Stripe and records
+// the refund, whether it succeeded or not. Refunds of a return are sent with
+// an idempotency key of the return, so it is never refunded twice. An error
+// wrapping util.RefundNotRecordedError means Stripe issued the refund.
+func (ps *PaymentService) Refund(payment model.Payment, amount float64, returnID *uint) (model.Refund, error) {
+	refunded, err := ps.RefundRepository.RefundedAmount(payment.ID)
+	if err != nil {
+		return model.Refund{}, err
+	}
+	if util.RoundAmount(refunded+amount) > payment.Amount {
+		return model.Refund{}, util.RefundLimitError
+	}
+
+	record := model.Refund{
+		PaymentID:       payment.ID,
+		ReturnRequestID: returnID,
+		Amount:          amount,
+		Currency:        payment.Currency,
+	}
+	params := stripe.RefundParams{
+		PaymentIntent: stripe.String(payment.TransactionId),
+		Amount:        stripe.Int64(toMinorUnits(amount, payment.Currency)),
+	}
+	if returnID != nil {
+		params.SetIdempotencyKey(fmt.Sprintf("return-%d", *returnID))
+	}
+	result, err := refund.New(&params)
+	if err != nil {
+		record.Status = model.FAILED
+		if _, err := ps.RefundRepository.Create(record); err != nil {
+			return model.Refund{}, err
+		}
+		return model.Refund{}, err
+	}
+	record.Status = model.SUCCESS
+	record.TransactionId = result.ID
+	created, err := ps.RefundRepository.Create(record)
+	if err != nil {
+		return record, fmt.Errorf("%w: %v", util.RefundNotRecordedError, err)
+	}
+	return created, nil
+}
+
+// ProcessPayment charges the payment and saves it. An error wrapping
+// util.PaymentFailedError means the card was declined and nothing was charged;
+// any other error leaves the outcome of the charge unknown. Only declines
+// start a new attempt, so an order retried after an unknown outcome reuses its
+// idempotency key and isn't charged twice.
+func (ps *PaymentService) ProcessPayment(payment model.Payment) error {
+	payment.Status = model.PENDING
+	payment.CreatedAt = time.Now()
+	payment.UpdatedAt = time.Now()
+
+	if payment.Currency == "" {
+		payment.Currency = model.BaseCurrency
+	}
+
+	params := stripe.PaymentIntentParams{
+		Amount:                  stripe.Int64(toMinorUnits(payment.Amount, payment.Currency)),
+		Currency:                stripe.String(strings.ToLower(payment.Currency)),
+		PaymentMethod:           stripe.String("pm_card_visa"), // Test
+		Confirm:                 stripe.Bool(true),
+		AutomaticPaymentMethods: &stripe.PaymentIntentAutomaticPaymentMethodsParams{AllowRedirects: stripe.String("never"), Enabled: stripe.Bool(true)},
+	}
+	failed, err := ps.Repository.CountFailedByOrder(payment.OrderID)
+	if err != nil {
+		return err
+	}
+	params.SetIdempotencyKey(fmt.Sprintf("order-%d-%d", payment.OrderID, failed+1))
+	result, err := paymentintent.New(&params)
+	if err != nil {
+		if !isDecline(err) {
+			return err
+		}
+		payment.Status = model.FAILED
+		payment.UpdatedAt = time.Now()
+		if saveErr := ps.SavePayment(payment); saveErr != nil {
+			return fmt.Errorf("%w: %v (%v)", util.PaymentFailedError, err, saveErr)
+		}
+		return fmt.Errorf("%w: %v", util.PaymentFailedError, err)
+	}
+	payment.Status = model.SUCCESS
+	payment.UpdatedAt = time.Now()
+	payment.TransactionId = result.ID
+	if err := ps.SavePayment(payment); err != nil {
+		return err
+	}
+	return nil
+}
+
+// isDecline reports whether Stripe definitively refused the charge, as
+// opposed to failing in a way that leaves its outcome unknown.
+func isDecline(err error) bool {
+	var stripeErr *stripe.Error
+	return errors.As(err, &stripeErr) && stripeErr.Type == stripe.ErrorTypeCard
+}
+
+// Coupon Service
+
+func (cs *CouponService) Get(id string) (model.Coupon, error) {
+	return cs.Repository.Get(id)
+}
+
+func (cs *CouponService) GetByCode(code string) (model.Coupon, error) {
+	return cs.Repository.GetByCode(strings.ToUpper(strings.TrimSpace(code)))
+}
+
+func (cs *CouponService) GetAll() ([]model.Coupon, error) {
+	return cs.Repository.GetAll()
+}
+
+func (cs *CouponService) Create(coupon model.Coupon) error {
+	if err := checkCouponValue(coupon); err != nil {
+		return err
+	}
+	coupon.Code = strings.ToUpper(strings.TrimSpace(coupon.Code))
+	return cs.Repository.Create(coupon)
+}
+
+func (cs *CouponService) Update(coupon model.Coupon) error {
+	if err := checkCouponValue(coupon); err != nil {
+		return err
+	}
+	exist, err := cs.Get(strconv.Itoa(int(coupon.ID)))
+	if err != nil {
+		return err
+	}
+	coupon.Code = strings.ToUpper(strings.TrimSpace(coupon.Code))
+	coupon.UsedCount = exist.UsedCount
+	coupon.CreatedAt = exist.CreatedAt
+	return cs.Repository.Update(coupon)
+}
+
+func (cs *CouponService) Delete(id string) error {
+	return cs.Repository.Delete(id)
+}
+
+// checkCouponValue rejects percentage coupons taking more than the whole
+// amount off.
+func checkCouponValue(coupon model.Coupon) error {
+	if coupon.Type == model.DISCOUNT_PERCENTAGE && coupon.Value > 100 {
+		return util.CouponValueError
+	}
+	return nil
+}
+
+// Apply checks a coupon against the order items of a user and returns the
+// discount line it grants. The discount is spread over the DiscountAmount of
+// the eligible items. Amounts of items are in the order currency and rate
+// converts the coupon's base currency amounts into it.
+func (cs *CouponService) Apply(code string, userID uint, items []model.OrderItem, rate float64) (model.OrderDiscount, error) {
+	coupon, err := cs.GetByCode(code)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.OrderDiscount{}, util.CouponNotFoundError
+		}
+		return model.OrderDiscount{}, err
+	}
+
+	now := time.Now()
+	if !coupon.Active || (coupon.StartsAt != nil && now.Before(*coupon.StartsAt)) || (coupon.EndsAt != nil && now.After(*coupon.EndsAt)) {
+		return model.OrderDiscount{}, util.CouponNotActiveError
+	}
+	if coupon.UsageLimit > 0 && coupon.UsedCount >= coupon.UsageLimit {
+		return model.OrderDiscount{}, util.CouponUsageLimitError
+	}
+	if coupon.PerUserLimit > 0 {
+		used, err := cs.Repository.CountUsages(coupon.ID, userID)
+		if err != nil {
+			return model.OrderDiscount{}, err
+		}
+		if used >= int64(coupon.PerUserLimit) {
+			return model.OrderDiscount{}, util.CouponUserLimitError
+		}
+	}
+
+	products := map[uint]bool{}
+	for _, product := range coupon.Products {
+		products[product.ID] = true
+	}
+	categories := map[uint]bool{}
+	for _, category := range coupon.Categories {
+		categories[category.ID] = true
+	}
+	restricted := len(products) > 0 || len(categories) > 0
+
+	var subtotal, eligible float64
+	var lines []int
+	for i, item := range items {
+		amount := lineAmount(item)
+		subtotal += amount
+		if !restricted || products[item.ProductID] || categories[item.Product.CategoryID] {
+			eligible += amount
+			lines = append(lines, i)
+		}
+	}
+	if subtotal < util.RoundAmount(coupon.MinOrderAmount*rate) {
+		return model.OrderDiscount{}, util.CouponMinimumNotMetError
+	}
+	if eligible == 0 {
+		return model.OrderDiscount{}, util.CouponNotApplicableError
+	}
+
+	var amount float64
+	var description string
+	switch coupon.Type {
+	case model.DISCOUNT_PERCENTAGE:
+		amount = eligible * coupon.Value / 100
+		description = fmt.Sprintf("Coupon %s (%g%% off)", coupon.Code, coupon.Value)
+	default:
+		amount = math.Min(coupon.Value*rate, eligible)
+		description = fmt.Sprintf("Coupon %s", coupon.Code)
+	}
+
+	return model.OrderDiscount{
+		CouponID:    &coupon.ID,
+		Code:        coupon.Code,
+		Description: description,
+		Amount:      allocateDiscount(items, lines, amount),
+	}, nil
+}
+
+// lineAmount is the amount of an order item left after its discounts.
+func lineAmount(item model.OrderItem) float64 {
+	return item.UnitPrice*float64(item.Quantity) - item.DiscountAmount
+}
+
+// allocateDiscount spreads amount over the given items in proportion to their
+// remaining amount and returns the part of amount that could be allocated.
+func allocateDiscount(items []model.OrderItem, lines []int, amount float64) float64 {
+	var base float64
+	for _, i := range lines {
+		base += lineAmount(items[i])
+	}
+	if base <= 0 || amount <= 0 {
+		return 0
+	}
+	amount = util.RoundAmount(math.Min(amount, base))
+	left := amount
+	for n, i := range lines {
+		share := left
+		if n < len(lines)-1 {
+			share = math.Min(util.RoundAmount(amount*lineAmount(items[i])/base), left)
+		}
+		items[i].DiscountAmount = util.RoundAmount(items[i].DiscountAmount + share)
+		left = util.RoundAmount(left - share)
+	}
+	return amount
+}
+
+// Promotion Service
+
+func (ps *PromotionService) Get(id string) (model.Promotion, error) {
+	return ps.Repository.Get(id)
+}
+
+func (ps *PromotionService) GetAll() ([]model.Promotion, error) {
+	return ps.Repository.GetAll()
+}
+
+func (ps *PromotionService) Create(promotion model.Promotion) error {
+	return ps.Repository.Create(promotion)
+}
+
+func (ps *PromotionService) Update(promotion model.Promotion) error {
+	exist, err := ps.Get(strconv.Itoa(int(promotion.ID)))
+	if err != nil {
+		return err
+	}
+	promotion.CreatedAt = exist.CreatedAt
+	return ps.Repository.Update(promotion)
+}
+
+func (ps *PromotionService) Delete(id string) error {
+	return ps.Repository.Delete(id)
+}
+
+// Apply evaluates the running promotions against the order items, spreads
+// the discounts over the items and returns one discount line per promotion
+// and item explaining what was applied.
+func (ps *PromotionService) Apply(items []model.OrderItem, rate float64) ([]model.OrderDiscount, error) {
+	promotions, err := ps.Repository.GetRunning(time.Now())
+	if err != nil {
+		return nil, err
+	}
+	return ApplyPromotions(promotions, items, rate), nil
+}
+
+// ApplyPromotions evaluates promotions by descending priority. Exclusive
+// promotions are evaluated first and the first one that applies is the only
+// promotion of the order; otherwise the non-exclusive promotions stack.
+func ApplyPromotions(promotions []model.Promotion, items []model.OrderItem, rate float64) []model.OrderDiscount {
+	sort.SliceStable(promotions, func(i, j int) bool {
+		return promotions[i].Priority > promotions[j].Priority
+	})
+
+	for _, promotion := range promotions {
+		if !promotion.Exclusive {
+			continue
+		}
+		// The promotion is tried on a copy so the discounts of one that
+		// doesn't apply aren't left on the items.
+		trial := slices.Clone(items)
+		if applied := applyPromotion(promotion, trial, rate); len(applied) > 0 {
+			copy(items, trial)
+			return applied
+		}
+	}
+
+	var discounts []model.OrderDiscount
+	for _, promotion := range promotions {
+		if !promotion.Exclusive {
+			discounts = append(discounts, applyPromotion(promotion, items, rate)...)
+		}
+	}
+	return discounts
+}
+
+// applyPromotion applies a promotion of any type to the items.
+func applyPromotion(promotion model.Promotion, items []model.OrderItem, rate float64) []model.OrderDiscount {
+	switch promotion.Type {
+	case model.PROMOTION_BUY_X_GET_Y:
+		return applyBuyXGetY(promotion, items)
+	case model.PROMOTION_CATEGORY_SALE:
+		return applyCategorySale(promotion, items, rate)
+	case model.PROMOTION_BUNDLE:
+		return applyBundle(promotion, items, rate)
+	case model.PROMOTION_THRESHOLD:
+		return applyThreshold(promotion, items, rate)
+	}
+	return nil
+}
+
+func promotionDiscount(promotion model.Promotion, productID *uint, amount float64, detail string) model.OrderDiscount {
+	id := promotion.ID
+	return model.OrderDiscount{
+		PromotionID: &id,
+		ProductID:   productID,
+		Description: fmt.Sprintf("%s: %s", promotion.Name, detail),
+		Amount:      amount,
+	}
+}
+
+// discountValue computes a percentage or fixed discount on amount. Fixed
+// values are in the base currency and are multiplied by units.
+func discountValue(discountType model.DiscountType, value float64, amount float64, units float64, rate float64) float64 {
+	if discountType == model.DISCOUNT_PERCENTAGE {
+		return amount * value / 100
+	}
+	return value * rate * units
+}
+
+func describeDiscount(discountType model.DiscountType, value float64) string {
+	if discountType == model.DISCOUNT_PERCENTAGE {
+		return fmt.Sprintf("%g%% off", value)
+	}
+	return fmt.Sprintf("%g %s off", value, model.BaseCurrency)
+}
+
+func applyBuyXGetY(promotion model.Promotion, items []model.OrderItem) []model.OrderDiscount {
+	group := int(promotion.BuyQuantity + promotion.GetQuantity)
+	if promotion.BuyQuantity == 0 || promotion.GetQuantity == 0 {
+		return nil
+	}
+	products := map[uint]bool{}
+	for _, product := range promotion.Products {
+		products[product.ID] = true
+	}
+
+	var discounts []model.OrderDiscount
+	for i := range items {
+		item := items[i]
+		if len(products) > 0 && !products[item.ProductID] {
+			continue
+		}
+		if promotion.CategoryID != nil && item.Product.CategoryID != *promotion.CategoryID {
+			continue
+		}
+		free := item.Quantity / group * int(promotion.GetQuantity)
+		if free == 0 {
+			continue
+		}
+		amount := allocateDiscount(items, []int{i}, item.UnitPrice*float64(free))
+		if amount > 0 {
+			detail := fmt.Sprintf("buy %d get %d free (%d free)", promotion.BuyQuantity, promotion.GetQuantity, free)
+			discounts = append(discounts, promotionDiscount(promotion, &item.ProductID, amount, detail))
+		}
+	}
+	return discounts
+}
+
+func applyCategorySale(promotion model.Promotion, items []model.OrderItem, rate float64) []model.OrderDiscount {
+	if promotion.CategoryID == nil {
+		return nil
+	}
+	var discounts []model.OrderDiscount
+	for i := range items {
+		item := items[i]
+		if item.Product.CategoryID != *promotion.CategoryID {
+			continue
+		}
+		value := discountValue(promotion.DiscountType, promotion.DiscountValue, lineAmount(item), float64(item.Quantity), rate)
+		amount := allocateDiscount(items, []int{i}, value)
+		if amount > 0 {
+			discounts = append(discounts, promotionDiscount(promotion, &item.ProductID, amount, describeDiscount(promotion.DiscountType, promotion.DiscountValue)))
+		}
+	}
+	return discounts
+}
+
+func applyBundle(promotion model.Promotion, items []model.OrderItem, rate float64) []model.OrderDiscount {
+	if len(promotion.Products) < 2 {
+		return nil
+	}
+	lines := map[uint]int{}
+	for i, item := range items {
+		lines[item.ProductID] = i
+	}
+
+	// The number of complete bundles is limited by the scarcest product.
+	bundles := -1
+	for _, product := range promotion.Products {
+		i, ok := lines[product.ID]
+		if !ok {
+			return nil
+		}
+		if bundles == -1 || items[i].Quantity < bundles {
+			bundles = items[i].Quantity
+		}
+	}
+	if bundles <= 0 {
+		return nil
+	}
+
+	var bundleAmount float64
+	for _, product := range promotion.Products {
+		item := items[lines[product.ID]]
+		bundleAmount += lineAmount(item) * float64(bundles) / float64(item.Quantity)
+	}
+	value := math.Min(discountValue(promotion.DiscountType, promotion.DiscountValue, bundleAmount, float64(bundles), rate), bundleAmount)
+
+	var discounts []model.OrderDiscount
+	for _, product := range promotion.Products {
+		i := lines[product.ID]
+		share := lineAmount(items[i]) * float64(bundles) / float64(items[i].Quantity)
+		amount := allocateDiscount(items, []int{i}, value*share/bundleAmount)
+		if amount > 0 {
+			detail := fmt.Sprintf("bundle of %d products, %s (%d bundles)", len(promotion.Products), describeDiscount(promotion.DiscountType, promotion.DiscountValue), bundles)
+			discounts = append(discounts, promotionDiscount(promotion, &items[i].ProductID, amount, detail))
+		}
+	}
+	return discounts
+}
+
+func applyThreshold(promotion model.Promotion, items []model.OrderItem, rate float64) []model.OrderDiscount {
+	var total float64
+	var lines []int
+	for i, item := range items {
+		total += lineAmount(item)
+		lines = append(lines, i)
+	}
+
+	// Use the highest tier the cart reaches.
+	var tier *model.PromotionTier
+	for i := range promotion.Tiers {
+		if total >= util.RoundAmount(promotion.Tiers[i].MinAmount*rate) && (tier == nil || promotion.Tiers[i].MinAmount > tier.MinAmount) {
+			tier = &promotion.Tiers[i]
+		}
+	}
+	if tier == nil {
+		return nil
+	}
+
+	value := discountValue(promotion.DiscountType, tier.DiscountValue, total, 1, rate)
+	amount := allocateDiscount(items, lines, value)
+	if amount <= 0 {
+		return nil
+	}
+	detail := fmt.Sprintf("spend over %g %s, %s", tier.MinAmount, model.BaseCurrency, describeDiscount(promotion.DiscountType, tier.DiscountValue))
+	return []model.OrderDiscount{promotionDiscount(promotion, nil, amount, detail)}
+}
+
+// Tax Service
+
+// TaxCalculator computes the tax lines of an order item shipped to a
+// country and region. Taxes are charged on the item amount after discounts.
+type TaxCalculator interface {
+	Calculate(country string, region string, item model.OrderItem) []model.OrderItemTax
+}
+
+// TableTaxCalculator looks taxes up in a table of rates. The most specific
+// rows matching the item win: a region beats the whole country and a tax
+// category beats the default rows. All rows at the winning level apply, so
+// a region can charge several taxes.
+type TableTaxCalculator struct {
+	Rates []model.TaxRate
+}
+
+func (tc TableTaxCalculator) Calculate(country string, region string, item model.OrderItem) []model.OrderItemTax {
+	levels := []struct{ region, category string }{
+		{region, item.Product.TaxCategory},
+		{"", item.Product.TaxCategory},
+		{region, ""},
+		{"", ""},
+	}
+	var rates []model.TaxRate
+	for _, level := range levels {
+		for _, rate := range tc.Rates {
+			if strings.EqualFold(rate.Country, country) && strings.EqualFold(rate.Region, level.region) && rate.TaxCategory == level.category {
+				rates = append(rates, rate)
+			}
+		}
+		if len(rates) > 0 {
+			break
+		}
+	}
+
+	base := lineAmount(item)
+	var inclusiveRate float64
+	for _, rate := range rates {
+		if rate.Inclusive {
+			inclusiveRate += rate.Rate
+		}
+	}
+	// Inclusive taxes are extracted from the amount before exclusive taxes
+	// are added on the net amount.
+	net := base / (1 + inclusiveRate/100)
+
+	var taxes []model.OrderItemTax
+	for _, rate := range rates {
+		taxes = append(taxes, model.OrderItemTax{
+			Name:      rate.Name,
+			Rate:      rate.Rate,
+			Inclusive: rate.Inclusive,
+			Amount:    util.RoundAmount(net * rate.Rate / 100),
+		})
+	}
+	return taxes
+}
+
+func (ts *TaxService) Get(id string) (model.TaxRate, error) {
+	return ts.Repository.Get(id)
+}
+
+func (ts *TaxService) GetAll() ([]model.TaxRate, error) {
+	return ts.Repository.GetAll()
+}
+
+func (ts *TaxService) Create(rate model.TaxRate) error {
+	rate.Country = strings.ToUpper(rate.Country)
+	return ts.Repository.Create(rate)
+}
+
+func (ts *TaxService) Update(rate model.TaxRate) error {
+	exist, err := ts.Get(strconv.Itoa(int(rate.ID)))
+	if err != nil {
+		return err
+	}
+	rate.Country = strings.ToUpper(rate.Country)
+	rate.CreatedAt = exist.CreatedAt
+	return ts.Repository.Update(rate)
+}
+
+func (ts *TaxService) Delete(id string) error {
+	return ts.Repository.Delete(id)
+}
+
+// Calculator returns a calculator loaded with the rates of a country.
+func (ts *TaxService) Calculator(country string) (TaxCalculator, error) {
+	rates, err := ts.Repository.GetByCountry(strings.ToUpper(country))
+	if err != nil {
+		return nil, err
+	}
+	return TableTaxCalculator{Rates: rates}, nil
+}
+
+// Apply stores the tax lines, tax amount and total of every order item.
+func (ts *TaxService) Apply(country string, region string, items []model.OrderItem) error {
+	var calculator TaxCalculator = TableTaxCalculator{}
+	if country != "" {
+		var err error
+		calculator, err = ts.Calculator(country)
+		if err != nil {
+			return err
+		}
+	}
+	for i := range items {
+		items[i].Taxes = calculator.Calculate(country, region, items[i])
+		items[i].TaxAmount = 0
+		total := lineAmount(items[i])
+		for _, tax := range items[i].Taxes {
+			items[i].TaxAmount += tax.Amount
+			if !tax.Inclusive {
+				total += tax.Amount
+			}
+		}
+		items[i].TaxAmount = util.RoundAmount(items[i].TaxAmount)
+		items[i].Total = util.RoundAmount(total)
+	}
+	return nil
+}
+
+// Address Service
+
+type addressRule struct {
+	postalCode    *regexp.Regexp
+	requireRegion bool
+}
+
+// addressRules are the country specific checks applied on top of the fields
+// every address needs. Countries without a rule only need those fields.
+var addressRules = map[string]addressRule{
+	"TR": {postalCode: regexp.MustCompile(`^\d{5}$`), requireRegion: true},
+	"US": {postalCode: regexp.MustCompile(`^\d{5}(-\d{4})?$`), requireRegion: true},
+	"CA": {postalCode: regexp.MustCompile(`^[A-Z]\d[A-Z] ?\d[A-Z]\d$`), requireRegion: true},
+	"GB": {postalCode: regexp.MustCompile(`^[A-Z]{1,2}\d[A-Z\d]? ?\d[A-Z]{2}$`)},
+	"DE": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+	"FR": {postalCode: regexp.MustCompile(`^\d{5}$`)},
+	"NL": {postalCode: regexp.MustCompile(`^\d{4} ?[A-Z]{2}$`)},
+}
+
+// ValidateAddress normalizes an address and checks it against the rules of
+// its country.
+func ValidateAddress(address *model.Address) error {
+	address.Country = strings.ToUpper(strings.TrimSpace(address.Country))
+	address.PostalCode = strings.ToUpper(strings.TrimSpace(address.PostalCode))
+	if address.FullName == "" || address.Line1 == "" || address.City == "" || len(address.Country) != 2 {
+		return fmt.Errorf("%w: full name, line1, city and country are required", util.InvalidAddressError)
+	}
+	rule, ok := addressRules[address.Country]
+	if !ok {
+		return nil
+	}
+	if rule.requireRegion && strings.TrimSpace(address.Region) == "" {
+		return fmt.Errorf("%w: region is required in %s", util.InvalidAddressError, address.Country)
+	}
+	if rule.postalCode != nil && !rule.postalCode.MatchString(address.PostalCode) {
+		return fmt.Errorf("%w: postal code is not valid in %s", util.InvalidAddressError, address.Country)
+	}
+	return nil
+}
+
+// Snapshot copies an address for storing on an order.
+func Snapshot(address model.Address) model.AddressSnapshot {
+	return model.AddressSnapshot{
+		FullName:   address.FullName,
+		Line1:      address.Line1,
+		Line2:      address.Line2,
+		City:       address.City,
+		Region:     address.Region,
+		PostalCode: address.PostalCode,
+		Country:    address.Country,
+		Phone:      address.Phone,
+	}
+}
+
+func (as *AddressService) Get(id string) (model.Address, error) {
+	return as.Repository.Get(id)
+}
+
+func (as *AddressService) GetByUser(userID uint) ([]model.Address, error) {
+	return as.Repository.GetByUser(userID)
+}
+
+func (as *AddressService) GetDefaultShipping(userID uint) (model.Address, error) {
+	return as.Repository.GetDefaultShipping(userID)
+}
+
+func (as *AddressService) GetDefaultBilling(userID uint) (model.Address, error) {
+	return as.Repository.GetDefaultBilling(userID)
+}
+
+// Save validates and stores an address. The first address of a user becomes
+// the default shipping and billing address.
+func (as *AddressService) Save(address model.Address) (model.Address, error) {
+	if err := ValidateAddress(&address); err != nil {
+		return model.Address{}, err
+	}
+	if address.ID == 0 {
+		exist, err := as.Repository.GetByUser(address.UserID)
+		if err != nil {
+			return model.Address{}, err
+		}
+		if len(exist) == 0 {
+			address.IsDefaultShipping = true
+			address.IsDefaultBilling = true
+		}
+	}
+	return as.Repository.Save(address)
+}
+
+func (as *AddressService) Delete(id string) error {
+	return as.Repository.Delete(id)
 }
 
-func (ps *PaymentService) ProcessPayment(payment model.Payment) error {
-	payment.Status = model.PENDING
-	payment.CreatedAt = time.Now()
-	payment.UpdatedAt = time.Now()
+// Shipping Service
 
-	params := stripe.PaymentIntentParams{
-		Amount:                  stripe.Int64(int64(payment.Amount)),
-		Currency:                stripe.String(string(stripe.CurrencyUSD)),
-		PaymentMethod:           stripe.String("pm_card_visa"), // Test
-		Confirm:                 stripe.Bool(true),
-		AutomaticPaymentMethods: &stripe.PaymentIntentAutomaticPaymentMethodsParams{AllowRedirects: stripe.String("never"), Enabled: stripe.Bool(true)},
+// ShippingRate is the price of a shipping method for a cart.
+type ShippingRate struct {
+	MethodID uint                   `json:"method_id"`
+	Name     string                 `json:"name"`
+	Type     model.ShippingRateType `json:"type"`
+	Amount   float64                `json:"amount"`
+	Currency string                 `json:"currency"`
+}
+
+// volumetricDivisor converts the volume of a product in cubic centimeters to
+// the weight in kilograms carriers charge for.
+const volumetricDivisor = 5000
+
+// ChargeableWeight returns the weight of the items in kilograms. A product is
+// charged by its volumetric weight when that is more than its actual weight.
+func ChargeableWeight(items []model.OrderItem) float64 {
+	var weight float64
+	for _, item := range items {
+		product := item.Product
+		volumetric := product.Length * product.Width * product.Height / volumetricDivisor
+		weight += math.Max(product.Weight, volumetric) * float64(item.Quantity)
 	}
-	result, err := paymentintent.New(&params)
+	return weight
+}
+
+// ShippingCost returns the price of a method in the order currency. amount is
+// the discounted subtotal of the cart in the order currency. Weight based
+// methods charge every started kilogram.
+func ShippingCost(method model.ShippingMethod, items []model.OrderItem, amount float64, rate float64) float64 {
+	cost := method.BaseRate
+	switch method.Type {
+	case model.SHIPPING_WEIGHT:
+		cost += method.PerKgRate * math.Ceil(ChargeableWeight(items))
+	case model.SHIPPING_FREE_OVER:
+		if amount >= util.RoundAmount(method.FreeOver*rate) {
+			return 0
+		}
+	}
+	return util.RoundAmount(cost * rate)
+}
+
+func (ss *ShippingService) GetZone(id string) (model.ShippingZone, error) {
+	return ss.ZoneRepository.Get(id)
+}
+
+func (ss *ShippingService) GetZones() ([]model.ShippingZone, error) {
+	return ss.ZoneRepository.GetAll()
+}
+
+func (ss *ShippingService) GetZoneByCountry(country string) (model.ShippingZone, error) {
+	return ss.ZoneRepository.GetByCountry(strings.ToUpper(country))
+}
+
+func (ss *ShippingService) CreateZone(zone model.ShippingZone) error {
+	for i := range zone.Countries {
+		zone.Countries[i].Country = strings.ToUpper(zone.Countries[i].Country)
+	}
+	return ss.ZoneRepository.Create(zone)
+}
+
+func (ss *ShippingService) UpdateZone(zone model.ShippingZone) error {
+	exist, err := ss.GetZone(strconv.Itoa(int(zone.ID)))
 	if err != nil {
-		payment.Status = model.FAILED
-		payment.UpdatedAt = time.Now()
-		if err := ps.SavePayment(payment); err != nil {
-			return err
+		return err
+	}
+	for i := range zone.Countries {
+		zone.Countries[i].Country = strings.ToUpper(zone.Countries[i].Country)
+	}
+	zone.CreatedAt = exist.CreatedAt
+	return ss.ZoneRepository.Update(zone)
+}
+
+func (ss *ShippingService) DeleteZone(id string) error {
+	return ss.ZoneRepository.Delete(id)
+}
+
+func (ss *ShippingService) GetMethod(id string) (model.ShippingMethod, error) {
+	return ss.MethodRepository.Get(id)
+}
+
+func (ss *ShippingService) CreateMethod(method model.ShippingMethod) error {
+	return ss.MethodRepository.Create(method)
+}
+
+func (ss *ShippingService) UpdateMethod(method model.ShippingMethod) error {
+	exist, err := ss.GetMethod(strconv.Itoa(int(method.ID)))
+	if err != nil {
+		return err
+	}
+	method.CreatedAt = exist.CreatedAt
+	return ss.MethodRepository.Update(method)
+}
+
+func (ss *ShippingService) DeleteMethod(id string) error {
+	return ss.MethodRepository.Delete(id)
+}
+
+// Rates prices every method available in the country for a cart. amount is
+// the discounted subtotal of the cart in the order currency.
+func (ss *ShippingService) Rates(country string, items []model.OrderItem, amount float64, currency string, rate float64) ([]ShippingRate, error) {
+	zone, err := ss.GetZoneByCountry(country)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return []ShippingRate{}, nil
+		}
+		return nil, err
+	}
+	methods, err := ss.MethodRepository.GetActiveByZone(zone.ID)
+	if err != nil {
+		return nil, err
+	}
+	rates := []ShippingRate{}
+	for _, method := range methods {
+		rates = append(rates, ShippingRate{
+			MethodID: method.ID,
+			Name:     method.Name,
+			Type:     method.Type,
+			Amount:   ShippingCost(method, items, amount, rate),
+			Currency: currency,
+		})
+	}
+	return rates, nil
+}
+
+// Quote prices the chosen method for a cart. It returns
+// util.ShippingMethodNotAvailableError when the method can't ship to the
+// country.
+func (ss *ShippingService) Quote(methodID uint, country string, items []model.OrderItem, amount float64, rate float64) (model.ShippingMethod, float64, error) {
+	method, err := ss.GetMethod(strconv.Itoa(int(methodID)))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ShippingMethod{}, 0, util.ShippingMethodNotAvailableError
+		}
+		return model.ShippingMethod{}, 0, err
+	}
+	zone, err := ss.GetZoneByCountry(country)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return model.ShippingMethod{}, 0, util.ShippingMethodNotAvailableError
+		}
+		return model.ShippingMethod{}, 0, err
+	}
+	if !method.Active || method.ZoneID != zone.ID {
+		return model.ShippingMethod{}, 0, util.ShippingMethodNotAvailableError
+	}
+	return method, ShippingCost(method, items, amount, rate), nil
+}
+
+// Return Service
+
+func (rs *ReturnService) Get(id string) (model.ReturnRequest, error) {
+	return rs.Repository.Get(id)
+}
+
+func (rs *ReturnService) GetAll() ([]model.ReturnRequest, error) {
+	return rs.Repository.GetAll()
+}
+
+func (rs *ReturnService) GetByUser(userID uint) ([]model.ReturnRequest, error) {
+	return rs.Repository.GetByUser(userID)
+}
+
+// Create opens a return for shipped items of an order of the user.
+func (rs *ReturnService) Create(order model.Order, request model.ReturnRequest) (model.ReturnRequest, error) {
+	if order.Status != model.ORDER_PARTIALLY_SHIPPED && order.Status != model.ORDER_SHIPPED && order.Status != model.ORDER_DELIVERED {
+		return model.ReturnRequest{}, util.OrderNotReturnableError
+	}
+	orderItems := map[uint]bool{}
+	for _, item := range order.Products {
+		orderItems[item.ID] = true
+	}
+	for _, item := range request.Items {
+		if !orderItems[item.OrderItemID] {
+			return model.ReturnRequest{}, util.ReturnItemNotInOrderError
+		}
+	}
+
+	request.ID = 0
+	request.OrderID = order.ID
+	request.UserID = order.UserID
+	request.Currency = order.Currency
+	request.Status = model.RETURN_REQUESTED
+	request.History = []model.ReturnHistory{{Status: model.RETURN_REQUESTED, Note: request.Note, ActorID: order.UserID}}
+	return rs.Repository.Create(request)
+}
+
+// Transition moves a return between two statuses of the workflow.
+func (rs *ReturnService) Transition(id uint, from model.ReturnStatus, to model.ReturnStatus, actorID uint, note string) error {
+	return rs.Repository.Transition(id, from, to, model.ReturnHistory{Note: note, ActorID: actorID})
+}
+
+// ReturnOutcome is the result of the inspection of a returned item.
+type ReturnOutcome struct {
+	Accepted bool
+	Restock  bool
+}
+
+// Inspect records the outcome of every item of a received return. Accepted
+// items are refunded at the price paid for them; items without an outcome are
+// neither accepted nor restocked. The return is closed when nothing is left
+// to refund.
+func (rs *ReturnService) Inspect(request model.ReturnRequest, order model.Order, outcomes map[uint]ReturnOutcome, actorID uint, note string) (model.ReturnRequest, error) {
+	if request.Status != model.RETURN_RECEIVED {
+		return model.ReturnRequest{}, util.ReturnStatusError
+	}
+	request = inspectReturn(request, order, outcomes)
+	err := rs.Repository.Inspect(request, request.Status, model.ReturnHistory{Note: note, ActorID: actorID})
+	return request, err
+}
+
+// inspectReturn applies the outcomes to the items of the return and works out
+// its refund and next status.
+func inspectReturn(request model.ReturnRequest, order model.Order, outcomes map[uint]ReturnOutcome) model.ReturnRequest {
+	orderItems := map[uint]model.OrderItem{}
+	for _, item := range order.Products {
+		orderItems[item.ID] = item
+	}
+
+	var amount float64
+	for i, item := range request.Items {
+		outcome := outcomes[item.ID]
+		request.Items[i].Accepted = outcome.Accepted
+		request.Items[i].Restocked = outcome.Restock
+		orderItem := orderItems[item.OrderItemID]
+		if outcome.Accepted && orderItem.Quantity > 0 {
+			amount += orderItem.Total / float64(orderItem.Quantity) * float64(item.Quantity)
+		}
+	}
+	request.RefundAmount = util.RoundAmount(amount)
+	request.Status = model.RETURN_INSPECTED
+	if request.RefundAmount == 0 {
+		request.Status = model.RETURN_CLOSED
+	}
+	return request
+}
+
+// Shipment Service
+
+func (ss *ShipmentService) Get(id string) (model.Shipment, error) {
+	return ss.Repository.Get(id)
+}
+
+func (ss *ShipmentService) GetByOrder(orderID uint) ([]model.Shipment, error) {
+	return ss.Repository.GetByOrder(orderID)
+}
+
+// Create ships items of a paid order. Lines of the same item are merged and
+// the order moves to shipped once every item is shipped in full.
+func (ss *ShipmentService) Create(order model.Order, shipment model.Shipment) (model.Shipment, error) {
+	if order.Status != model.ORDER_PAID && order.Status != model.ORDER_PARTIALLY_SHIPPED {
+		return model.Shipment{}, util.OrderNotShippableError
+	}
+	items, err := shipmentItems(order, shipment.Items)
+	if err != nil {
+		return model.Shipment{}, err
+	}
+
+	now := time.Now()
+	shipment.ID = 0
+	shipment.OrderID = order.ID
+	shipment.Items = items
+	shipment.Status = model.SHIPMENT_SHIPPED
+	shipment.ShippedAt = &now
+	return ss.Repository.Create(shipment)
+}
+
+// shipmentItems checks that the items belong to the order and merges the
+// lines of the same item.
+func shipmentItems(order model.Order, lines []model.ShipmentItem) ([]model.ShipmentItem, error) {
+	orderItems := map[uint]bool{}
+	for _, item := range order.Products {
+		orderItems[item.ID] = true
+	}
+	var items []model.ShipmentItem
+	merged := map[uint]int{}
+	for _, item := range lines {
+		if !orderItems[item.OrderItemID] {
+			return nil, util.ShipmentItemNotInOrderError
+		}
+		if i, ok := merged[item.OrderItemID]; ok {
+			items[i].Quantity += item.Quantity
+			continue
+		}
+		merged[item.OrderItemID] = len(items)
+		items = append(items, model.ShipmentItem{OrderItemID: item.OrderItemID, Quantity: item.Quantity})
+	}
+	return items, nil
+}
+
+// Update changes the carrier details and status of a shipment. The order
+// moves to delivered once it is fully shipped and every shipment is
+// delivered.
+func (ss *ShipmentService) Update(shipment model.Shipment) (model.Shipment, error) {
+	exist, err := ss.Get(strconv.Itoa(int(shipment.ID)))
+	if err != nil {
+		return model.Shipment{}, err
+	}
+	exist, err = updateShipment(exist, shipment, time.Now())
+	if err != nil {
+		return model.Shipment{}, err
+	}
+	if err := ss.Repository.Update(exist); err != nil {
+		return model.Shipment{}, err
+	}
+	if exist.Status != model.SHIPMENT_DELIVERED {
+		return exist, nil
+	}
+
+	shipments, err := ss.GetByOrder(exist.OrderID)
+	if err != nil {
+		return model.Shipment{}, err
+	}
+	if !allDelivered(shipments) {
+		return exist, nil
+	}
+	_, err = ss.OrderRepository.UpdateStatus(exist.OrderID, model.ORDER_SHIPPED, model.ORDER_DELIVERED)
+	return exist, err
+}
+
+// updateShipment applies the carrier details and status of shipment to exist,
+// stamping the delivery the first time it is delivered. A delivered shipment
+// can't go back.
+func updateShipment(exist model.Shipment, shipment model.Shipment, now time.Time) (model.Shipment, error) {
+	if exist.Status == model.SHIPMENT_DELIVERED && shipment.Status != model.SHIPMENT_DELIVERED {
+		return model.Shipment{}, util.ShipmentDeliveredError
+	}
+	exist.Carrier = shipment.Carrier
+	exist.TrackingNumber = shipment.TrackingNumber
+	exist.TrackingURL = shipment.TrackingURL
+	exist.Status = shipment.Status
+	if exist.Status == model.SHIPMENT_DELIVERED && exist.DeliveredAt == nil {
+		exist.DeliveredAt = &now
+	}
+	return exist, nil
+}
+
+// allDelivered reports whether every shipment of an order is delivered.
+func allDelivered(shipments []model.Shipment) bool {
+	for _, shipment := range shipments {
+		if shipment.Status != model.SHIPMENT_DELIVERED {
+			return false
+		}
+	}
+	return true
+}
+
+// Idempotency Service
+
+// IdempotencyKeyTTL is how long a stored response is replayed for.
+const IdempotencyKeyTTL = 24 * time.Hour
+
+// Begin reserves key for a request. It returns a completed record when the
+// request was already executed and its response should be replayed.
+func (is *IdempotencyService) Begin(userID uint, key string, method string, path string, body []byte) (model.IdempotencyKey, error) {
+	hash := sha256.New()
+	hash.Write([]byte(method + " " + path + "\n"))
+	hash.Write(body)
+	fingerprint := hex.EncodeToString(hash.Sum(nil))
+
+	exist, err := is.Repository.Get(userID, key)
+	if err == nil && exist.ExpiresAt.Before(time.Now()) {
+		if err := is.Repository.Delete(exist.ID); err != nil {
+			return model.IdempotencyKey{}, err
+		}
+		err = gorm.ErrRecordNotFound
+	}
+	if err == nil {
+		if exist.Fingerprint != fingerprint {
+			return model.IdempotencyKey{}, util.IdempotencyKeyMismatchError
+		}
+		if !exist.Completed {
+			return model.IdempotencyKey{}, util.IdempotencyKeyInProgressError
+		}
+		return exist, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return model.IdempotencyKey{}, err
+	}
+
+	record, err := is.Repository.Create(model.IdempotencyKey{
+		UserID:      userID,
+		Key:         key,
+		Method:      method,
+		Path:        path,
+		Fingerprint: fingerprint,
+		ExpiresAt:   time.Now().Add(IdempotencyKeyTTL),
+	})
+	if err != nil {
+		// A concurrent request reserved the same key first.
+		if _, getErr := is.Repository.Get(userID, key); getErr == nil {
+			return model.IdempotencyKey{}, util.IdempotencyKeyInProgressError
 		}
+		return model.IdempotencyKey{}, err
+	}
+	return record, nil
+}
+
+func (is *IdempotencyService) Complete(record model.IdempotencyKey, response []byte) error {
+	return is.Repository.Complete(record.ID, response)
+}
+
+// Release forgets a reserved key so that the request can be retried.
+func (is *IdempotencyService) Release(record model.IdempotencyKey) error {
+	return is.Repository.Delete(record.ID)
+}
+
+// zeroDecimalCurrencies are charged by Stripe in whole units instead of cents.
+var zeroDecimalCurrencies = map[string]bool{
+	"BIF": true, "CLP": true, "DJF": true, "GNF": true, "JPY": true, "KMF": true,
+	"KRW": true, "MGA": true, "PYG": true, "RWF": true, "UGX": true, "VND": true,
+	"VUV": true, "XAF": true, "XOF": true, "XPF": true,
+}
+
+func toMinorUnits(amount float64, currency string) int64 {
+	if zeroDecimalCurrencies[strings.ToUpper(currency)] {
+		return int64(math.Round(amount))
+	}
+	return int64(math.Round(amount * 100))
+}
+
+// Price Service
+
+// LOWEST_PRICE_PERIOD is how far back the lowest price of a product is
+// looked for.
+const LOWEST_PRICE_PERIOD = 30 * 24 * time.Hour
 
+func (ps *PriceService) History(productID uint) ([]model.PriceHistory, error) {
+	return ps.HistoryRepository.GetByProduct(productID)
+}
+
+func (ps *PriceService) Schedules(productID uint) ([]model.ScheduledPrice, error) {
+	return ps.ScheduleRepository.GetByProduct(productID)
+}
+
+// Schedule saves a price the product goes on at StartsAt and comes off at
+// EndsAt. Without an end the price stays until it's changed.
+func (ps *PriceService) Schedule(schedule model.ScheduledPrice) (model.ScheduledPrice, error) {
+	if schedule.Price <= 0 {
+		return schedule, fmt.Errorf("%w: price must be greater than 0", util.ScheduledPriceWindowError)
+	}
+	if schedule.EndsAt != nil && !schedule.EndsAt.After(schedule.StartsAt) {
+		return schedule, fmt.Errorf("%w: ends_at must be after starts_at", util.ScheduledPriceWindowError)
+	}
+	if schedule.EndsAt != nil && !schedule.EndsAt.After(time.Now()) {
+		return schedule, fmt.Errorf("%w: ends_at must be in the future", util.ScheduledPriceWindowError)
+	}
+	return ps.ScheduleRepository.Create(schedule)
+}
+
+func (ps *PriceService) Cancel(id uint) error {
+	return ps.ScheduleRepository.Cancel(id)
+}
+
+// ApplySchedules starts and ends the scheduled prices that are due.
+func (ps *PriceService) ApplySchedules() (int, error) {
+	return ps.ScheduleRepository.ApplyDue(time.Now())
+}
+
+// Annotate fills the was price and the lowest price of the 30 days before the
+// current price of the products.
+func (ps *PriceService) Annotate(products []model.Product) error {
+	if len(products) == 0 {
+		return nil
+	}
+	ids := make([]uint, len(products))
+	for i, product := range products {
+		ids[i] = product.ID
+	}
+	schedules, err := ps.ScheduleRepository.GetActive(ids)
+	if err != nil {
 		return err
 	}
-	payment.Status = model.SUCCESS
-	payment.UpdatedAt = time.Now()
-	payment.TransactionId = result.ID
-	if err := ps.SavePayment(payment); err != nil {
+	lowest, err := ps.HistoryRepository.LowestPrices(ids, LOWEST_PRICE_PERIOD)
+	if err != nil {
+		return err
+	}
+	annotatePrices(products, schedules, lowest)
+	return nil
+}
+
+// annotatePrices fills the prices of the products from the scheduled prices
+// they are on and their lowest earlier prices. The was price is only shown
+// while the product is on a scheduled price lower than the one it had before.
+func annotatePrices(products []model.Product, schedules []model.ScheduledPrice, lowest map[uint]float64) {
+	active := map[uint]model.ScheduledPrice{}
+	for _, schedule := range schedules {
+		active[schedule.ProductID] = schedule
+	}
+	for i := range products {
+		product := &products[i]
+		product.WasPrice = nil
+		product.LowestPrice30Days = nil
+		if schedule, ok := active[product.ID]; ok && schedule.PreviousPrice != nil &&
+			product.Price == schedule.Price && *schedule.PreviousPrice > product.Price {
+			wasPrice := *schedule.PreviousPrice
+			product.WasPrice = &wasPrice
+		}
+		if price, ok := lowest[product.ID]; ok {
+			product.LowestPrice30Days = &price
+		}
+	}
+}
+
+// BackfillBaseline records the current price of the products from before
+// prices were recorded, so later changes have a price to compare with.
+func (ps *PriceService) BackfillBaseline() error {
+	return ps.HistoryRepository.BackfillBaseline()
+}
+
+// Stock Service
+
+// Adjust changes the stock of a product by the quantity of the movement.
+func (ss *StockService) Adjust(movement model.StockMovement) (model.StockMovement, error) {
+	if movement.Quantity == 0 {
+		return movement, fmt.Errorf("%w: quantity can't be 0", util.StockAdjustmentError)
+	}
+	return ss.Repository.Adjust(movement)
+}
+
+// Set changes the stock of a product to stock, recording the difference as
+// the movement.
+func (ss *StockService) Set(movement model.StockMovement, stock uint) (model.StockMovement, error) {
+	return ss.Repository.Set(movement, stock)
+}
+
+// Report returns the stock movements of a product between from and to with
+// their totals. A zero time leaves that side open.
+func (ss *StockService) Report(productID uint, from time.Time, to time.Time) (dto.StockReportDto, error) {
+	var report dto.StockReportDto
+	product, err := ss.ProductRepository.Get(strconv.Itoa(int(productID)))
+	if err != nil {
+		return report, err
+	}
+	movements, err := ss.Repository.GetByProduct(productID, from, to)
+	if err != nil {
+		return report, err
+	}
+	ledger, err := ss.Repository.LedgerStock(productID)
+	if err != nil {
+		return report, err
+	}
+	report = dto.StockReportDto{
+		ProductID:   product.ID,
+		Name:        product.Name,
+		SKU:         product.SKU,
+		Stock:       product.Stock,
+		LedgerStock: ledger,
+		Reasons:     map[model.StockReason]int{},
+		Movements:   movements,
+	}
+	for _, movement := range movements {
+		if movement.Quantity > 0 {
+			report.In += movement.Quantity
+		} else {
+			report.Out -= movement.Quantity
+		}
+		report.Reasons[movement.Reason] += movement.Quantity
+	}
+	return report, nil
+}
+
+func (ss *StockService) GetDiscrepancies() ([]storage.StockDiscrepancy, error) {
+	return ss.Repository.GetDiscrepancies()
+}
+
+// Reconcile sets the stock of the products that don't match their ledger
+// back to it, recording the corrections of the ledger as made by the user.
+func (ss *StockService) Reconcile(userID uint) ([]storage.StockDiscrepancy, error) {
+	return ss.Repository.Reconcile(userID)
+}
+
+// BackfillOpening records the stock the products had before the ledger as
+// their opening movement.
+func (ss *StockService) BackfillOpening() error {
+	return ss.Repository.BackfillOpening()
+}
+
+// Currency Service
+
+func (cs *CurrencyService) GetRates() ([]model.ExchangeRate, error) {
+	return cs.RateRepository.GetAll()
+}
+
+func (cs *CurrencyService) SaveRate(currency string, rate float64) error {
+	currency = strings.ToUpper(currency)
+	if currency == model.BaseCurrency {
+		return util.UnsupportedCurrencyError
+	}
+	return cs.RateRepository.Save(model.ExchangeRate{Currency: currency, Rate: rate})
+}
+
+func (cs *CurrencyService) DeleteRate(currency string) error {
+	return cs.RateRepository.Delete(strings.ToUpper(currency))
+}
+
+// Resolve normalizes a requested currency and returns it with its exchange
+// rate. An empty currency selects the base currency.
+func (cs *CurrencyService) Resolve(currency string) (string, float64, error) {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" || currency == model.BaseCurrency {
+		return model.BaseCurrency, 1, nil
+	}
+	rate, err := cs.RateRepository.Get(currency)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", 0, util.UnsupportedCurrencyError
+		}
+		return "", 0, err
+	}
+	return currency, rate.Rate, nil
+}
+
+// Price returns the price of a product in currency, preferring the product's
+// price list and falling back to converting the base price with rate.
+func (cs *CurrencyService) Price(product model.Product, currency string, rate float64) (float64, error) {
+	if currency == model.BaseCurrency {
+		return product.Price, nil
+	}
+	price, err := cs.PriceRepository.Get(product.ID, currency)
+	if err == nil {
+		return price.Price, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	return util.RoundAmount(product.Price * rate), nil
+}
+
+// Localize rewrites product prices into the requested currency.
+func (cs *CurrencyService) Localize(products []model.Product, currency string) error {
+	currency, rate, err := cs.Resolve(currency)
+	if err != nil {
 		return err
 	}
+	prices := map[uint]float64{}
+	if currency != model.BaseCurrency {
+		list, err := cs.PriceRepository.GetByCurrency(currency)
+		if err != nil {
+			return err
+		}
+		for _, price := range list {
+			prices[price.ProductID] = price.Price
+		}
+	}
+	for i := range products {
+		if price, ok := prices[products[i].ID]; ok {
+			// The history is kept in the base currency only, so it can't
+			// be compared with a price from the price list.
+			products[i].Price = price
+			products[i].WasPrice = nil
+			products[i].LowestPrice30Days = nil
+		} else if currency != model.BaseCurrency {
+			products[i].Price = util.RoundAmount(products[i].Price * rate)
+			products[i].WasPrice = convertPrice(products[i].WasPrice, rate)
+			products[i].LowestPrice30Days = convertPrice(products[i].LowestPrice30Days, rate)
+		}
+		products[i].Currency = currency
+	}
 	return nil
 }
+
+// convertPrice converts an optional base currency price with rate.
+func convertPrice(price *float64, rate float64) *float64 {
+	if price == nil {
+		return nil
+	}
+	converted := util.RoundAmount(*price * rate)
+	return &converted
+}
+
+func (cs *CurrencyService) GetProductPrices(productID uint) ([]model.ProductPrice, error) {
+	return cs.PriceRepository.GetByProduct(productID)
+}
+
+func (cs *CurrencyService) SaveProductPrice(productID uint, currency string, price float64) error {
+	currency, _, err := cs.Resolve(currency)
+	if err != nil {
+		return err
+	}
+	if currency == model.BaseCurrency {
+		return util.UnsupportedCurrencyError
+	}
+	return cs.PriceRepository.Save(model.ProductPrice{ProductID: productID, Currency: currency, Price: price})
+}
+
+func (cs *CurrencyService) DeleteProductPrice(productID uint, currency string) error {
+	return cs.PriceRepository.Delete(productID, strings.ToUpper(currency))
+}
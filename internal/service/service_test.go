@@ -0,0 +1,745 @@
+package service
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatihesergg/go_ecommerce/internal/dto"
+	"github.com/fatihesergg/go_ecommerce/internal/model"
+	"github.com/fatihesergg/go_ecommerce/internal/util"
+)
+
+func TestAllocateDiscount(t *testing.T) {
+	tests := []struct {
+		name      string
+		items     []model.OrderItem
+		lines     []int
+		amount    float64
+		allocated float64
+		discounts []float64
+	}{
+		{
+			name:      "single line",
+			items:     []model.OrderItem{{UnitPrice: 10, Quantity: 2}},
+			lines:     []int{0},
+			amount:    5,
+			allocated: 5,
+			discounts: []float64{5},
+		},
+		{
+			name:      "proportional to the line amounts",
+			items:     []model.OrderItem{{UnitPrice: 30, Quantity: 1}, {UnitPrice: 10, Quantity: 1}},
+			lines:     []int{0, 1},
+			amount:    8,
+			allocated: 8,
+			discounts: []float64{6, 2},
+		},
+		{
+			name:      "rounding remainder goes to the last line",
+			items:     []model.OrderItem{{UnitPrice: 10, Quantity: 1}, {UnitPrice: 10, Quantity: 1}, {UnitPrice: 10, Quantity: 1}},
+			lines:     []int{0, 1, 2},
+			amount:    10,
+			allocated: 10,
+			discounts: []float64{3.33, 3.33, 3.34},
+		},
+		{
+			name:      "capped at the remaining amount",
+			items:     []model.OrderItem{{UnitPrice: 10, Quantity: 1, DiscountAmount: 4}},
+			lines:     []int{0},
+			amount:    20,
+			allocated: 6,
+			discounts: []float64{10},
+		},
+		{
+			name:      "only the given lines",
+			items:     []model.OrderItem{{UnitPrice: 10, Quantity: 1}, {UnitPrice: 10, Quantity: 1}},
+			lines:     []int{1},
+			amount:    4,
+			allocated: 4,
+			discounts: []float64{0, 4},
+		},
+		{
+			name:      "nothing left to discount",
+			items:     []model.OrderItem{{UnitPrice: 10, Quantity: 1, DiscountAmount: 10}},
+			lines:     []int{0},
+			amount:    5,
+			allocated: 0,
+			discounts: []float64{10},
+		},
+		{
+			name:      "no amount",
+			items:     []model.OrderItem{{UnitPrice: 10, Quantity: 1}},
+			lines:     []int{0},
+			amount:    0,
+			allocated: 0,
+			discounts: []float64{0},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			items := slices.Clone(test.items)
+			allocated := allocateDiscount(items, test.lines, test.amount)
+			if allocated != test.allocated {
+				t.Errorf("allocated %v, want %v", allocated, test.allocated)
+			}
+			for i, item := range items {
+				if item.DiscountAmount != test.discounts[i] {
+					t.Errorf("item %d discount %v, want %v", i, item.DiscountAmount, test.discounts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestApplyPromotions(t *testing.T) {
+	category := func(id uint) *uint { return &id }
+	item := func(productID uint, categoryID uint, price float64, quantity int) model.OrderItem {
+		return model.OrderItem{ProductID: productID, Product: model.Product{ID: productID, CategoryID: categoryID}, UnitPrice: price, Quantity: quantity}
+	}
+	type applied struct {
+		promotion uint
+		amount    float64
+	}
+	tests := []struct {
+		name       string
+		promotions []model.Promotion
+		items      []model.OrderItem
+		rate       float64
+		applied    []applied
+		discounts  []float64
+	}{
+		{
+			name:       "buy x get y",
+			promotions: []model.Promotion{{ID: 1, Type: model.PROMOTION_BUY_X_GET_Y, BuyQuantity: 2, GetQuantity: 1}},
+			items:      []model.OrderItem{item(1, 1, 10, 7)},
+			rate:       1,
+			applied:    []applied{{1, 20}},
+			discounts:  []float64{20},
+		},
+		{
+			name:       "category sale",
+			promotions: []model.Promotion{{ID: 1, Type: model.PROMOTION_CATEGORY_SALE, CategoryID: category(1), DiscountType: model.DISCOUNT_PERCENTAGE, DiscountValue: 10}},
+			items:      []model.OrderItem{item(1, 1, 50, 2), item(2, 2, 50, 1)},
+			rate:       1,
+			applied:    []applied{{1, 10}},
+			discounts:  []float64{10, 0},
+		},
+		{
+			name:       "bundle",
+			promotions: []model.Promotion{{ID: 1, Type: model.PROMOTION_BUNDLE, Products: []model.Product{{ID: 1}, {ID: 2}}, DiscountType: model.DISCOUNT_PERCENTAGE, DiscountValue: 20}},
+			items:      []model.OrderItem{item(1, 1, 30, 1), item(2, 1, 20, 2)},
+			rate:       1,
+			applied:    []applied{{1, 6}, {1, 4}},
+			discounts:  []float64{6, 4},
+		},
+		{
+			name:       "bundle missing a product",
+			promotions: []model.Promotion{{ID: 1, Type: model.PROMOTION_BUNDLE, Products: []model.Product{{ID: 1}, {ID: 3}}, DiscountType: model.DISCOUNT_PERCENTAGE, DiscountValue: 20}},
+			items:      []model.OrderItem{item(1, 1, 30, 1), item(2, 1, 20, 2)},
+			rate:       1,
+			discounts:  []float64{0, 0},
+		},
+		{
+			name: "threshold takes the highest tier reached",
+			promotions: []model.Promotion{{ID: 1, Type: model.PROMOTION_THRESHOLD, DiscountType: model.DISCOUNT_FIXED, Tiers: []model.PromotionTier{
+				{MinAmount: 50, DiscountValue: 5}, {MinAmount: 100, DiscountValue: 12}, {MinAmount: 200, DiscountValue: 30},
+			}}},
+			items:     []model.OrderItem{item(1, 1, 60, 1), item(2, 1, 60, 1)},
+			rate:      1,
+			applied:   []applied{{1, 12}},
+			discounts: []float64{6, 6},
+		},
+		{
+			name: "threshold in another currency",
+			promotions: []model.Promotion{{ID: 1, Type: model.PROMOTION_THRESHOLD, DiscountType: model.DISCOUNT_FIXED, Tiers: []model.PromotionTier{
+				{MinAmount: 50, DiscountValue: 5},
+			}}},
+			items:     []model.OrderItem{item(1, 1, 80, 1)},
+			rate:      2,
+			discounts: []float64{0},
+		},
+		{
+			name: "non-exclusive promotions stack by priority",
+			promotions: []model.Promotion{
+				{ID: 1, Priority: 1, Type: model.PROMOTION_THRESHOLD, DiscountType: model.DISCOUNT_FIXED, Tiers: []model.PromotionTier{{MinAmount: 50, DiscountValue: 5}}},
+				{ID: 2, Priority: 2, Type: model.PROMOTION_CATEGORY_SALE, CategoryID: category(1), DiscountType: model.DISCOUNT_PERCENTAGE, DiscountValue: 10},
+			},
+			items:     []model.OrderItem{item(1, 1, 100, 1)},
+			rate:      1,
+			applied:   []applied{{2, 10}, {1, 5}},
+			discounts: []float64{15},
+		},
+		{
+			name: "exclusive promotion replaces the others",
+			promotions: []model.Promotion{
+				{ID: 1, Priority: 10, Type: model.PROMOTION_THRESHOLD, DiscountType: model.DISCOUNT_FIXED, Tiers: []model.PromotionTier{{MinAmount: 50, DiscountValue: 5}}},
+				{ID: 2, Priority: 1, Exclusive: true, Type: model.PROMOTION_CATEGORY_SALE, CategoryID: category(1), DiscountType: model.DISCOUNT_PERCENTAGE, DiscountValue: 50},
+			},
+			items:     []model.OrderItem{item(1, 1, 100, 1)},
+			rate:      1,
+			applied:   []applied{{2, 50}},
+			discounts: []float64{50},
+		},
+		{
+			name: "exclusive promotion that doesn't apply leaves the items alone",
+			promotions: []model.Promotion{
+				{ID: 1, Priority: 10, Exclusive: true, Type: model.PROMOTION_CATEGORY_SALE, CategoryID: category(2), DiscountType: model.DISCOUNT_PERCENTAGE, DiscountValue: 50},
+				{ID: 2, Priority: 1, Type: model.PROMOTION_CATEGORY_SALE, CategoryID: category(1), DiscountType: model.DISCOUNT_PERCENTAGE, DiscountValue: 10},
+			},
+			items:     []model.OrderItem{item(1, 1, 100, 1)},
+			rate:      1,
+			applied:   []applied{{2, 10}},
+			discounts: []float64{10},
+		},
+		{
+			name: "first applying exclusive promotion by priority wins",
+			promotions: []model.Promotion{
+				{ID: 1, Priority: 1, Exclusive: true, Type: model.PROMOTION_CATEGORY_SALE, CategoryID: category(1), DiscountType: model.DISCOUNT_PERCENTAGE, DiscountValue: 50},
+				{ID: 2, Priority: 5, Exclusive: true, Type: model.PROMOTION_CATEGORY_SALE, CategoryID: category(1), DiscountType: model.DISCOUNT_PERCENTAGE, DiscountValue: 20},
+			},
+			items:     []model.OrderItem{item(1, 1, 100, 1)},
+			rate:      1,
+			applied:   []applied{{2, 20}},
+			discounts: []float64{20},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			items := slices.Clone(test.items)
+			discounts := ApplyPromotions(test.promotions, items, test.rate)
+			if len(discounts) != len(test.applied) {
+				t.Fatalf("got %d discounts, want %d: %+v", len(discounts), len(test.applied), discounts)
+			}
+			for i, discount := range discounts {
+				if *discount.PromotionID != test.applied[i].promotion || discount.Amount != test.applied[i].amount {
+					t.Errorf("discount %d is %v of promotion %d, want %v of promotion %d", i, discount.Amount, *discount.PromotionID, test.applied[i].amount, test.applied[i].promotion)
+				}
+			}
+			for i, item := range items {
+				if item.DiscountAmount != test.discounts[i] {
+					t.Errorf("item %d discount %v, want %v", i, item.DiscountAmount, test.discounts[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTableTaxCalculator(t *testing.T) {
+	calculator := TableTaxCalculator{Rates: []model.TaxRate{
+		{Name: "VAT", Country: "DE", Rate: 19, Inclusive: true},
+		{Name: "VAT", Country: "DE", TaxCategory: "books", Rate: 7, Inclusive: true},
+		{Name: "State tax", Country: "US", Region: "CA", Rate: 6},
+		{Name: "County tax", Country: "US", Region: "CA", Rate: 1.25},
+		{Name: "Sales tax", Country: "US", TaxCategory: "electronics", Rate: 2},
+		{Name: "GST", Country: "XX", Rate: 10, Inclusive: true},
+		{Name: "Levy", Country: "XX", Rate: 5},
+	}}
+	item := func(category string, price float64, quantity int, discount float64) model.OrderItem {
+		return model.OrderItem{Product: model.Product{TaxCategory: category}, UnitPrice: price, Quantity: quantity, DiscountAmount: discount}
+	}
+	tests := []struct {
+		name    string
+		country string
+		region  string
+		item    model.OrderItem
+		taxes   []model.OrderItemTax
+	}{
+		{
+			name:    "inclusive country rate",
+			country: "DE",
+			item:    item("", 119, 1, 0),
+			taxes:   []model.OrderItemTax{{Name: "VAT", Rate: 19, Inclusive: true, Amount: 19}},
+		},
+		{
+			name:    "tax category beats the default rate",
+			country: "DE",
+			item:    item("books", 107, 1, 0),
+			taxes:   []model.OrderItemTax{{Name: "VAT", Rate: 7, Inclusive: true, Amount: 7}},
+		},
+		{
+			name:    "unknown tax category falls back to the default rate",
+			country: "de",
+			item:    item("toys", 119, 1, 0),
+			taxes:   []model.OrderItemTax{{Name: "VAT", Rate: 19, Inclusive: true, Amount: 19}},
+		},
+		{
+			name:    "taxed after discounts",
+			country: "DE",
+			item:    item("", 119, 2, 119),
+			taxes:   []model.OrderItemTax{{Name: "VAT", Rate: 19, Inclusive: true, Amount: 19}},
+		},
+		{
+			name:    "every rate of the region applies",
+			country: "US",
+			region:  "ca",
+			item:    item("", 100, 1, 0),
+			taxes:   []model.OrderItemTax{{Name: "State tax", Rate: 6, Amount: 6}, {Name: "County tax", Rate: 1.25, Amount: 1.25}},
+		},
+		{
+			name:    "country category beats the region default",
+			country: "US",
+			region:  "CA",
+			item:    item("electronics", 100, 1, 0),
+			taxes:   []model.OrderItemTax{{Name: "Sales tax", Rate: 2, Amount: 2}},
+		},
+		{
+			name:    "no matching rate",
+			country: "US",
+			region:  "NY",
+			item:    item("", 100, 1, 0),
+		},
+		{
+			name:    "exclusive taxes on the net amount",
+			country: "XX",
+			item:    item("", 110, 1, 0),
+			taxes:   []model.OrderItemTax{{Name: "GST", Rate: 10, Inclusive: true, Amount: 10}, {Name: "Levy", Rate: 5, Amount: 5}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			taxes := calculator.Calculate(test.country, test.region, test.item)
+			if !slices.Equal(taxes, test.taxes) {
+				t.Errorf("got %+v, want %+v", taxes, test.taxes)
+			}
+		})
+	}
+}
+
+func TestValidateAddress(t *testing.T) {
+	address := func(country string, region string, postalCode string) model.Address {
+		return model.Address{FullName: "Ada Lovelace", Line1: "1 Main Street", City: "Springfield", Country: country, Region: region, PostalCode: postalCode}
+	}
+	tests := []struct {
+		name       string
+		address    model.Address
+		valid      bool
+		country    string
+		postalCode string
+	}{
+		{name: "US with ZIP+4", address: address("US", "IL", "62701-1234"), valid: true, country: "US", postalCode: "62701-1234"},
+		{name: "US without region", address: address("US", "", "62701"), valid: false},
+		{name: "US with invalid ZIP", address: address("US", "IL", "6270"), valid: false},
+		{name: "country and postal code normalized", address: address(" ca ", "ON", "k1a 0b1"), valid: true, country: "CA", postalCode: "K1A 0B1"},
+		{name: "GB postcode", address: address("GB", "", "SW1A 1AA"), valid: true, country: "GB", postalCode: "SW1A 1AA"},
+		{name: "NL postcode", address: address("NL", "", "1012 AB"), valid: true, country: "NL", postalCode: "1012 AB"},
+		{name: "DE with letters in postal code", address: address("DE", "", "1011A"), valid: false},
+		{name: "TR without region", address: address("TR", "", "34000"), valid: false},
+		{name: "country without rules", address: address("JP", "", ""), valid: true, country: "JP"},
+		{name: "country not a code", address: address("Germany", "", "10115"), valid: false},
+		{name: "missing full name", address: model.Address{Line1: "1 Main Street", City: "Berlin", Country: "DE", PostalCode: "10115"}, valid: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			address := test.address
+			err := ValidateAddress(&address)
+			if !test.valid {
+				if !errors.Is(err, util.InvalidAddressError) {
+					t.Errorf("got %v, want InvalidAddressError", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got %v, want no error", err)
+			}
+			if address.Country != test.country || address.PostalCode != test.postalCode {
+				t.Errorf("normalized to %s %s, want %s %s", address.Country, address.PostalCode, test.country, test.postalCode)
+			}
+		})
+	}
+}
+
+func TestShippingCost(t *testing.T) {
+	flat := model.ShippingMethod{Type: model.SHIPPING_FLAT, BaseRate: 5}
+	weight := model.ShippingMethod{Type: model.SHIPPING_WEIGHT, BaseRate: 3, PerKgRate: 2}
+	freeOver := model.ShippingMethod{Type: model.SHIPPING_FREE_OVER, BaseRate: 5, FreeOver: 50}
+	item := func(weight float64, length float64, width float64, height float64, quantity int) model.OrderItem {
+		return model.OrderItem{Product: model.Product{Weight: weight, Length: length, Width: width, Height: height}, Quantity: quantity}
+	}
+	tests := []struct {
+		name   string
+		method model.ShippingMethod
+		items  []model.OrderItem
+		amount float64
+		rate   float64
+		cost   float64
+	}{
+		{name: "flat", method: flat, amount: 100, rate: 1, cost: 5},
+		{name: "flat in another currency", method: flat, amount: 100, rate: 1.5, cost: 7.5},
+		{name: "weight charges every started kilogram", method: weight, items: []model.OrderItem{item(1.2, 0, 0, 0, 2)}, rate: 1, cost: 9},
+		{name: "weight uses the volumetric weight when higher", method: weight, items: []model.OrderItem{item(1, 50, 40, 30, 1)}, rate: 1, cost: 27},
+		{name: "weight of several items", method: weight, items: []model.OrderItem{item(0.5, 0, 0, 0, 1), item(0.25, 0, 0, 0, 2)}, rate: 1, cost: 5},
+		{name: "free over reached", method: freeOver, amount: 50, rate: 1, cost: 0},
+		{name: "free over not reached", method: freeOver, amount: 49.99, rate: 1, cost: 5},
+		{name: "free over in another currency", method: freeOver, amount: 90, rate: 2, cost: 10},
+		{name: "free over reached in another currency", method: freeOver, amount: 100, rate: 2, cost: 0},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			cost := ShippingCost(test.method, test.items, test.amount, test.rate)
+			if cost != test.cost {
+				t.Errorf("got %v, want %v", cost, test.cost)
+			}
+		})
+	}
+}
+
+func TestShipmentItems(t *testing.T) {
+	order := model.Order{Products: []model.OrderItem{{ID: 1, Quantity: 3}, {ID: 2, Quantity: 1}}}
+	tests := []struct {
+		name  string
+		lines []model.ShipmentItem
+		items []model.ShipmentItem
+		err   error
+	}{
+		{name: "single line", lines: []model.ShipmentItem{{OrderItemID: 1, Quantity: 2}}, items: []model.ShipmentItem{{OrderItemID: 1, Quantity: 2}}},
+		{
+			name:  "lines of the same item are merged",
+			lines: []model.ShipmentItem{{OrderItemID: 1, Quantity: 1}, {OrderItemID: 2, Quantity: 1}, {OrderItemID: 1, Quantity: 2}},
+			items: []model.ShipmentItem{{OrderItemID: 1, Quantity: 3}, {OrderItemID: 2, Quantity: 1}},
+		},
+		{name: "item of another order", lines: []model.ShipmentItem{{OrderItemID: 1, Quantity: 1}, {OrderItemID: 9, Quantity: 1}}, err: util.ShipmentItemNotInOrderError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			items, err := shipmentItems(order, test.lines)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if !reflect.DeepEqual(items, test.items) {
+				t.Errorf("got %+v, want %+v", items, test.items)
+			}
+		})
+	}
+}
+
+func TestUpdateShipment(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	earlier := now.Add(-time.Hour)
+	tests := []struct {
+		name      string
+		exist     model.Shipment
+		shipment  model.Shipment
+		delivered *time.Time
+		err       error
+	}{
+		{name: "in transit", exist: model.Shipment{Status: model.SHIPMENT_SHIPPED}, shipment: model.Shipment{Status: model.SHIPMENT_IN_TRANSIT, Carrier: "UPS"}},
+		{name: "delivered", exist: model.Shipment{Status: model.SHIPMENT_IN_TRANSIT}, shipment: model.Shipment{Status: model.SHIPMENT_DELIVERED}, delivered: &now},
+		{name: "delivered again keeps the delivery time", exist: model.Shipment{Status: model.SHIPMENT_DELIVERED, DeliveredAt: &earlier}, shipment: model.Shipment{Status: model.SHIPMENT_DELIVERED}, delivered: &earlier},
+		{name: "delivered can't go back", exist: model.Shipment{Status: model.SHIPMENT_DELIVERED, DeliveredAt: &earlier}, shipment: model.Shipment{Status: model.SHIPMENT_IN_TRANSIT}, err: util.ShipmentDeliveredError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			shipment, err := updateShipment(test.exist, test.shipment, now)
+			if !errors.Is(err, test.err) {
+				t.Fatalf("got error %v, want %v", err, test.err)
+			}
+			if err != nil {
+				return
+			}
+			if shipment.Status != test.shipment.Status || shipment.Carrier != test.shipment.Carrier {
+				t.Errorf("got %s %q, want %s %q", shipment.Status, shipment.Carrier, test.shipment.Status, test.shipment.Carrier)
+			}
+			if !reflect.DeepEqual(shipment.DeliveredAt, test.delivered) {
+				t.Errorf("got delivered at %v, want %v", shipment.DeliveredAt, test.delivered)
+			}
+		})
+	}
+}
+
+func TestAllDelivered(t *testing.T) {
+	delivered := model.Shipment{Status: model.SHIPMENT_DELIVERED}
+	inTransit := model.Shipment{Status: model.SHIPMENT_IN_TRANSIT}
+	tests := []struct {
+		name      string
+		shipments []model.Shipment
+		delivered bool
+	}{
+		{name: "every shipment delivered", shipments: []model.Shipment{delivered, delivered}, delivered: true},
+		{name: "one still in transit", shipments: []model.Shipment{delivered, inTransit}, delivered: false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if delivered := allDelivered(test.shipments); delivered != test.delivered {
+				t.Errorf("got %v, want %v", delivered, test.delivered)
+			}
+		})
+	}
+}
+
+func TestCreateReturn(t *testing.T) {
+	order := model.Order{Status: model.ORDER_DELIVERED, Products: []model.OrderItem{{ID: 1, Quantity: 2}}}
+	tests := []struct {
+		name    string
+		order   model.Order
+		request model.ReturnRequest
+		err     error
+	}{
+		{name: "order not shipped", order: model.Order{Status: model.ORDER_PAID, Products: order.Products}, request: model.ReturnRequest{Items: []model.ReturnItem{{OrderItemID: 1, Quantity: 1}}}, err: util.OrderNotReturnableError},
+		{name: "item of another order", order: order, request: model.ReturnRequest{Items: []model.ReturnItem{{OrderItemID: 1, Quantity: 1}, {OrderItemID: 9, Quantity: 1}}}, err: util.ReturnItemNotInOrderError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var service ReturnService
+			if _, err := service.Create(test.order, test.request); !errors.Is(err, test.err) {
+				t.Errorf("got error %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestInspectReturn(t *testing.T) {
+	order := model.Order{Products: []model.OrderItem{{ID: 1, Quantity: 3, Total: 10}, {ID: 2, Quantity: 1, Total: 25}}}
+	request := func() model.ReturnRequest {
+		return model.ReturnRequest{Status: model.RETURN_RECEIVED, Items: []model.ReturnItem{{ID: 11, OrderItemID: 1, Quantity: 2}, {ID: 12, OrderItemID: 2, Quantity: 1}}}
+	}
+	tests := []struct {
+		name     string
+		outcomes map[uint]ReturnOutcome
+		amount   float64
+		status   model.ReturnStatus
+	}{
+		{name: "everything accepted", outcomes: map[uint]ReturnOutcome{11: {Accepted: true, Restock: true}, 12: {Accepted: true}}, amount: 31.67, status: model.RETURN_INSPECTED},
+		{name: "part of a line is refunded pro rata", outcomes: map[uint]ReturnOutcome{11: {Accepted: true}}, amount: 6.67, status: model.RETURN_INSPECTED},
+		{name: "nothing accepted closes the return", outcomes: map[uint]ReturnOutcome{11: {Restock: true}}, amount: 0, status: model.RETURN_CLOSED},
+		{name: "no outcomes closes the return", amount: 0, status: model.RETURN_CLOSED},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			inspected := inspectReturn(request(), order, test.outcomes)
+			if inspected.RefundAmount != test.amount || inspected.Status != test.status {
+				t.Errorf("got %v %s, want %v %s", inspected.RefundAmount, inspected.Status, test.amount, test.status)
+			}
+			for _, item := range inspected.Items {
+				outcome := test.outcomes[item.ID]
+				if item.Accepted != outcome.Accepted || item.Restocked != outcome.Restock {
+					t.Errorf("item %d got accepted %v restocked %v, want %v %v", item.ID, item.Accepted, item.Restocked, outcome.Accepted, outcome.Restock)
+				}
+			}
+		})
+	}
+}
+
+func TestReviewScreener(t *testing.T) {
+	screener := NewReviewScreener([]string{" Scam ", "", "fake"}, 10, 60)
+	tests := []struct {
+		name    string
+		comment string
+		status  model.ReviewStatus
+		reason  string
+	}{
+		{name: "clean", comment: "Works well and arrived on time.", status: model.REVIEW_APPROVED},
+		{name: "banned word in any case", comment: "This is a SCAM, do not buy it.", status: model.REVIEW_REJECTED, reason: "Contains banned words"},
+		{name: "banned words match whole words only", comment: "Scampi flavoured, would buy again.", status: model.REVIEW_APPROVED},
+		{name: "link", comment: "Cheaper at https://example.com/deal today", status: model.REVIEW_PENDING, reason: "Contains links"},
+		{name: "bare domain", comment: "Cheaper at shop.com, honestly.", status: model.REVIEW_PENDING, reason: "Contains links"},
+		{name: "too short", comment: "  Great!  ", status: model.REVIEW_PENDING, reason: "Shorter than 10 characters"},
+		{name: "length counts characters", comment: "Çok güzel ürün", status: model.REVIEW_APPROVED},
+		{name: "too long", comment: "This product is good but the comment just keeps going for too long.", status: model.REVIEW_PENDING, reason: "Longer than 60 characters"},
+		{name: "rejection beats a hold", comment: "fake www.spam.net", status: model.REVIEW_REJECTED, reason: "Contains banned words; Contains links"},
+		{name: "every failed rule is reported", comment: "x.io", status: model.REVIEW_PENDING, reason: "Contains links; Shorter than 10 characters"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			status, reason := screener.Screen(test.comment)
+			if status != test.status || reason != test.reason {
+				t.Errorf("got %s %q, want %s %q", status, reason, test.status, test.reason)
+			}
+		})
+	}
+}
+
+func TestParseImport(t *testing.T) {
+	text := func(value string) *string { return &value }
+	product := func(sku string, name string, price float64, stock uint) dto.ProductImportDto {
+		var data dto.ProductImportDto
+		data.SKU, data.Name, data.Price, data.Stock = text(sku), name, price, stock
+		return data
+	}
+	withAttributes := product("TS-1", "T-Shirt", 19.9, 5)
+	withAttributes.CategoryID = 2
+	withAttributes.Attributes = []dto.ProductAttributeDto{{AttributeID: 3, Value: "Red"}}
+	withCategory := product("MUG-1", "Mug", 8, 0)
+	withCategory.Category = "Kitchen"
+	withCategory.Description = "Holds coffee, tea"
+
+	tests := []struct {
+		name   string
+		format model.ImportFormat
+		file   string
+		err    error
+		rows   []ImportRow
+		failed []int
+	}{
+		{
+			name:   "csv",
+			format: model.IMPORT_CSV,
+			file:   "sku,name,price,stock,category_id,attr_3\nTS-1,T-Shirt,19.9,5,2,Red\n",
+			rows:   []ImportRow{{Row: 2, Data: withAttributes, Columns: []string{"sku", "name", "price", "stock", "category_id", "attr_3"}}},
+		},
+		{
+			name:   "csv header is normalized and empty cells are unset",
+			format: model.IMPORT_CSV,
+			file:   "\ufeffSKU, Name ,Price,Stock,Category,Description,Image_URL\nMUG-1,Mug,8,,Kitchen,\"Holds coffee, tea\",\n",
+			rows:   []ImportRow{{Row: 2, Data: withCategory, Columns: []string{"sku", "name", "price", "stock", "category", "description", "image_url"}}},
+		},
+		{
+			name:   "csv rows that can't be read",
+			format: model.IMPORT_CSV,
+			file:   "sku,name,price\nA,Lamp\nB,Desk,cheap\nC,Chair,40\n",
+			failed: []int{2, 3},
+			rows:   []ImportRow{{Row: 2}, {Row: 3}, {Row: 4, Data: product("C", "Chair", 40, 0), Columns: []string{"sku", "name", "price"}}},
+		},
+		{
+			name:   "csv unknown column",
+			format: model.IMPORT_CSV,
+			file:   "sku,name,colour\n",
+			err:    util.ImportFileError,
+		},
+		{
+			name:   "csv invalid attribute column",
+			format: model.IMPORT_CSV,
+			file:   "sku,attr_size\n",
+			err:    util.ImportFileError,
+		},
+		{
+			name:   "csv without sku column",
+			format: model.IMPORT_CSV,
+			file:   "name,price\nLamp,10\n",
+			err:    util.ImportFileError,
+		},
+		{
+			name:   "json",
+			format: model.IMPORT_JSON,
+			file:   `[{"sku": "TS-1", "name": "T-Shirt", "price": 19.9, "stock": 5, "category_id": 2, "attributes": [{"attribute_id": 3, "value": "Red"}]}, {"sku": "B", "price": "cheap"}]`,
+			failed: []int{2},
+			rows: []ImportRow{
+				{Row: 1, Data: withAttributes, Columns: []string{"attributes", "category_id", "name", "price", "sku", "stock"}},
+				{Row: 2, Columns: []string{"price", "sku"}},
+			},
+		},
+		{
+			name:   "json that isn't an array",
+			format: model.IMPORT_JSON,
+			file:   `{"sku": "A"}`,
+			err:    util.ImportFileError,
+		},
+		{
+			name:   "unknown format",
+			format: model.ImportFormat("xlsx"),
+			err:    util.ImportFormatError,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			rows, err := ParseImport(test.format, strings.NewReader(test.file))
+			if test.err != nil {
+				if !errors.Is(err, test.err) {
+					t.Errorf("got %v, want %v", err, test.err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("got %v, want no error", err)
+			}
+			if len(rows) != len(test.rows) {
+				t.Fatalf("got %d rows, want %d", len(rows), len(test.rows))
+			}
+			for i, row := range rows {
+				failed := slices.Contains(test.failed, row.Row)
+				if (row.Err != nil) != failed {
+					t.Errorf("row %d error %v, want failed %v", row.Row, row.Err, failed)
+				}
+				if row.Row != test.rows[i].Row {
+					t.Errorf("row %d is numbered %d", test.rows[i].Row, row.Row)
+				}
+				if failed {
+					continue
+				}
+				if !reflect.DeepEqual(row.Data, test.rows[i].Data) {
+					t.Errorf("row %d is %+v, want %+v", row.Row, row.Data, test.rows[i].Data)
+				}
+				if !slices.Equal(row.Columns, test.rows[i].Columns) {
+					t.Errorf("row %d columns %v, want %v", row.Row, row.Columns, test.rows[i].Columns)
+				}
+			}
+		})
+	}
+}
+
+func TestCategoryPath(t *testing.T) {
+	parent := func(id uint) *uint { return &id }
+	categories := map[uint]model.Category{
+		1: {ID: 1, Name: "Clothing"},
+		2: {ID: 2, Name: "Men", ParentID: parent(1)},
+		3: {ID: 3, Name: "Shirts", ParentID: parent(2)},
+		4: {ID: 4, Name: "Orphan", ParentID: parent(99)},
+		5: {ID: 5, Name: "Loop A", ParentID: parent(6)},
+		6: {ID: 6, Name: "Loop B", ParentID: parent(5)},
+	}
+	tests := []struct {
+		name string
+		id   uint
+		path string
+	}{
+		{name: "root", id: 1, path: "Clothing"},
+		{name: "nested", id: 3, path: "Clothing > Men > Shirts"},
+		{name: "missing parent", id: 4, path: "Orphan"},
+		{name: "cycle", id: 5, path: "Loop B > Loop A"},
+		{name: "unknown category", id: 42, path: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if path := categoryPath(categories, test.id); path != test.path {
+				t.Errorf("got %q, want %q", path, test.path)
+			}
+		})
+	}
+}
+
+func TestAnnotatePrices(t *testing.T) {
+	price := func(value float64) *float64 { return &value }
+	schedules := []model.ScheduledPrice{
+		{ProductID: 1, Price: 80, PreviousPrice: price(100)},
+		{ProductID: 2, Price: 120, PreviousPrice: price(100)},
+		{ProductID: 3, Price: 80, PreviousPrice: price(100)},
+		{ProductID: 4, Price: 80},
+	}
+	lowest := map[uint]float64{1: 95, 2: 90, 5: 70}
+	tests := []struct {
+		name     string
+		product  model.Product
+		wasPrice *float64
+		lowest   *float64
+	}{
+		{name: "on a reduced scheduled price", product: model.Product{ID: 1, Price: 80}, wasPrice: price(100), lowest: price(95)},
+		{name: "on a raised scheduled price", product: model.Product{ID: 2, Price: 120}, lowest: price(90)},
+		{name: "price changed by hand during the schedule", product: model.Product{ID: 3, Price: 75}},
+		{name: "schedule without a previous price", product: model.Product{ID: 4, Price: 80}},
+		{name: "lowest earlier price above the current one", product: model.Product{ID: 5, Price: 60}, lowest: price(70)},
+		{name: "no earlier price", product: model.Product{ID: 6, Price: 50, WasPrice: price(60), LowestPrice30Days: price(40)}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			products := []model.Product{test.product}
+			annotatePrices(products, schedules, lowest)
+			if !reflect.DeepEqual(products[0].WasPrice, test.wasPrice) {
+				t.Errorf("was price %v, want %v", products[0].WasPrice, test.wasPrice)
+			}
+			if !reflect.DeepEqual(products[0].LowestPrice30Days, test.lowest) {
+				t.Errorf("lowest price %v, want %v", products[0].LowestPrice30Days, test.lowest)
+			}
+		})
+	}
+}
+
+func TestAdjustStock(t *testing.T) {
+	var service StockService
+	_, err := service.Adjust(model.StockMovement{ProductID: 1, Reason: model.STOCK_ADJUSTMENT})
+	if !errors.Is(err, util.StockAdjustmentError) {
+		t.Errorf("got %v, want %v", err, util.StockAdjustmentError)
+	}
+}
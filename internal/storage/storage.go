@@ -1,8 +1,16 @@
 package storage
 
 import (
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"time"
+
 	"github.com/fatihesergg/go_ecommerce/internal/model"
+	"github.com/fatihesergg/go_ecommerce/internal/util"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 func NewCategoryRepository(db *gorm.DB) *CategoryRepository {
@@ -29,6 +37,86 @@ func NewPaymentRepository(db *gorm.DB) *PaymentRepository {
 	return &PaymentRepository{DB: db}
 }
 
+func NewCouponRepository(db *gorm.DB) *CouponRepository {
+	return &CouponRepository{DB: db}
+}
+
+func NewPromotionRepository(db *gorm.DB) *PromotionRepository {
+	return &PromotionRepository{DB: db}
+}
+
+func NewTaxRateRepository(db *gorm.DB) *TaxRateRepository {
+	return &TaxRateRepository{DB: db}
+}
+
+func NewAddressRepository(db *gorm.DB) *AddressRepository {
+	return &AddressRepository{DB: db}
+}
+
+func NewShippingZoneRepository(db *gorm.DB) *ShippingZoneRepository {
+	return &ShippingZoneRepository{DB: db}
+}
+
+func NewShippingMethodRepository(db *gorm.DB) *ShippingMethodRepository {
+	return &ShippingMethodRepository{DB: db}
+}
+
+func NewShipmentRepository(db *gorm.DB) *ShipmentRepository {
+	return &ShipmentRepository{DB: db}
+}
+
+func NewReturnRepository(db *gorm.DB) *ReturnRepository {
+	return &ReturnRepository{DB: db}
+}
+
+func NewRefundRepository(db *gorm.DB) *RefundRepository {
+	return &RefundRepository{DB: db}
+}
+
+func NewAttributeRepository(db *gorm.DB) *AttributeRepository {
+	return &AttributeRepository{DB: db}
+}
+
+func NewSlugRedirectRepository(db *gorm.DB) *SlugRedirectRepository {
+	return &SlugRedirectRepository{DB: db}
+}
+
+func NewImportJobRepository(db *gorm.DB) *ImportJobRepository {
+	return &ImportJobRepository{DB: db}
+}
+
+func NewProductFeedRepository(db *gorm.DB) *ProductFeedRepository {
+	return &ProductFeedRepository{DB: db}
+}
+
+func NewPriceHistoryRepository(db *gorm.DB) *PriceHistoryRepository {
+	return &PriceHistoryRepository{DB: db}
+}
+
+func NewScheduledPriceRepository(db *gorm.DB) *ScheduledPriceRepository {
+	return &ScheduledPriceRepository{DB: db}
+}
+
+func NewStockMovementRepository(db *gorm.DB) *StockMovementRepository {
+	return &StockMovementRepository{DB: db}
+}
+
+func NewNotificationRepository(db *gorm.DB) *NotificationRepository {
+	return &NotificationRepository{DB: db}
+}
+
+func NewIdempotencyRepository(db *gorm.DB) *IdempotencyRepository {
+	return &IdempotencyRepository{DB: db}
+}
+
+func NewExchangeRateRepository(db *gorm.DB) *ExchangeRateRepository {
+	return &ExchangeRateRepository{DB: db}
+}
+
+func NewProductPriceRepository(db *gorm.DB) *ProductPriceRepository {
+	return &ProductPriceRepository{DB: db}
+}
+
 // Category Repository
 type CategoryRepository struct {
 	DB *gorm.DB
@@ -44,12 +132,301 @@ func (repo *CategoryRepository) GetAll() ([]model.Category, error) {
 	return result, repo.DB.Find(&result).Error
 }
 
+func (repo *CategoryRepository) GetByIDs(ids []uint) ([]model.Category, error) {
+	var result []model.Category
+	return result, repo.DB.Where("id IN ?", ids).Find(&result).Error
+}
+
+// Create saves the category. The parent is checked while the tree is locked
+// so it can't be deleted at the same time.
 func (repo *CategoryRepository) Create(category model.Category) error {
-	return repo.DB.Create(&category).Error
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockCategoryTree(tx); err != nil {
+			return err
+		}
+		if category.ParentID != nil {
+			if err := categoryExists(tx, *category.ParentID, util.ParentCategoryNotFoundError); err != nil {
+				return err
+			}
+		}
+		return tx.Create(&category).Error
+	})
 }
 
+// Update saves the category and keeps its old slug as a redirect when the
+// slug changed.
 func (repo *CategoryRepository) Update(category model.Category) error {
-	return repo.DB.Save(&category).Error
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		var old model.Category
+		if err := tx.Select("id", "slug").First(&old, "id = ?", category.ID).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&category).Select("name", "slug").Updates(&category).Error; err != nil {
+			return err
+		}
+		return recordSlugChange(tx, model.SLUG_CATEGORY, category.ID, old.Slug, category.Slug)
+	})
+}
+
+func (repo *CategoryRepository) GetBySlug(slug string) (model.Category, error) {
+	var result model.Category
+	return result, repo.DB.First(&result, "slug = ?", slug).Error
+}
+
+func (repo *CategoryRepository) SlugTaken(slug string, exceptID uint) (bool, error) {
+	return slugTaken(repo.DB, &model.Category{}, slug, exceptID)
+}
+
+// GetWithoutSlug returns the categories created before slugs existed.
+func (repo *CategoryRepository) GetWithoutSlug() ([]model.Category, error) {
+	var result []model.Category
+	return result, repo.DB.Where("slug IS NULL OR slug = ''").Order("id").Find(&result).Error
+}
+
+func (repo *CategoryRepository) SetSlug(id uint, slug string) error {
+	return repo.DB.Model(&model.Category{}).Where("id = ?", id).Update("slug", slug).Error
+}
+
+// Descendants returns the ID of the category followed by the IDs of all the
+// categories below it. It's empty when the category doesn't exist.
+func (repo *CategoryRepository) Descendants(id uint) ([]uint, error) {
+	return categoryDescendants(repo.DB, id)
+}
+
+// Ancestors returns the path from the root category down to the category.
+func (repo *CategoryRepository) Ancestors(id uint) ([]model.Category, error) {
+	var result []model.Category
+	return result, repo.DB.Raw(`WITH RECURSIVE path AS (
+		SELECT categories.*, 0 AS depth FROM categories WHERE id = ? AND deleted_at IS NULL
+		UNION ALL
+		SELECT categories.*, path.depth + 1 FROM categories JOIN path ON categories.id = path.parent_id
+		WHERE categories.deleted_at IS NULL
+	) SELECT id, name, slug, parent_id, created_at, updated_at FROM path ORDER BY depth DESC`, id).Scan(&result).Error
+}
+
+// CATEGORY_TREE_LOCK is the advisory lock key held while the category tree is
+// restructured.
+const CATEGORY_TREE_LOCK = 4101
+
+// Move puts the category with its subtree under a new parent, or at the root
+// when parentID is nil. Moves are serialized so two concurrent moves can't
+// create a cycle together.
+func (repo *CategoryRepository) Move(id uint, parentID *uint) error {
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockCategoryTree(tx); err != nil {
+			return err
+		}
+		if parentID != nil {
+			if err := categoryExists(tx, *parentID, util.ParentCategoryNotFoundError); err != nil {
+				return err
+			}
+			subtree, err := categoryDescendants(tx, id)
+			if err != nil {
+				return err
+			}
+			if err := moveError(subtree, *parentID); err != nil {
+				return err
+			}
+		}
+		result := tx.Model(&model.Category{}).Where("id = ?", id).Update("parent_id", parentID)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return nil
+	})
+}
+
+// moveError returns util.CategoryCycleError when the new parent is in the
+// subtree of the moved category, the category itself included.
+func moveError(subtree []uint, parentID uint) error {
+	if slices.Contains(subtree, parentID) {
+		return util.CategoryCycleError
+	}
+	return nil
+}
+
+// CategoryDeletion reports what was moved to the target category when a
+// category was deleted.
+type CategoryDeletion struct {
+	CategoryID    uint  `json:"category_id"`
+	ReassignedTo  *uint `json:"reassigned_to"`
+	Products      int64 `json:"products"`
+	Subcategories int64 `json:"subcategories"`
+	Promotions    int64 `json:"promotions"`
+	Coupons       int64 `json:"coupons"`
+	Attributes    int64 `json:"attributes"`
+}
+
+// InUse reports whether anything has to be reassigned before the category is
+// deleted. Attribute definitions don't count as they can be dropped.
+func (deletion CategoryDeletion) InUse() bool {
+	return deletion.Products+deletion.Subcategories+deletion.Promotions+deletion.Coupons > 0
+}
+
+// deletionError checks that a category can be deleted: a category in use
+// needs a target to reassign to, and that target can't be in its subtree.
+func deletionError(deletion CategoryDeletion, subtree []uint) error {
+	if deletion.ReassignedTo == nil {
+		if deletion.InUse() {
+			return util.CategoryInUseError
+		}
+		return nil
+	}
+	if slices.Contains(subtree, *deletion.ReassignedTo) {
+		return util.CategoryReassignError
+	}
+	return nil
+}
+
+// restoredParent returns the parent a restored category goes under given the
+// error of looking it up: the root when the parent is deleted too.
+func restoredParent(parentID *uint, err error) (*uint, error) {
+	if errors.Is(err, util.ParentCategoryNotFoundError) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parentID, nil
+}
+
+// Delete soft deletes a category. A category that still has products,
+// subcategories, promotions or coupons is only deleted when reassignTo is
+// given; everything is moved to that category in the same transaction,
+// deleted products and subcategories included so they come back under it
+// when restored. The attribute definitions of the category move along, or
+// are dropped when nothing is reassigned.
+func (repo *CategoryRepository) Delete(id uint, reassignTo *uint) (CategoryDeletion, error) {
+	report := CategoryDeletion{CategoryID: id, ReassignedTo: reassignTo}
+	err := repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockCategoryTree(tx); err != nil {
+			return err
+		}
+		if err := categoryExists(tx, id, gorm.ErrRecordNotFound); err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Product{}).Where("category_id = ?", id).Count(&report.Products).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Category{}).Where("parent_id = ?", id).Count(&report.Subcategories).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.Promotion{}).Where("category_id = ?", id).Count(&report.Promotions).Error; err != nil {
+			return err
+		}
+		if err := tx.Table("coupon_categories").Where("category_id = ?", id).Count(&report.Coupons).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&model.AttributeDefinition{}).Where("category_id = ?", id).Count(&report.Attributes).Error; err != nil {
+			return err
+		}
+		var subtree []uint
+		if reassignTo != nil {
+			if err := categoryExists(tx, *reassignTo, util.ReassignCategoryNotFoundError); err != nil {
+				return err
+			}
+			var err error
+			if subtree, err = categoryDescendants(tx, id); err != nil {
+				return err
+			}
+		}
+		if err := deletionError(report, subtree); err != nil {
+			return err
+		}
+
+		if reassignTo != nil {
+			if err := tx.Unscoped().Model(&model.Product{}).Where("category_id = ?", id).Update("category_id", *reassignTo).Error; err != nil {
+				return err
+			}
+			if err := tx.Unscoped().Model(&model.Category{}).Where("parent_id = ?", id).Update("parent_id", *reassignTo).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&model.Promotion{}).Where("category_id = ?", id).Update("category_id", *reassignTo).Error; err != nil {
+				return err
+			}
+			err := tx.Exec(`INSERT INTO coupon_categories (coupon_id, category_id)
+				SELECT coupon_id, ? FROM coupon_categories WHERE category_id = ?
+				ON CONFLICT DO NOTHING`, *reassignTo, id).Error
+			if err != nil {
+				return err
+			}
+			if err := tx.Table("coupon_categories").Where("category_id = ?", id).Delete(nil).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&model.AttributeDefinition{}).Where("category_id = ?", id).Update("category_id", *reassignTo).Error; err != nil {
+				return err
+			}
+		} else if err := tx.Where("category_id = ?", id).Delete(&model.AttributeDefinition{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&model.Category{}, id).Error
+	})
+	return report, err
+}
+
+// GetDeleted returns the deleted categories, most recently deleted first.
+func (repo *CategoryRepository) GetDeleted() ([]model.Category, error) {
+	var result []model.Category
+	return result, repo.DB.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&result).Error
+}
+
+// Restore brings back a deleted category. It goes to the root of the tree
+// when its parent is deleted too. What was reassigned when it was deleted
+// stays where it was moved.
+func (repo *CategoryRepository) Restore(id uint) (model.Category, error) {
+	var category model.Category
+	err := repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockCategoryTree(tx); err != nil {
+			return err
+		}
+		if err := tx.Unscoped().First(&category, "id = ? AND deleted_at IS NOT NULL", id).Error; err != nil {
+			return err
+		}
+		if category.ParentID != nil {
+			parentID, err := restoredParent(category.ParentID, categoryExists(tx, *category.ParentID, util.ParentCategoryNotFoundError))
+			if err != nil {
+				return err
+			}
+			category.ParentID = parentID
+		}
+		category.DeletedAt = gorm.DeletedAt{}
+		return tx.Unscoped().Model(&model.Category{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"parent_id":  category.ParentID,
+			"deleted_at": nil,
+		}).Error
+	})
+	return category, err
+}
+
+// lockCategoryTree serializes the changes to the structure of the category
+// tree until the transaction ends.
+func lockCategoryTree(tx *gorm.DB) error {
+	return tx.Exec("SELECT pg_advisory_xact_lock(?)", CATEGORY_TREE_LOCK).Error
+}
+
+// categoryExists returns notFound when there is no category with the ID.
+func categoryExists(tx *gorm.DB, id uint, notFound error) error {
+	var count int64
+	if err := tx.Model(&model.Category{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return notFound
+	}
+	return nil
+}
+
+func categoryDescendants(db *gorm.DB, id uint) ([]uint, error) {
+	var result []uint
+	return result, db.Raw(`WITH RECURSIVE tree AS (
+		SELECT id FROM categories WHERE id = ? AND deleted_at IS NULL
+		UNION ALL
+		SELECT categories.id FROM categories JOIN tree ON categories.parent_id = tree.id
+		WHERE categories.deleted_at IS NULL
+	) SELECT id FROM tree`, id).Scan(&result).Error
 }
 
 // Product Repository
@@ -57,30 +434,218 @@ type ProductRepository struct {
 	DB *gorm.DB
 }
 
+const (
+	PRODUCT_SORT_RATING  = "rating"
+	PRODUCT_SORT_REVIEWS = "reviews"
+)
+
+// ProductFilter narrows down and orders the product listing. Products without
+// reviews have a rating of zero. CategoryIDs keeps only the products in those
+// categories when set.
+type ProductFilter struct {
+	MinRating   float64
+	Sort        string
+	CategoryIDs []uint
+	Attributes  []AttributeCondition
+}
+
+// AttributeCondition keeps the products whose value of the attribute is one of
+// Values and, for numbers, within Min and Max.
+type AttributeCondition struct {
+	AttributeID uint
+	Values      []string
+	Min         *float64
+	Max         *float64
+}
+
 func (repo *ProductRepository) Get(id string) (model.Product, error) {
 	var result model.Product
-	return result, repo.DB.Preload("Category").Where("id = $1", id).First(&result).Error
+	return result, repo.DB.Preload("Category").Preload("Rating").Preload("Attributes.Attribute").Where("id = $1", id).First(&result).Error
 }
 
-func (repo *ProductRepository) GetAll() ([]model.Product, error) {
+func (repo *ProductRepository) GetAll(filter ProductFilter) ([]model.Product, error) {
 	var result []model.Product
-	return result, repo.DB.Find(&result).Error
+	query := repo.DB.Preload("Rating").Preload("Attributes.Attribute").Joins("LEFT JOIN product_ratings ON product_ratings.product_id = products.id")
+	if filter.MinRating > 0 {
+		query = query.Where("COALESCE(product_ratings.average, 0) >= ?", filter.MinRating)
+	}
+	if filter.CategoryIDs != nil {
+		query = query.Where("products.category_id IN ?", filter.CategoryIDs)
+	}
+	for _, condition := range filter.Attributes {
+		values := repo.DB.Model(&model.ProductAttributeValue{}).Select("1").
+			Where("product_attribute_values.product_id = products.id AND product_attribute_values.attribute_id = ?", condition.AttributeID)
+		if len(condition.Values) > 0 {
+			values = values.Where("product_attribute_values.value IN ?", condition.Values)
+		}
+		if condition.Min != nil {
+			values = values.Where("product_attribute_values.number_value >= ?", *condition.Min)
+		}
+		if condition.Max != nil {
+			values = values.Where("product_attribute_values.number_value <= ?", *condition.Max)
+		}
+		query = query.Where("EXISTS (?)", values)
+	}
+	switch filter.Sort {
+	case PRODUCT_SORT_RATING:
+		query = query.Order("COALESCE(product_ratings.average, 0) DESC").Order("COALESCE(product_ratings.count, 0) DESC")
+	case PRODUCT_SORT_REVIEWS:
+		query = query.Order("COALESCE(product_ratings.count, 0) DESC")
+	}
+	return result, query.Order("products.id").Find(&result).Error
+}
+
+func (repo *ProductRepository) GetByIDs(ids []uint) ([]model.Product, error) {
+	var result []model.Product
+	return result, repo.DB.Where("id IN ?", ids).Find(&result).Error
 }
 
-func (repo *ProductRepository) Create(product model.Product) error {
-	return repo.DB.Create(&product).Error
+// Create saves the product with its attribute values and starts its price
+// history and its stock ledger in one transaction. The stock of the product
+// is recorded as the movement.
+func (repo *ProductRepository) Create(product model.Product, movement model.StockMovement) error {
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		stock := product.Stock
+		product.Stock = 0
+		if err := tx.Omit("Rating", "Attributes").Create(&product).Error; err != nil {
+			return err
+		}
+		if err := recordPrice(tx, product.ID, product.Price, model.PRICE_MANUAL, nil); err != nil {
+			return err
+		}
+		if stock > 0 {
+			movement.ProductID = product.ID
+			movement.Quantity = int(stock)
+			if _, err := moveStock(tx, movement); err != nil {
+				return err
+			}
+		}
+		return replaceAttributes(tx, product.ID, product.Attributes)
+	})
 }
 
+// Update saves the product with its attribute values, keeps its old slug as a
+// redirect when the slug changed and adds the new price to its history when
+// the price changed. Stock is left alone; it only changes through movements.
 func (repo *ProductRepository) Update(product model.Product) error {
-	return repo.DB.Save(&product).Error
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		return updateProduct(tx, product)
+	})
+}
+
+// UpdateWithStock saves the product and sets its stock, recorded as the
+// movement, in one transaction.
+func (repo *ProductRepository) UpdateWithStock(product model.Product, movement model.StockMovement, stock uint) error {
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := updateProduct(tx, product); err != nil {
+			return err
+		}
+		movement.ProductID = product.ID
+		_, err := setStock(tx, movement, stock)
+		return err
+	})
+}
+
+// updateProduct saves the product but not its stock, replaces its attribute
+// values and records a change of its price or slug.
+func updateProduct(tx *gorm.DB, product model.Product) error {
+	var old model.Product
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "slug", "price").First(&old, "id = ?", product.ID).Error; err != nil {
+		return err
+	}
+	if err := tx.Omit("Rating", "Attributes", "Stock").Save(&product).Error; err != nil {
+		return err
+	}
+	if err := replaceAttributes(tx, product.ID, product.Attributes); err != nil {
+		return err
+	}
+	if old.Price != product.Price {
+		if err := recordPrice(tx, product.ID, product.Price, model.PRICE_MANUAL, nil); err != nil {
+			return err
+		}
+	}
+	return recordSlugChange(tx, model.SLUG_PRODUCT, product.ID, old.Slug, product.Slug)
+}
+
+func (repo *ProductRepository) GetBySlug(slug string) (model.Product, error) {
+	var result model.Product
+	return result, repo.DB.Preload("Category").Preload("Rating").Preload("Attributes.Attribute").Where("slug = ?", slug).First(&result).Error
+}
+
+func (repo *ProductRepository) SlugTaken(slug string, exceptID uint) (bool, error) {
+	return slugTaken(repo.DB, &model.Product{}, slug, exceptID)
+}
+
+func (repo *ProductRepository) GetBySKU(sku string) (model.Product, error) {
+	var result model.Product
+	return result, repo.DB.Where("sku = ?", sku).First(&result).Error
+}
+
+func (repo *ProductRepository) SKUTaken(sku string, exceptID uint) (bool, error) {
+	var count int64
+	err := repo.DB.Unscoped().Model(&model.Product{}).Where("sku = ? AND id <> ?", sku, exceptID).Count(&count).Error
+	return count > 0, err
+}
+
+// GetWithoutSlug returns the products created before slugs existed.
+func (repo *ProductRepository) GetWithoutSlug() ([]model.Product, error) {
+	var result []model.Product
+	return result, repo.DB.Where("slug IS NULL OR slug = ''").Order("id").Find(&result).Error
+}
+
+func (repo *ProductRepository) SetSlug(id uint, slug string) error {
+	return repo.DB.Model(&model.Product{}).Where("id = ?", id).Update("slug", slug).Error
 }
 
+// Delete soft deletes the product. It keeps its attribute values, and its
+// slug and SKU stay reserved, so it can be restored as it was.
 func (repo *ProductRepository) Delete(id string) error {
 	product, err := repo.Get(id)
 	if err != nil {
 		return err
 	}
-	return repo.DB.Delete(product).Error
+	return repo.DB.Delete(&model.Product{}, product.ID).Error
+}
+
+// GetDeleted returns the deleted products, most recently deleted first.
+func (repo *ProductRepository) GetDeleted() ([]model.Product, error) {
+	var result []model.Product
+	return result, repo.DB.Unscoped().Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&result).Error
+}
+
+// Restore brings back a deleted product. Its category must not be deleted,
+// so the category is locked against deletion while the product is restored.
+func (repo *ProductRepository) Restore(id uint) (model.Product, error) {
+	var product model.Product
+	err := repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().First(&product, "id = ? AND deleted_at IS NOT NULL", id).Error; err != nil {
+			return err
+		}
+		if err := lockCategoryTree(tx); err != nil {
+			return err
+		}
+		if err := categoryExists(tx, product.CategoryID, util.ProductCategoryDeletedError); err != nil {
+			return err
+		}
+		product.DeletedAt = gorm.DeletedAt{}
+		return tx.Unscoped().Model(&model.Product{}).Where("id = ?", id).Update("deleted_at", nil).Error
+	})
+	return product, err
+}
+
+// replaceAttributes replaces the attribute values of a product.
+func replaceAttributes(tx *gorm.DB, productID uint, values []model.ProductAttributeValue) error {
+	if err := tx.Where("product_id = ?", productID).Delete(&model.ProductAttributeValue{}).Error; err != nil {
+		return err
+	}
+	if len(values) == 0 {
+		return nil
+	}
+	for i := range values {
+		values[i].ID = 0
+		values[i].ProductID = productID
+	}
+	return tx.Omit("Attribute").Create(&values).Error
 }
 
 // User Repository
@@ -112,25 +677,359 @@ type ReviewRepository struct {
 	DB *gorm.DB
 }
 
+const (
+	REVIEW_SORT_NEWEST  = "newest"
+	REVIEW_SORT_HIGHEST = "highest"
+	REVIEW_SORT_LOWEST  = "lowest"
+	REVIEW_SORT_HELPFUL = "helpful"
+)
+
+// ReviewFilter selects a page of the reviews of a product. Rating keeps only
+// the reviews with that many stars when set.
+type ReviewFilter struct {
+	Rating   int
+	Sort     string
+	Page     int
+	PageSize int
+}
+
 func (repo *ReviewRepository) Get(id string) (model.Review, error) {
 	var result model.Review
-	return result, repo.DB.Model(&model.Review{}).First(&result, "id = $1", id).Error
+	return result, repo.DB.Model(&model.Review{}).Preload("User").Preload("Reply").First(&result, "id = $1", id).Error
+}
+
+// GetByStatus returns the reviews in a moderation status, oldest first.
+func (repo *ReviewRepository) GetByStatus(status model.ReviewStatus) ([]model.Review, error) {
+	var result []model.Review
+	return result, repo.DB.Where("status = ?", status).Order("created_at ASC").Order("id ASC").Find(&result).Error
+}
+
+// GetByProduct returns a page of the approved reviews of a product with their authors
+// and the number of reviews matching the filter.
+func (repo *ReviewRepository) GetByProduct(productID uint, filter ReviewFilter) ([]model.Review, int64, error) {
+	var result []model.Review
+	var total int64
+	query := repo.DB.Model(&model.Review{}).Where("product_id = ? AND status = ?", productID, model.REVIEW_APPROVED)
+	if filter.Rating > 0 {
+		query = query.Where("rating = ?", filter.Rating)
+	}
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	switch filter.Sort {
+	case REVIEW_SORT_HIGHEST:
+		query = query.Order("rating DESC")
+	case REVIEW_SORT_LOWEST:
+		query = query.Order("rating ASC")
+	case REVIEW_SORT_HELPFUL:
+		query = query.Order("helpful_count - unhelpful_count DESC").Order("helpful_count DESC")
+	}
+	err := query.Preload("User").Preload("Reply").Order("created_at DESC").Order("id DESC").
+		Offset((filter.Page - 1) * filter.PageSize).Limit(filter.PageSize).
+		Find(&result).Error
+	return result, total, err
 }
 
+// Create saves the review and refreshes the rating of its product in one
+// transaction.
 func (repo *ReviewRepository) Create(review model.Review) error {
-	return repo.DB.Create(&review).Error
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockProduct(tx, review.ProductID); err != nil {
+			return err
+		}
+		if err := tx.Create(&review).Error; err != nil {
+			return err
+		}
+		return refreshRating(tx, review.ProductID)
+	})
+}
+
+// DeleteDuplicates deletes all but the latest review of a user on a product,
+// left from before a user could only review a product once, and refreshes the
+// rating of their products.
+func (repo *ReviewRepository) DeleteDuplicates() error {
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		latest := tx.Model(&model.Review{}).Select("MAX(id)").Group("user_id, product_id")
+		var duplicates []model.Review
+		if err := tx.Select("id", "product_id").Where("id NOT IN (?)", latest).Find(&duplicates).Error; err != nil {
+			return err
+		}
+		if len(duplicates) == 0 {
+			return nil
+		}
+		var ids, productIDs []uint
+		for _, review := range duplicates {
+			ids = append(ids, review.ID)
+			if !slices.Contains(productIDs, review.ProductID) {
+				productIDs = append(productIDs, review.ProductID)
+			}
+		}
+		if err := tx.Delete(&model.Review{}, ids).Error; err != nil {
+			return err
+		}
+		for _, productID := range productIDs {
+			if err := refreshRating(tx, productID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
 }
 
+// Update saves the review and refreshes the rating of its product in one
+// transaction.
 func (repo *ReviewRepository) Update(review model.Review) error {
-	return repo.DB.Save(&review).Error
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockProduct(tx, review.ProductID); err != nil {
+			return err
+		}
+		if err := tx.Omit("User", "Product", "Reply").Save(&review).Error; err != nil {
+			return err
+		}
+		return refreshRating(tx, review.ProductID)
+	})
+}
+
+// Moderate sets the moderation status of the review, clears its reports and
+// refreshes the rating of its product in one transaction.
+func (repo *ReviewRepository) Moderate(review model.Review, status model.ReviewStatus, reason string) error {
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockProduct(tx, review.ProductID); err != nil {
+			return err
+		}
+		result := tx.Model(&model.Review{}).Where("id = ?", review.ID).Updates(map[string]interface{}{
+			"status":            status,
+			"moderation_reason": reason,
+			"moderated_at":      time.Now(),
+			"report_count":      0,
+		})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		// The reports were dealt with, so their users can report the review
+		// again.
+		if err := tx.Where("review_id = ?", review.ID).Delete(&model.ReviewReport{}).Error; err != nil {
+			return err
+		}
+		return refreshRating(tx, review.ProductID)
+	})
+}
+
+// Vote saves the vote of a user on a review, replacing their earlier vote,
+// and recounts the votes of the review.
+func (repo *ReviewRepository) Vote(vote model.ReviewVote) error {
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockReview(tx, vote.ReviewID); err != nil {
+			return err
+		}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "review_id"}, {Name: "user_id"}},
+			DoUpdates: clause.AssignmentColumns([]string{"helpful", "updated_at"}),
+		}).Create(&vote).Error
+		if err != nil {
+			return err
+		}
+		return refreshVotes(tx, vote.ReviewID)
+	})
+}
+
+// Unvote removes the vote of a user on a review and recounts the votes of the
+// review.
+func (repo *ReviewRepository) Unvote(reviewID uint, userID uint) error {
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockReview(tx, reviewID); err != nil {
+			return err
+		}
+		result := tx.Where("review_id = ? AND user_id = ?", reviewID, userID).Delete(&model.ReviewVote{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+		return refreshVotes(tx, reviewID)
+	})
+}
+
+// Report saves the report of a user on a review. An approved review goes back
+// to the moderation queue once it collects threshold reports since it was last
+// moderated.
+func (repo *ReviewRepository) Report(review model.Review, report model.ReviewReport, threshold int) error {
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockProduct(tx, review.ProductID); err != nil {
+			return err
+		}
+		if err := lockReview(tx, review.ID); err != nil {
+			return err
+		}
+		var reported int64
+		err := tx.Model(&model.ReviewReport{}).Where("review_id = ? AND user_id = ?", report.ReviewID, report.UserID).Count(&reported).Error
+		if err != nil {
+			return err
+		}
+		if reported > 0 {
+			return util.ReviewReportedError
+		}
+		if err := tx.Create(&report).Error; err != nil {
+			return err
+		}
+
+		var current model.Review
+		if err := tx.Select("id", "status", "report_count").First(&current, "id = ?", review.ID).Error; err != nil {
+			return err
+		}
+		updates := map[string]interface{}{"report_count": current.ReportCount + 1}
+		held := current.Status == model.REVIEW_APPROVED && current.ReportCount+1 >= threshold
+		if held {
+			updates["status"] = model.REVIEW_PENDING
+			updates["moderation_reason"] = "Reported by users"
+		}
+		if err := tx.Model(&model.Review{}).Where("id = ?", review.ID).Updates(updates).Error; err != nil {
+			return err
+		}
+		if held {
+			return refreshRating(tx, review.ProductID)
+		}
+		return nil
+	})
+}
+
+// CreateReply saves the reply to a review and notifies the reviewer in one
+// transaction.
+func (repo *ReviewRepository) CreateReply(reply model.ReviewReply, notification model.Notification) (model.ReviewReply, error) {
+	return reply, repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockReview(tx, reply.ReviewID); err != nil {
+			return err
+		}
+		var replied int64
+		if err := tx.Model(&model.ReviewReply{}).Where("review_id = ?", reply.ReviewID).Count(&replied).Error; err != nil {
+			return err
+		}
+		if replied > 0 {
+			return util.ReviewReplyExistsError
+		}
+		if err := tx.Create(&reply).Error; err != nil {
+			return err
+		}
+		return tx.Create(&notification).Error
+	})
+}
+
+func (repo *ReviewRepository) UpdateReply(reply model.ReviewReply) (model.ReviewReply, error) {
+	return reply, repo.DB.Save(&reply).Error
+}
+
+func (repo *ReviewRepository) DeleteReply(reply model.ReviewReply) error {
+	return repo.DB.Delete(&reply).Error
 }
 
+func (repo *ReviewRepository) GetByUserAndProduct(userID uint, productID uint) (model.Review, error) {
+	var result model.Review
+	return result, repo.DB.Where("user_id = ? AND product_id = ?", userID, productID).First(&result).Error
+}
+
+// Delete soft deletes the review and refreshes the rating of its product in
+// one transaction. Its votes, reports and reply are kept for a restore.
 func (repo *ReviewRepository) Delete(id string) error {
 	review, err := repo.Get(id)
 	if err != nil {
 		return err
 	}
-	return repo.DB.Delete(&review).Error
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := lockProduct(tx, review.ProductID); err != nil {
+			return err
+		}
+		if err := tx.Delete(&model.Review{}, review.ID).Error; err != nil {
+			return err
+		}
+		return refreshRating(tx, review.ProductID)
+	})
+}
+
+// GetDeleted returns the deleted reviews, most recently deleted first.
+func (repo *ReviewRepository) GetDeleted() ([]model.Review, error) {
+	var result []model.Review
+	return result, repo.DB.Unscoped().Preload("Reply").Where("deleted_at IS NOT NULL").Order("deleted_at DESC").Find(&result).Error
+}
+
+// Restore brings back a deleted review and refreshes the rating of its
+// product in one transaction. It fails with ReviewExistsError when the user
+// has written another review of the product since.
+func (repo *ReviewRepository) Restore(id uint) (model.Review, error) {
+	var review model.Review
+	err := repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().First(&review, "id = ? AND deleted_at IS NOT NULL", id).Error; err != nil {
+			return err
+		}
+		if err := lockProduct(tx, review.ProductID); err != nil {
+			return err
+		}
+		var count int64
+		if err := tx.Model(&model.Review{}).Where("user_id = ? AND product_id = ?", review.UserID, review.ProductID).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return util.ReviewExistsError
+		}
+		if err := tx.Unscoped().Model(&model.Review{}).Where("id = ?", id).Update("deleted_at", nil).Error; err != nil {
+			return err
+		}
+		review.DeletedAt = gorm.DeletedAt{}
+		return refreshRating(tx, review.ProductID)
+	})
+	return review, err
+}
+
+// lockProduct locks the products so concurrent review writes refresh their
+// ratings one after another.
+func lockProduct(tx *gorm.DB, ids ...uint) error {
+	var products []model.Product
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").Where("id IN ?", ids).Order("id").Find(&products).Error
+}
+
+// lockReview locks the review so concurrent votes and reports on it are
+// counted one after another.
+func lockReview(tx *gorm.DB, id uint) error {
+	var review model.Review
+	return tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&review, "id = ?", id).Error
+}
+
+// refreshVotes recounts the helpful and unhelpful votes of a review.
+func refreshVotes(tx *gorm.DB, reviewID uint) error {
+	helpful := tx.Model(&model.ReviewVote{}).Select("COUNT(*)").Where("review_id = ? AND helpful", reviewID)
+	unhelpful := tx.Model(&model.ReviewVote{}).Select("COUNT(*)").Where("review_id = ? AND NOT helpful", reviewID)
+	return tx.Model(&model.Review{}).Where("id = ?", reviewID).Updates(map[string]interface{}{
+		"helpful_count":   helpful,
+		"unhelpful_count": unhelpful,
+	}).Error
+}
+
+// refreshRating recalculates the rating of a product from its approved
+// reviews. Reviews written before ratings existed aren't counted.
+func refreshRating(tx *gorm.DB, productID uint) error {
+	rating := model.ProductRating{ProductID: productID}
+	err := tx.Model(&model.Review{}).
+		Select("COUNT(*) AS count, COALESCE(AVG(rating), 0) AS average, "+
+			"COUNT(*) FILTER (WHERE rating = 1) AS one_star, "+
+			"COUNT(*) FILTER (WHERE rating = 2) AS two_stars, "+
+			"COUNT(*) FILTER (WHERE rating = 3) AS three_stars, "+
+			"COUNT(*) FILTER (WHERE rating = 4) AS four_stars, "+
+			"COUNT(*) FILTER (WHERE rating = 5) AS five_stars").
+		Where("product_id = ? AND status = ? AND rating BETWEEN 1 AND 5", productID, model.REVIEW_APPROVED).
+		Scan(&rating).Error
+	if err != nil {
+		return err
+	}
+	rating.ProductID = productID
+	rating.Average = math.Round(rating.Average*100) / 100
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"average", "count", "one_star", "two_stars", "three_stars", "four_stars", "five_stars", "updated_at"}),
+	}).Create(&rating).Error
 }
 
 type OrderRepository struct {
@@ -139,7 +1038,20 @@ type OrderRepository struct {
 
 func (repo *OrderRepository) Get(id string) (model.Order, error) {
 	var result model.Order
-	return result, repo.DB.Preload("User").Preload("Products").Preload("Products.Product").First(&result, "id = $1", id).Error
+	return result, repo.DB.Preload("User").Preload("Products").Preload("Products.Product", func(db *gorm.DB) *gorm.DB {
+		return db.Unscoped()
+	}).Preload("Products.Taxes").Preload("Discounts").First(&result, "id = $1", id).Error
+}
+
+// BackfillSnapshots copies the name and SKU of the product to the order items
+// saved before they were captured at checkout.
+func (repo *OrderRepository) BackfillSnapshots() error {
+	name := repo.DB.Unscoped().Model(&model.Product{}).Select("name").Where("products.id = order_items.product_id")
+	sku := repo.DB.Unscoped().Model(&model.Product{}).Select("sku").Where("products.id = order_items.product_id")
+	return repo.DB.Model(&model.OrderItem{}).Where("product_name IS NULL OR product_name = ''").Updates(map[string]interface{}{
+		"product_name": gorm.Expr("COALESCE((?), '')", name),
+		"sku":          gorm.Expr("COALESCE((?), '')", sku),
+	}).Error
 }
 
 func (repo *OrderRepository) GetAll() ([]model.Order, error) {
@@ -147,15 +1059,140 @@ func (repo *OrderRepository) GetAll() ([]model.Order, error) {
 	return result, repo.DB.Model(&model.Order{}).Find(&result).Error
 }
 
-func (repo *OrderRepository) Create(order model.Order) error {
-	err := repo.DB.Create(&order).Error
-	return err
+// Create saves the order, takes its items out of stock and redeems the
+// coupons used by its discounts in one transaction.
+func (repo *OrderRepository) Create(order model.Order) (model.Order, error) {
+	err := repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&order).Error; err != nil {
+			return err
+		}
+		ids := make([]uint, len(order.Products))
+		for i, item := range order.Products {
+			ids[i] = item.ProductID
+		}
+		if err := lockProduct(tx, ids...); err != nil {
+			return err
+		}
+		for _, item := range order.Products {
+			movement := model.StockMovement{ProductID: item.ProductID, Quantity: -item.Quantity, Reason: model.STOCK_SALE, UserID: &order.UserID, OrderID: &order.ID}
+			if _, err := moveStock(tx, movement); err != nil {
+				return err
+			}
+		}
+		for _, discount := range order.Discounts {
+			if discount.CouponID == nil {
+				continue
+			}
+			// The coupon is locked so the usages of the user are counted
+			// without another checkout adding one in between.
+			var coupon model.Coupon
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&coupon, "id = ?", *discount.CouponID).Error; err != nil {
+				return err
+			}
+			if coupon.PerUserLimit > 0 {
+				var used int64
+				if err := tx.Model(&model.CouponUsage{}).Where("coupon_id = ? AND user_id = ?", coupon.ID, order.UserID).Count(&used).Error; err != nil {
+					return err
+				}
+				if used >= int64(coupon.PerUserLimit) {
+					return util.CouponUserLimitError
+				}
+			}
+			result := tx.Model(&model.Coupon{}).
+				Where("id = ? AND (usage_limit = 0 OR used_count < usage_limit)", *discount.CouponID).
+				Update("used_count", gorm.Expr("used_count + 1"))
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return util.CouponUsageLimitError
+			}
+			usage := model.CouponUsage{CouponID: *discount.CouponID, UserID: order.UserID, OrderID: order.ID}
+			if err := tx.Create(&usage).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	return order, err
 }
 
 func (repo *OrderRepository) Update(order model.Order) error {
 	return repo.DB.Model(&model.Order{}).Save(order).Error
 }
 
+// HasPurchased reports whether the user has a paid order containing the
+// product.
+func (repo *OrderRepository) HasPurchased(userID uint, productID uint) (bool, error) {
+	var count int64
+	err := repo.DB.Model(&model.OrderItem{}).
+		Joins("JOIN orders ON orders.id = order_items.order_id").
+		Where("orders.user_id = ? AND order_items.product_id = ?", userID, productID).
+		Where("orders.status IN ?", []model.OrderStatus{model.ORDER_PAID, model.ORDER_PARTIALLY_SHIPPED, model.ORDER_SHIPPED, model.ORDER_DELIVERED}).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// Cancel cancels a pending order, puts back in stock what was taken out for it
+// and releases the coupons it used. Orders placed before stock movements were
+// recorded took nothing out, so nothing goes back for them. A nil actor is the
+// system.
+func (repo *OrderRepository) Cancel(id uint, actorID *uint) error {
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.Order{}).Where("id = ? AND status = ?", id, model.ORDER_PENDING).Update("status", model.ORDER_CANCELLED)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return util.OrderNotCancellableError
+		}
+		type taken struct {
+			ProductID uint
+			Quantity  int
+		}
+		var items []taken
+		err := tx.Model(&model.StockMovement{}).Select("product_id, -SUM(quantity) AS quantity").
+			Where("order_id = ? AND reason IN ?", id, []model.StockReason{model.STOCK_SALE, model.STOCK_CANCELLATION}).
+			Group("product_id").Having("SUM(quantity) < 0").Order("product_id").Scan(&items).Error
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			movement := model.StockMovement{ProductID: item.ProductID, Quantity: item.Quantity, Reason: model.STOCK_CANCELLATION, UserID: actorID, OrderID: &id}
+			if _, err := moveStock(tx, movement); err != nil {
+				return err
+			}
+		}
+
+		var usages []model.CouponUsage
+		if err := tx.Where("order_id = ?", id).Order("coupon_id").Find(&usages).Error; err != nil {
+			return err
+		}
+		for _, usage := range usages {
+			err := tx.Unscoped().Model(&model.Coupon{}).Where("id = ? AND used_count > 0", usage.CouponID).
+				Update("used_count", gorm.Expr("used_count - 1")).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Where("order_id = ?", id).Delete(&model.CouponUsage{}).Error
+	})
+}
+
+// GetExpired returns the IDs of the orders still pending since before a time.
+func (repo *OrderRepository) GetExpired(before time.Time) ([]uint, error) {
+	var result []uint
+	return result, repo.DB.Model(&model.Order{}).Where("status = ? AND created_at < ?", model.ORDER_PENDING, before).
+		Order("id").Pluck("id", &result).Error
+}
+
+// UpdateStatus moves an order from one status to another and reports whether
+// the order was still in the expected status.
+func (repo *OrderRepository) UpdateStatus(id uint, from model.OrderStatus, to model.OrderStatus) (bool, error) {
+	result := repo.DB.Model(&model.Order{}).Where("id = ? AND status = ?", id, from).Update("status", to)
+	return result.RowsAffected == 1, result.Error
+}
+
 type PaymentRepository struct {
 	DB *gorm.DB
 }
@@ -167,3 +1204,1213 @@ func (repo *PaymentRepository) Create(payment model.Payment) error {
 func (repo *PaymentRepository) Update(payment model.Payment) error {
 	return repo.DB.Model(&model.Payment{}).Save(payment).Error
 }
+
+// CountFailedByOrder returns how many payments of the order failed.
+func (repo *PaymentRepository) CountFailedByOrder(orderID uint) (int64, error) {
+	var count int64
+	return count, repo.DB.Model(&model.Payment{}).Where("order_id = ? AND status = ?", orderID, model.FAILED).Count(&count).Error
+}
+
+func (repo *PaymentRepository) GetSuccessfulByOrder(orderID uint) (model.Payment, error) {
+	var result model.Payment
+	return result, repo.DB.Where("order_id = ? AND status = ?", orderID, model.SUCCESS).First(&result).Error
+}
+
+// Idempotency Repository
+type IdempotencyRepository struct {
+	DB *gorm.DB
+}
+
+func (repo *IdempotencyRepository) Get(userID uint, key string) (model.IdempotencyKey, error) {
+	var result model.IdempotencyKey
+	return result, repo.DB.Where("user_id = ? AND key = ?", userID, key).First(&result).Error
+}
+
+func (repo *IdempotencyRepository) Create(record model.IdempotencyKey) (model.IdempotencyKey, error) {
+	err := repo.DB.Create(&record).Error
+	return record, err
+}
+
+func (repo *IdempotencyRepository) Complete(id uint, response []byte) error {
+	return repo.DB.Model(&model.IdempotencyKey{}).Where("id = ?", id).Updates(map[string]interface{}{"response": response, "completed": true}).Error
+}
+
+func (repo *IdempotencyRepository) Delete(id uint) error {
+	return repo.DB.Delete(&model.IdempotencyKey{}, id).Error
+}
+
+// Exchange Rate Repository
+type ExchangeRateRepository struct {
+	DB *gorm.DB
+}
+
+func (repo *ExchangeRateRepository) Get(currency string) (model.ExchangeRate, error) {
+	var result model.ExchangeRate
+	return result, repo.DB.Model(&model.ExchangeRate{}).First(&result, "currency = $1", currency).Error
+}
+
+func (repo *ExchangeRateRepository) GetAll() ([]model.ExchangeRate, error) {
+	var result []model.ExchangeRate
+	return result, repo.DB.Order("currency").Find(&result).Error
+}
+
+func (repo *ExchangeRateRepository) Save(rate model.ExchangeRate) error {
+	return repo.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"rate", "updated_at"}),
+	}).Create(&rate).Error
+}
+
+func (repo *ExchangeRateRepository) Delete(currency string) error {
+	rate, err := repo.Get(currency)
+	if err != nil {
+		return err
+	}
+	return repo.DB.Delete(&rate).Error
+}
+
+// Product Price Repository
+type ProductPriceRepository struct {
+	DB *gorm.DB
+}
+
+func (repo *ProductPriceRepository) Get(productID uint, currency string) (model.ProductPrice, error) {
+	var result model.ProductPrice
+	return result, repo.DB.Where("product_id = ? AND currency = ?", productID, currency).First(&result).Error
+}
+
+func (repo *ProductPriceRepository) GetByProduct(productID uint) ([]model.ProductPrice, error) {
+	var result []model.ProductPrice
+	return result, repo.DB.Where("product_id = ?", productID).Order("currency").Find(&result).Error
+}
+
+func (repo *ProductPriceRepository) GetByCurrency(currency string) ([]model.ProductPrice, error) {
+	var result []model.ProductPrice
+	return result, repo.DB.Where("currency = ?", currency).Find(&result).Error
+}
+
+func (repo *ProductPriceRepository) Save(price model.ProductPrice) error {
+	return repo.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "product_id"}, {Name: "currency"}},
+		DoUpdates: clause.AssignmentColumns([]string{"price", "updated_at"}),
+	}).Create(&price).Error
+}
+
+func (repo *ProductPriceRepository) Delete(productID uint, currency string) error {
+	price, err := repo.Get(productID, currency)
+	if err != nil {
+		return err
+	}
+	return repo.DB.Delete(&price).Error
+}
+
+// Coupon Repository
+type CouponRepository struct {
+	DB *gorm.DB
+}
+
+func (repo *CouponRepository) Get(id string) (model.Coupon, error) {
+	var result model.Coupon
+	return result, repo.DB.Preload("Products").Preload("Categories").First(&result, "id = $1", id).Error
+}
+
+func (repo *CouponRepository) GetByCode(code string) (model.Coupon, error) {
+	var result model.Coupon
+	return result, repo.DB.Preload("Products").Preload("Categories").First(&result, "code = $1", code).Error
+}
+
+func (repo *CouponRepository) GetAll() ([]model.Coupon, error) {
+	var result []model.Coupon
+	return result, repo.DB.Preload("Products").Preload("Categories").Order("id").Find(&result).Error
+}
+
+func (repo *CouponRepository) Create(coupon model.Coupon) error {
+	return repo.DB.Omit("Products.*", "Categories.*").Create(&coupon).Error
+}
+
+func (repo *CouponRepository) Update(coupon model.Coupon) error {
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Products", "Categories").Save(&coupon).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&coupon).Omit("Products.*").Association("Products").Replace(coupon.Products); err != nil {
+			return err
+		}
+		return tx.Model(&coupon).Omit("Categories.*").Association("Categories").Replace(coupon.Categories)
+	})
+}
+
+func (repo *CouponRepository) Delete(id string) error {
+	coupon, err := repo.Get(id)
+	if err != nil {
+		return err
+	}
+	return repo.DB.Select("Products", "Categories").Delete(&coupon).Error
+}
+
+func (repo *CouponRepository) CountUsages(couponID uint, userID uint) (int64, error) {
+	var count int64
+	return count, repo.DB.Model(&model.CouponUsage{}).Where("coupon_id = ? AND user_id = ?", couponID, userID).Count(&count).Error
+}
+
+// Promotion Repository
+type PromotionRepository struct {
+	DB *gorm.DB
+}
+
+func (repo *PromotionRepository) Get(id string) (model.Promotion, error) {
+	var result model.Promotion
+	return result, repo.DB.Preload("Products").Preload("Tiers").First(&result, "id = $1", id).Error
+}
+
+func (repo *PromotionRepository) GetAll() ([]model.Promotion, error) {
+	var result []model.Promotion
+	return result, repo.DB.Preload("Products").Preload("Tiers").Order("priority DESC, id").Find(&result).Error
+}
+
+// GetRunning returns the active promotions whose validity window contains now.
+func (repo *PromotionRepository) GetRunning(now time.Time) ([]model.Promotion, error) {
+	var result []model.Promotion
+	return result, repo.DB.Preload("Products").Preload("Tiers").
+		Where("active = ? AND (starts_at IS NULL OR starts_at <= ?) AND (ends_at IS NULL OR ends_at > ?)", true, now, now).
+		Order("priority DESC, id").Find(&result).Error
+}
+
+func (repo *PromotionRepository) Create(promotion model.Promotion) error {
+	return repo.DB.Omit("Products.*").Create(&promotion).Error
+}
+
+func (repo *PromotionRepository) Update(promotion model.Promotion) error {
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Products", "Tiers").Save(&promotion).Error; err != nil {
+			return err
+		}
+		if err := tx.Model(&promotion).Omit("Products.*").Association("Products").Replace(promotion.Products); err != nil {
+			return err
+		}
+		if err := tx.Where("promotion_id = ?", promotion.ID).Delete(&model.PromotionTier{}).Error; err != nil {
+			return err
+		}
+		for _, tier := range promotion.Tiers {
+			tier.ID = 0
+			tier.PromotionID = promotion.ID
+			if err := tx.Create(&tier).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (repo *PromotionRepository) Delete(id string) error {
+	promotion, err := repo.Get(id)
+	if err != nil {
+		return err
+	}
+	return repo.DB.Select("Products", "Tiers").Delete(&promotion).Error
+}
+
+// Tax Rate Repository
+type TaxRateRepository struct {
+	DB *gorm.DB
+}
+
+func (repo *TaxRateRepository) Get(id string) (model.TaxRate, error) {
+	var result model.TaxRate
+	return result, repo.DB.Model(&model.TaxRate{}).First(&result, "id = $1", id).Error
+}
+
+func (repo *TaxRateRepository) GetAll() ([]model.TaxRate, error) {
+	var result []model.TaxRate
+	return result, repo.DB.Order("country, region, tax_category").Find(&result).Error
+}
+
+func (repo *TaxRateRepository) GetByCountry(country string) ([]model.TaxRate, error) {
+	var result []model.TaxRate
+	return result, repo.DB.Where("country = ?", country).Find(&result).Error
+}
+
+func (repo *TaxRateRepository) Create(rate model.TaxRate) error {
+	return repo.DB.Create(&rate).Error
+}
+
+func (repo *TaxRateRepository) Update(rate model.TaxRate) error {
+	return repo.DB.Save(&rate).Error
+}
+
+func (repo *TaxRateRepository) Delete(id string) error {
+	rate, err := repo.Get(id)
+	if err != nil {
+		return err
+	}
+	return repo.DB.Delete(&rate).Error
+}
+
+// Address Repository
+type AddressRepository struct {
+	DB *gorm.DB
+}
+
+func (repo *AddressRepository) Get(id string) (model.Address, error) {
+	var result model.Address
+	return result, repo.DB.Model(&model.Address{}).First(&result, "id = $1", id).Error
+}
+
+func (repo *AddressRepository) GetByUser(userID uint) ([]model.Address, error) {
+	var result []model.Address
+	return result, repo.DB.Where("user_id = ?", userID).Order("id").Find(&result).Error
+}
+
+func (repo *AddressRepository) GetDefaultShipping(userID uint) (model.Address, error) {
+	var result model.Address
+	return result, repo.DB.Where("user_id = ? AND is_default_shipping = ?", userID, true).First(&result).Error
+}
+
+func (repo *AddressRepository) GetDefaultBilling(userID uint) (model.Address, error) {
+	var result model.Address
+	return result, repo.DB.Where("user_id = ? AND is_default_billing = ?", userID, true).First(&result).Error
+}
+
+// Save creates or updates an address and clears the default flags of the
+// user's other addresses when the address takes them over.
+func (repo *AddressRepository) Save(address model.Address) (model.Address, error) {
+	err := repo.DB.Transaction(func(tx *gorm.DB) error {
+		if address.IsDefaultShipping {
+			if err := tx.Model(&model.Address{}).Where("user_id = ? AND id <> ?", address.UserID, address.ID).Update("is_default_shipping", false).Error; err != nil {
+				return err
+			}
+		}
+		if address.IsDefaultBilling {
+			if err := tx.Model(&model.Address{}).Where("user_id = ? AND id <> ?", address.UserID, address.ID).Update("is_default_billing", false).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(&address).Error
+	})
+	return address, err
+}
+
+func (repo *AddressRepository) Delete(id string) error {
+	address, err := repo.Get(id)
+	if err != nil {
+		return err
+	}
+	return repo.DB.Delete(&address).Error
+}
+
+// Shipping Zone Repository
+type ShippingZoneRepository struct {
+	DB *gorm.DB
+}
+
+func (repo *ShippingZoneRepository) Get(id string) (model.ShippingZone, error) {
+	var result model.ShippingZone
+	return result, repo.DB.Preload("Countries").Preload("Methods").First(&result, "id = $1", id).Error
+}
+
+func (repo *ShippingZoneRepository) GetAll() ([]model.ShippingZone, error) {
+	var result []model.ShippingZone
+	return result, repo.DB.Preload("Countries").Preload("Methods").Order("name").Find(&result).Error
+}
+
+// GetByCountry returns the zone the country belongs to.
+func (repo *ShippingZoneRepository) GetByCountry(country string) (model.ShippingZone, error) {
+	var result model.ShippingZone
+	return result, repo.DB.Preload("Countries").
+		Where("id = (?)", repo.DB.Model(&model.ShippingZoneCountry{}).Select("zone_id").Where("country = ?", country)).
+		First(&result).Error
+}
+
+func (repo *ShippingZoneRepository) Create(zone model.ShippingZone) error {
+	return repo.DB.Omit("Methods").Create(&zone).Error
+}
+
+func (repo *ShippingZoneRepository) Update(zone model.ShippingZone) error {
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Omit("Countries", "Methods").Save(&zone).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("zone_id = ?", zone.ID).Delete(&model.ShippingZoneCountry{}).Error; err != nil {
+			return err
+		}
+		for _, country := range zone.Countries {
+			country.ID = 0
+			country.ZoneID = zone.ID
+			if err := tx.Create(&country).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (repo *ShippingZoneRepository) Delete(id string) error {
+	zone, err := repo.Get(id)
+	if err != nil {
+		return err
+	}
+	return repo.DB.Select("Countries", "Methods").Delete(&zone).Error
+}
+
+// Shipping Method Repository
+type ShippingMethodRepository struct {
+	DB *gorm.DB
+}
+
+func (repo *ShippingMethodRepository) Get(id string) (model.ShippingMethod, error) {
+	var result model.ShippingMethod
+	return result, repo.DB.Model(&model.ShippingMethod{}).First(&result, "id = $1", id).Error
+}
+
+// GetActiveByZone returns the methods of a zone customers can choose from.
+func (repo *ShippingMethodRepository) GetActiveByZone(zoneID uint) ([]model.ShippingMethod, error) {
+	var result []model.ShippingMethod
+	return result, repo.DB.Where("zone_id = ? AND active = ?", zoneID, true).Order("id").Find(&result).Error
+}
+
+func (repo *ShippingMethodRepository) Create(method model.ShippingMethod) error {
+	return repo.DB.Create(&method).Error
+}
+
+func (repo *ShippingMethodRepository) Update(method model.ShippingMethod) error {
+	return repo.DB.Save(&method).Error
+}
+
+func (repo *ShippingMethodRepository) Delete(id string) error {
+	method, err := repo.Get(id)
+	if err != nil {
+		return err
+	}
+	return repo.DB.Delete(&method).Error
+}
+
+// Shipment Repository
+type ShipmentRepository struct {
+	DB *gorm.DB
+}
+
+func (repo *ShipmentRepository) Get(id string) (model.Shipment, error) {
+	var result model.Shipment
+	return result, repo.DB.Preload("Items").First(&result, "id = $1", id).Error
+}
+
+func (repo *ShipmentRepository) GetByOrder(orderID uint) ([]model.Shipment, error) {
+	var result []model.Shipment
+	return result, repo.DB.Preload("Items").Where("order_id = ?", orderID).Order("id").Find(&result).Error
+}
+
+// ShippedQuantities returns the quantity already shipped of every item of an
+// order, keyed by order item id.
+func (repo *ShipmentRepository) ShippedQuantities(orderID uint) (map[uint]int, error) {
+	return shippedQuantities(repo.DB, orderID)
+}
+
+func shippedQuantities(db *gorm.DB, orderID uint) (map[uint]int, error) {
+	var rows []struct {
+		OrderItemID uint
+		Quantity    int
+	}
+	err := db.Model(&model.ShipmentItem{}).
+		Select("shipment_items.order_item_id, SUM(shipment_items.quantity) AS quantity").
+		Joins("JOIN shipments ON shipments.id = shipment_items.shipment_id").
+		Where("shipments.order_id = ?", orderID).
+		Group("shipment_items.order_item_id").
+		Scan(&rows).Error
+	result := map[uint]int{}
+	for _, row := range rows {
+		result[row.OrderItemID] = row.Quantity
+	}
+	return result, err
+}
+
+// Create saves the shipment and moves its order to shipped, or partially
+// shipped while items are left, in one transaction. The order is locked so
+// concurrent shipments can't ship more than was ordered.
+func (repo *ShipmentRepository) Create(shipment model.Shipment) (model.Shipment, error) {
+	err := repo.DB.Transaction(func(tx *gorm.DB) error {
+		var order model.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, "id = ?", shipment.OrderID).Error; err != nil {
+			return err
+		}
+		if order.Status != model.ORDER_PAID && order.Status != model.ORDER_PARTIALLY_SHIPPED {
+			return util.OrderNotShippableError
+		}
+		var items []model.OrderItem
+		if err := tx.Where("order_id = ?", order.ID).Find(&items).Error; err != nil {
+			return err
+		}
+		shipped, err := shippedQuantities(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		for _, item := range shipment.Items {
+			shipped[item.OrderItemID] += item.Quantity
+		}
+
+		status := model.ORDER_SHIPPED
+		for _, item := range items {
+			if shipped[item.ID] > item.Quantity {
+				return util.ShipmentQuantityError
+			}
+			if shipped[item.ID] < item.Quantity {
+				status = model.ORDER_PARTIALLY_SHIPPED
+			}
+		}
+		if err := tx.Model(&order).Update("status", status).Error; err != nil {
+			return err
+		}
+		return tx.Create(&shipment).Error
+	})
+	return shipment, err
+}
+
+func (repo *ShipmentRepository) Update(shipment model.Shipment) error {
+	return repo.DB.Omit("Items").Save(&shipment).Error
+}
+
+// Return Repository
+type ReturnRepository struct {
+	DB *gorm.DB
+}
+
+func (repo *ReturnRepository) Get(id string) (model.ReturnRequest, error) {
+	var result model.ReturnRequest
+	return result, repo.DB.Preload("Items").Preload("History").First(&result, "id = $1", id).Error
+}
+
+func (repo *ReturnRepository) GetAll() ([]model.ReturnRequest, error) {
+	var result []model.ReturnRequest
+	return result, repo.DB.Preload("Items").Preload("History").Order("id DESC").Find(&result).Error
+}
+
+func (repo *ReturnRepository) GetByUser(userID uint) ([]model.ReturnRequest, error) {
+	var result []model.ReturnRequest
+	return result, repo.DB.Preload("Items").Preload("History").Where("user_id = ?", userID).Order("id DESC").Find(&result).Error
+}
+
+// returnedQuantities returns the quantity of every item of an order that is
+// in a return which wasn't rejected, keyed by order item id.
+func returnedQuantities(db *gorm.DB, orderID uint) (map[uint]int, error) {
+	var rows []struct {
+		OrderItemID uint
+		Quantity    int
+	}
+	err := db.Model(&model.ReturnItem{}).
+		Select("return_items.order_item_id, SUM(return_items.quantity) AS quantity").
+		Joins("JOIN return_requests ON return_requests.id = return_items.return_request_id").
+		Where("return_requests.order_id = ? AND return_requests.status <> ?", orderID, model.RETURN_REJECTED).
+		Group("return_items.order_item_id").
+		Scan(&rows).Error
+	result := map[uint]int{}
+	for _, row := range rows {
+		result[row.OrderItemID] = row.Quantity
+	}
+	return result, err
+}
+
+// Create saves the return in one transaction. The order is locked so
+// concurrent returns can't send back more than was shipped.
+func (repo *ReturnRepository) Create(request model.ReturnRequest) (model.ReturnRequest, error) {
+	err := repo.DB.Transaction(func(tx *gorm.DB) error {
+		var order model.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&order, "id = ?", request.OrderID).Error; err != nil {
+			return err
+		}
+		shipped, err := shippedQuantities(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		returned, err := returnedQuantities(tx, order.ID)
+		if err != nil {
+			return err
+		}
+		for _, item := range request.Items {
+			returned[item.OrderItemID] += item.Quantity
+			if returned[item.OrderItemID] > shipped[item.OrderItemID] {
+				return util.ReturnQuantityError
+			}
+		}
+		return tx.Create(&request).Error
+	})
+	return request, err
+}
+
+// Transition moves a return from one status to another and records it in the
+// history. It returns util.ReturnStatusError when the return is no longer in
+// the expected status.
+func (repo *ReturnRepository) Transition(id uint, from model.ReturnStatus, to model.ReturnStatus, history model.ReturnHistory) error {
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		return transitionReturn(tx, id, from, to, history)
+	})
+}
+
+func transitionReturn(tx *gorm.DB, id uint, from model.ReturnStatus, to model.ReturnStatus, history model.ReturnHistory) error {
+	result := tx.Model(&model.ReturnRequest{}).Where("id = ? AND status = ?", id, from).Update("status", to)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return util.ReturnStatusError
+	}
+	history.ID = 0
+	history.ReturnRequestID = id
+	history.Status = to
+	return tx.Create(&history).Error
+}
+
+// Inspect stores the outcome of the inspection of a received return and puts
+// the restocked items back in stock in one transaction.
+func (repo *ReturnRepository) Inspect(request model.ReturnRequest, to model.ReturnStatus, history model.ReturnHistory) error {
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := transitionReturn(tx, request.ID, model.RETURN_RECEIVED, to, history); err != nil {
+			return err
+		}
+		if err := tx.Model(&model.ReturnRequest{}).Where("id = ?", request.ID).Update("refund_amount", request.RefundAmount).Error; err != nil {
+			return err
+		}
+		for _, item := range request.Items {
+			if err := tx.Model(&item).Select("accepted", "restocked").Updates(item).Error; err != nil {
+				return err
+			}
+			if !item.Restocked {
+				continue
+			}
+			var orderItem model.OrderItem
+			if err := tx.Select("product_id").First(&orderItem, "id = ?", item.OrderItemID).Error; err != nil {
+				return err
+			}
+			orderID := request.OrderID
+			movement := model.StockMovement{ProductID: orderItem.ProductID, Quantity: item.Quantity, Reason: model.STOCK_RETURN, UserID: &history.ActorID, OrderID: &orderID, ReturnID: &request.ID}
+			if _, err := moveStock(tx, movement); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Attribute Repository
+type AttributeRepository struct {
+	DB *gorm.DB
+}
+
+func (repo *AttributeRepository) Get(id string) (model.AttributeDefinition, error) {
+	var result model.AttributeDefinition
+	return result, repo.DB.First(&result, "id = $1", id).Error
+}
+
+func (repo *AttributeRepository) GetByCategories(categoryIDs []uint) ([]model.AttributeDefinition, error) {
+	var result []model.AttributeDefinition
+	return result, repo.DB.Where("category_id IN ?", categoryIDs).Order("id").Find(&result).Error
+}
+
+func (repo *AttributeRepository) Create(attribute model.AttributeDefinition) (model.AttributeDefinition, error) {
+	return attribute, repo.DB.Create(&attribute).Error
+}
+
+func (repo *AttributeRepository) GetByIDs(ids []uint) ([]model.AttributeDefinition, error) {
+	var result []model.AttributeDefinition
+	return result, repo.DB.Where("id IN ?", ids).Find(&result).Error
+}
+
+// Update saves the attribute in one transaction with the values products have
+// for it. Values of an enum take the case of their option, and the values of
+// options that were removed are deleted.
+func (repo *AttributeRepository) Update(attribute model.AttributeDefinition) (model.AttributeDefinition, error) {
+	return attribute, repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&attribute).Error; err != nil {
+			return err
+		}
+		return syncOptions(tx, attribute)
+	})
+}
+
+// PruneOptions brings the values of every enum attribute in line with its
+// options, for the options removed before updates did it.
+func (repo *AttributeRepository) PruneOptions() error {
+	var attributes []model.AttributeDefinition
+	if err := repo.DB.Where("type = ?", model.ATTRIBUTE_ENUM).Find(&attributes).Error; err != nil {
+		return err
+	}
+	for _, attribute := range attributes {
+		if err := syncOptions(repo.DB, attribute); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// syncOptions gives the values of an enum attribute the case of their option
+// and deletes the values no option matches.
+func syncOptions(tx *gorm.DB, attribute model.AttributeDefinition) error {
+	if attribute.Type != model.ATTRIBUTE_ENUM {
+		return nil
+	}
+	for _, option := range attribute.Options {
+		err := tx.Model(&model.ProductAttributeValue{}).
+			Where("attribute_id = ? AND LOWER(value) = LOWER(?) AND value <> ?", attribute.ID, option, option).
+			Update("value", option).Error
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Where("attribute_id = ? AND value NOT IN ?", attribute.ID, attribute.Options).Delete(&model.ProductAttributeValue{}).Error
+}
+
+// Delete removes the attribute with the values products have for it in one
+// transaction.
+func (repo *AttributeRepository) Delete(id string) error {
+	attribute, err := repo.Get(id)
+	if err != nil {
+		return err
+	}
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("attribute_id = ?", attribute.ID).Delete(&model.ProductAttributeValue{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&attribute).Error
+	})
+}
+
+// Slug Redirect Repository
+type SlugRedirectRepository struct {
+	DB *gorm.DB
+}
+
+func (repo *SlugRedirectRepository) Get(entity model.SlugEntity, slug string) (model.SlugRedirect, error) {
+	var result model.SlugRedirect
+	return result, repo.DB.First(&result, "entity = ? AND slug = ?", entity, slug).Error
+}
+
+func slugTaken(db *gorm.DB, table interface{}, slug string, exceptID uint) (bool, error) {
+	var count int64
+	err := db.Unscoped().Model(table).Where("slug = ? AND id <> ?", slug, exceptID).Count(&count).Error
+	return count > 0, err
+}
+
+// recordSlugChange points the old slug of an entity to it. The new slug stops
+// being a redirect, since it now resolves directly.
+func recordSlugChange(tx *gorm.DB, entity model.SlugEntity, id uint, oldSlug string, newSlug string) error {
+	if oldSlug == newSlug {
+		return nil
+	}
+	if err := tx.Where("entity = ? AND slug = ?", entity, newSlug).Delete(&model.SlugRedirect{}).Error; err != nil {
+		return err
+	}
+	if oldSlug == "" {
+		return nil
+	}
+	return tx.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "entity"}, {Name: "slug"}},
+		DoUpdates: clause.AssignmentColumns([]string{"entity_id", "created_at"}),
+	}).Create(&model.SlugRedirect{Entity: entity, Slug: oldSlug, EntityID: id}).Error
+}
+
+// ImportJob Repository
+type ImportJobRepository struct {
+	DB *gorm.DB
+}
+
+func (repo *ImportJobRepository) Get(id string) (model.ImportJob, error) {
+	var result model.ImportJob
+	return result, repo.DB.First(&result, "id = $1", id).Error
+}
+
+func (repo *ImportJobRepository) Create(job model.ImportJob) (model.ImportJob, error) {
+	return job, repo.DB.Create(&job).Error
+}
+
+func (repo *ImportJobRepository) Update(job model.ImportJob) error {
+	return repo.DB.Save(&job).Error
+}
+
+// FailUnfinished marks the jobs that are still pending or running as failed.
+func (repo *ImportJobRepository) FailUnfinished(message string) error {
+	return repo.DB.Model(&model.ImportJob{}).Where("status IN ?", []model.ImportStatus{model.IMPORT_PENDING, model.IMPORT_RUNNING}).
+		Updates(map[string]interface{}{"status": model.IMPORT_FAILED, "message": message, "finished_at": time.Now()}).Error
+}
+
+// ProductFeed Repository
+type ProductFeedRepository struct {
+	DB *gorm.DB
+}
+
+func (repo *ProductFeedRepository) Get(format model.ExportFormat) (model.ProductFeed, error) {
+	var result model.ProductFeed
+	return result, repo.DB.First(&result, "format = ?", format).Error
+}
+
+// Save replaces the feed of the format.
+func (repo *ProductFeedRepository) Save(feed model.ProductFeed) (model.ProductFeed, error) {
+	return feed, repo.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "format"}},
+		DoUpdates: clause.AssignmentColumns([]string{"content", "product_count", "generated_at"}),
+	}).Create(&feed).Error
+}
+
+// PriceHistory Repository
+type PriceHistoryRepository struct {
+	DB *gorm.DB
+}
+
+// GetByProduct returns the price history of a product, newest first.
+func (repo *PriceHistoryRepository) GetByProduct(productID uint) ([]model.PriceHistory, error) {
+	var result []model.PriceHistory
+	return result, repo.DB.Where("product_id = ?", productID).Order("created_at DESC").Order("id DESC").Find(&result).Error
+}
+
+// LowestPrices returns, by product, the lowest price the products had in the
+// period before their current price took effect: the price they had when the
+// period started and the prices they had after it. Products without an
+// earlier price are left out.
+func (repo *PriceHistoryRepository) LowestPrices(productIDs []uint, period time.Duration) (map[uint]float64, error) {
+	var prices []struct {
+		ProductID uint
+		Price     float64
+	}
+	err := repo.DB.Raw(`WITH latest AS (
+		SELECT DISTINCT ON (product_id) id, product_id, created_at FROM price_histories
+		WHERE product_id IN ? ORDER BY product_id, created_at DESC, id DESC
+	) SELECT latest.product_id, MIN(history.price) AS price FROM latest
+	JOIN price_histories history ON history.product_id = latest.product_id AND history.id <> latest.id
+	WHERE history.created_at >= latest.created_at - make_interval(secs => ?) OR history.id = (
+		SELECT previous.id FROM price_histories previous
+		WHERE previous.product_id = latest.product_id AND previous.created_at < latest.created_at - make_interval(secs => ?)
+		ORDER BY previous.created_at DESC, previous.id DESC LIMIT 1
+	) GROUP BY latest.product_id`, productIDs, period.Seconds(), period.Seconds()).Scan(&prices).Error
+	if err != nil {
+		return nil, err
+	}
+	result := map[uint]float64{}
+	for _, price := range prices {
+		result[price.ProductID] = price.Price
+	}
+	return result, nil
+}
+
+// BackfillBaseline records the price of the products without a price history
+// as their first entry, dated when the product was last updated.
+func (repo *PriceHistoryRepository) BackfillBaseline() error {
+	var products []model.Product
+	err := repo.DB.Unscoped().Select("id", "price", "updated_at").
+		Where("NOT EXISTS (?)", repo.DB.Model(&model.PriceHistory{}).Select("1").Where("price_histories.product_id = products.id")).
+		Find(&products).Error
+	if err != nil || len(products) == 0 {
+		return err
+	}
+	entries := make([]model.PriceHistory, len(products))
+	for i, product := range products {
+		entries[i] = model.PriceHistory{
+			ProductID: product.ID,
+			Price:     product.Price,
+			Source:    model.PRICE_BASELINE,
+			CreatedAt: product.UpdatedAt,
+		}
+	}
+	return repo.DB.CreateInBatches(entries, 100).Error
+}
+
+// recordPrice adds a price to the history of a product.
+func recordPrice(tx *gorm.DB, productID uint, price float64, source model.PriceSource, scheduledPriceID *uint) error {
+	return tx.Create(&model.PriceHistory{ProductID: productID, Price: price, Source: source, ScheduledPriceID: scheduledPriceID}).Error
+}
+
+// ScheduledPrice Repository
+type ScheduledPriceRepository struct {
+	DB *gorm.DB
+}
+
+func (repo *ScheduledPriceRepository) Get(id string) (model.ScheduledPrice, error) {
+	var result model.ScheduledPrice
+	return result, repo.DB.First(&result, "id = $1", id).Error
+}
+
+// GetByProduct returns the scheduled prices of a product, latest start first.
+func (repo *ScheduledPriceRepository) GetByProduct(productID uint) ([]model.ScheduledPrice, error) {
+	var result []model.ScheduledPrice
+	return result, repo.DB.Where("product_id = ?", productID).Order("starts_at DESC").Order("id DESC").Find(&result).Error
+}
+
+// GetActive returns the scheduled prices the products are on.
+func (repo *ScheduledPriceRepository) GetActive(productIDs []uint) ([]model.ScheduledPrice, error) {
+	var result []model.ScheduledPrice
+	return result, repo.DB.Where("product_id IN ? AND status = ?", productIDs, model.SCHEDULED_PRICE_ACTIVE).Find(&result).Error
+}
+
+// Create saves the scheduled price unless it overlaps another pending or
+// active one of the product. The product is locked so two overlapping
+// schedules can't be saved at the same time.
+func (repo *ScheduledPriceRepository) Create(schedule model.ScheduledPrice) (model.ScheduledPrice, error) {
+	err := repo.DB.Transaction(func(tx *gorm.DB) error {
+		var product model.Product
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").First(&product, "id = ?", schedule.ProductID).Error; err != nil {
+			return err
+		}
+		query := tx.Model(&model.ScheduledPrice{}).
+			Where("product_id = ? AND status IN ?", schedule.ProductID, []model.ScheduledPriceStatus{model.SCHEDULED_PRICE_PENDING, model.SCHEDULED_PRICE_ACTIVE}).
+			Where("ends_at IS NULL OR ends_at > ?", schedule.StartsAt)
+		if schedule.EndsAt != nil {
+			query = query.Where("starts_at < ?", *schedule.EndsAt)
+		}
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return util.ScheduledPriceOverlapError
+		}
+		schedule.Status = model.SCHEDULED_PRICE_PENDING
+		return tx.Create(&schedule).Error
+	})
+	return schedule, err
+}
+
+// Cancel stops a scheduled price. A pending one never starts and an active
+// one ends now, bringing the previous price back.
+func (repo *ScheduledPriceRepository) Cancel(id uint) error {
+	return repo.DB.Transaction(func(tx *gorm.DB) error {
+		var schedule model.ScheduledPrice
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&schedule, "id = ?", id).Error; err != nil {
+			return err
+		}
+		switch schedule.Status {
+		case model.SCHEDULED_PRICE_PENDING:
+			return tx.Model(&schedule).Updates(map[string]interface{}{"status": model.SCHEDULED_PRICE_CANCELLED, "ended_at": time.Now()}).Error
+		case model.SCHEDULED_PRICE_ACTIVE:
+			return endSchedule(tx, schedule, model.SCHEDULED_PRICE_CANCELLED)
+		}
+		return util.ScheduledPriceFinishedError
+	})
+}
+
+// ApplyDue ends the active scheduled prices whose end has passed, then starts
+// the pending ones whose start has passed, so back to back schedules follow
+// each other. Each schedule is applied in its own transaction and it returns
+// how many were applied.
+func (repo *ScheduledPriceRepository) ApplyDue(now time.Time) (int, error) {
+	applied := 0
+	var ending []uint
+	err := repo.DB.Model(&model.ScheduledPrice{}).Where("status = ? AND ends_at <= ?", model.SCHEDULED_PRICE_ACTIVE, now).
+		Order("ends_at").Pluck("id", &ending).Error
+	if err != nil {
+		return applied, err
+	}
+	for _, id := range ending {
+		err := repo.DB.Transaction(func(tx *gorm.DB) error {
+			var schedule model.ScheduledPrice
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&schedule, "id = ? AND status = ?", id, model.SCHEDULED_PRICE_ACTIVE).Error
+			if err != nil {
+				return err
+			}
+			return endSchedule(tx, schedule, model.SCHEDULED_PRICE_ENDED)
+		})
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return applied, err
+		}
+		applied++
+	}
+
+	var starting []uint
+	err = repo.DB.Model(&model.ScheduledPrice{}).Where("status = ? AND starts_at <= ?", model.SCHEDULED_PRICE_PENDING, now).
+		Order("starts_at").Pluck("id", &starting).Error
+	if err != nil {
+		return applied, err
+	}
+	for _, id := range starting {
+		err := repo.DB.Transaction(func(tx *gorm.DB) error {
+			var schedule model.ScheduledPrice
+			err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&schedule, "id = ? AND status = ?", id, model.SCHEDULED_PRICE_PENDING).Error
+			if err != nil {
+				return err
+			}
+			return startSchedule(tx, schedule, now)
+		})
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return applied, err
+		}
+		applied++
+	}
+	return applied, nil
+}
+
+// startSchedule puts the product on the scheduled price, keeping the price it
+// had. A schedule that already ended before it could start, or whose product
+// is gone, ends without changing anything.
+func startSchedule(tx *gorm.DB, schedule model.ScheduledPrice, now time.Time) error {
+	var product model.Product
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "price").First(&product, "id = ?", schedule.ProductID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err != nil || (schedule.EndsAt != nil && !schedule.EndsAt.After(now)) {
+		return tx.Model(&schedule).Updates(map[string]interface{}{"status": model.SCHEDULED_PRICE_ENDED, "ended_at": now}).Error
+	}
+	if err := tx.Model(&product).Update("price", schedule.Price).Error; err != nil {
+		return err
+	}
+	if err := recordPrice(tx, product.ID, schedule.Price, model.PRICE_SCHEDULED, &schedule.ID); err != nil {
+		return err
+	}
+	return tx.Model(&schedule).Updates(map[string]interface{}{
+		"status":         model.SCHEDULED_PRICE_ACTIVE,
+		"previous_price": product.Price,
+		"applied_at":     now,
+	}).Error
+}
+
+// endSchedule takes the product off the scheduled price. The previous price
+// only comes back when the price wasn't changed by hand in the meantime.
+func endSchedule(tx *gorm.DB, schedule model.ScheduledPrice, status model.ScheduledPriceStatus) error {
+	var product model.Product
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "price").First(&product, "id = ?", schedule.ProductID).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	if err == nil && schedule.PreviousPrice != nil && product.Price == schedule.Price {
+		if err := tx.Model(&product).Update("price", *schedule.PreviousPrice).Error; err != nil {
+			return err
+		}
+		if err := recordPrice(tx, product.ID, *schedule.PreviousPrice, model.PRICE_SCHEDULED, &schedule.ID); err != nil {
+			return err
+		}
+	}
+	return tx.Model(&schedule).Updates(map[string]interface{}{"status": status, "ended_at": time.Now()}).Error
+}
+
+// StockMovement Repository
+type StockMovementRepository struct {
+	DB *gorm.DB
+}
+
+// StockDiscrepancy is a product whose stock doesn't match the sum of its
+// stock movements.
+type StockDiscrepancy struct {
+	ProductID   uint    `json:"product_id"`
+	Name        string  `json:"name"`
+	SKU         *string `json:"sku"`
+	Stock       int     `json:"stock"`
+	LedgerStock int     `json:"ledger_stock"`
+}
+
+// GetByProduct returns the stock movements of a product made between from and
+// to, newest first. A zero time leaves that side open.
+func (repo *StockMovementRepository) GetByProduct(productID uint, from time.Time, to time.Time) ([]model.StockMovement, error) {
+	var result []model.StockMovement
+	query := repo.DB.Where("product_id = ?", productID)
+	if !from.IsZero() {
+		query = query.Where("created_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("created_at < ?", to)
+	}
+	return result, query.Order("created_at DESC").Order("id DESC").Find(&result).Error
+}
+
+// Adjust changes the stock of the product of the movement by its quantity.
+func (repo *StockMovementRepository) Adjust(movement model.StockMovement) (model.StockMovement, error) {
+	err := repo.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		movement, err = moveStock(tx, movement)
+		return err
+	})
+	return movement, err
+}
+
+// Set changes the stock of the product of the movement to stock. Nothing is
+// recorded when the product already has that stock.
+func (repo *StockMovementRepository) Set(movement model.StockMovement, stock uint) (model.StockMovement, error) {
+	err := repo.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		movement, err = setStock(tx, movement, stock)
+		return err
+	})
+	return movement, err
+}
+
+// LedgerStock returns the sum of the stock movements of a product.
+func (repo *StockMovementRepository) LedgerStock(productID uint) (int, error) {
+	var result int
+	return result, repo.DB.Model(&model.StockMovement{}).Select("COALESCE(SUM(quantity), 0)").Where("product_id = ?", productID).Scan(&result).Error
+}
+
+// GetDiscrepancies returns the products, deleted ones included, whose stock
+// doesn't match their stock movements.
+func (repo *StockMovementRepository) GetDiscrepancies() ([]StockDiscrepancy, error) {
+	var result []StockDiscrepancy
+	return result, repo.DB.Unscoped().Model(&model.Product{}).
+		Select("products.id AS product_id, products.name, products.sku, products.stock, COALESCE(SUM(stock_movements.quantity), 0) AS ledger_stock").
+		Joins("LEFT JOIN stock_movements ON stock_movements.product_id = products.id").
+		Group("products.id").
+		Having("products.stock <> COALESCE(SUM(stock_movements.quantity), 0)").
+		Order("products.id").Scan(&result).Error
+}
+
+// Reconcile sets the stock of the products that don't match their stock
+// movements back to the sum of the movements and returns them as they were.
+// Corrections of a ledger below zero are recorded as adjustments of the user.
+func (repo *StockMovementRepository) Reconcile(userID uint) ([]StockDiscrepancy, error) {
+	var result []StockDiscrepancy
+	err := repo.DB.Transaction(func(tx *gorm.DB) error {
+		ledger := tx.Model(&model.StockMovement{}).Select("COALESCE(SUM(quantity), 0)").Where("stock_movements.product_id = products.id")
+		var ids []uint
+		if err := tx.Unscoped().Model(&model.Product{}).Where("stock <> (?)", ledger).Pluck("id", &ids).Error; err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		if err := lockProduct(tx.Unscoped(), ids...); err != nil {
+			return err
+		}
+		err := tx.Unscoped().Model(&model.Product{}).
+			Select("products.id AS product_id, products.name, products.sku, products.stock, (?) AS ledger_stock", ledger).
+			Where("id IN ? AND stock <> (?)", ids, ledger).Order("id").Scan(&result).Error
+		if err != nil {
+			return err
+		}
+		for _, discrepancy := range result {
+			stock, movement := reconcileMovement(discrepancy, userID)
+			if err := tx.Unscoped().Model(&model.Product{}).Where("id = ?", discrepancy.ProductID).Update("stock", stock).Error; err != nil {
+				return err
+			}
+			if movement != nil {
+				if err := tx.Create(movement).Error; err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	return result, err
+}
+
+// reconcileMovement returns the stock a product is set back to and the
+// movement recorded for it, if any. Stock can't be negative, so a ledger below
+// zero leaves the product at zero with an adjustment bringing the ledger up
+// to it.
+func reconcileMovement(discrepancy StockDiscrepancy, userID uint) (uint, *model.StockMovement) {
+	if discrepancy.LedgerStock >= 0 {
+		return uint(discrepancy.LedgerStock), nil
+	}
+	return 0, &model.StockMovement{
+		ProductID:  discrepancy.ProductID,
+		Quantity:   -discrepancy.LedgerStock,
+		StockAfter: 0,
+		Reason:     model.STOCK_ADJUSTMENT,
+		Note:       "Reconciled a stock ledger below zero",
+		UserID:     &userID,
+	}
+}
+
+// BackfillOpening records the stock the products had before stock movements
+// were recorded as their opening movement, so their ledger adds up to it.
+func (repo *StockMovementRepository) BackfillOpening() error {
+	var products []model.Product
+	err := repo.DB.Unscoped().Select("id", "stock").
+		Where("stock > 0 AND NOT EXISTS (?)", repo.DB.Model(&model.StockMovement{}).Select("1").Where("stock_movements.product_id = products.id")).
+		Find(&products).Error
+	if err != nil || len(products) == 0 {
+		return err
+	}
+	movements := make([]model.StockMovement, len(products))
+	for i, product := range products {
+		movements[i] = model.StockMovement{
+			ProductID:  product.ID,
+			Quantity:   int(product.Stock),
+			StockAfter: product.Stock,
+			Reason:     model.STOCK_OPENING,
+			Note:       "Stock before the stock ledger",
+		}
+	}
+	return repo.DB.CreateInBatches(movements, 100).Error
+}
+
+// moveStock changes the stock of a product by the quantity of the movement
+// and records it. Stock can't go below zero.
+func moveStock(tx *gorm.DB, movement model.StockMovement) (model.StockMovement, error) {
+	var product model.Product
+	err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "stock").First(&product, "id = ?", movement.ProductID).Error
+	if err != nil {
+		return movement, err
+	}
+	stock := int(product.Stock) + movement.Quantity
+	if stock < 0 {
+		return movement, fmt.Errorf("%w: %d left of product %d", util.OutOfStockError, product.Stock, product.ID)
+	}
+	if err := tx.Unscoped().Model(&product).Update("stock", stock).Error; err != nil {
+		return movement, err
+	}
+	movement.StockAfter = uint(stock)
+	return movement, tx.Create(&movement).Error
+}
+
+// setStock changes the stock of a product to stock, recording the difference
+// as the movement. Nothing is recorded when the stock doesn't change.
+func setStock(tx *gorm.DB, movement model.StockMovement, stock uint) (model.StockMovement, error) {
+	var product model.Product
+	err := tx.Unscoped().Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "stock").First(&product, "id = ?", movement.ProductID).Error
+	if err != nil {
+		return movement, err
+	}
+	movement.Quantity = int(stock) - int(product.Stock)
+	if movement.Quantity == 0 {
+		movement.StockAfter = stock
+		return movement, nil
+	}
+	return moveStock(tx, movement)
+}
+
+// Notification Repository
+type NotificationRepository struct {
+	DB *gorm.DB
+}
+
+// GetByUser returns a page of the notifications of a user, newest first, with
+// the total count of their notifications.
+func (repo *NotificationRepository) GetByUser(userID uint, page int, pageSize int) ([]model.Notification, int64, error) {
+	var result []model.Notification
+	var total int64
+	query := repo.DB.Model(&model.Notification{}).Where("user_id = ?", userID)
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	err := query.Order("created_at DESC").Order("id DESC").
+		Offset((page - 1) * pageSize).Limit(pageSize).
+		Find(&result).Error
+	return result, total, err
+}
+
+func (repo *NotificationRepository) CountUnread(userID uint) (int64, error) {
+	var result int64
+	return result, repo.DB.Model(&model.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Count(&result).Error
+}
+
+// MarkRead marks a notification of a user as read. Notifications already read
+// keep the time they were first read.
+func (repo *NotificationRepository) MarkRead(id uint, userID uint) error {
+	var notification model.Notification
+	if err := repo.DB.First(&notification, "id = ? AND user_id = ?", id, userID).Error; err != nil {
+		return err
+	}
+	return repo.DB.Model(&model.Notification{}).Where("id = ? AND read_at IS NULL", id).Update("read_at", time.Now()).Error
+}
+
+func (repo *NotificationRepository) MarkAllRead(userID uint) error {
+	return repo.DB.Model(&model.Notification{}).Where("user_id = ? AND read_at IS NULL", userID).Update("read_at", time.Now()).Error
+}
+
+// Refund Repository
+type RefundRepository struct {
+	DB *gorm.DB
+}
+
+func (repo *RefundRepository) Create(refund model.Refund) (model.Refund, error) {
+	return refund, repo.DB.Create(&refund).Error
+}
+
+// RefundedAmount returns the amount successfully refunded for a payment.
+func (repo *RefundRepository) RefundedAmount(paymentID uint) (float64, error) {
+	var result float64
+	return result, repo.DB.Model(&model.Refund{}).Select("COALESCE(SUM(amount), 0)").
+		Where("payment_id = ? AND status = ?", paymentID, model.SUCCESS).Scan(&result).Error
+}
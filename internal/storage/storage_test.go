@@ -0,0 +1,120 @@
+package storage
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/fatihesergg/go_ecommerce/internal/model"
+	"github.com/fatihesergg/go_ecommerce/internal/util"
+)
+
+func TestMoveError(t *testing.T) {
+	// Category 1 has the children 2 and 3, and 3 has the child 4.
+	subtree := []uint{1, 2, 3, 4}
+	tests := []struct {
+		name   string
+		parent uint
+		err    error
+	}{
+		{name: "under another branch", parent: 5},
+		{name: "under itself", parent: 1, err: util.CategoryCycleError},
+		{name: "under a child", parent: 2, err: util.CategoryCycleError},
+		{name: "under a grandchild", parent: 4, err: util.CategoryCycleError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := moveError(subtree, test.parent); !errors.Is(err, test.err) {
+				t.Errorf("got %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestDeletionError(t *testing.T) {
+	// Category 1 has the children 2 and 3.
+	subtree := []uint{1, 2, 3}
+	target := func(id uint) *uint { return &id }
+	tests := []struct {
+		name     string
+		deletion CategoryDeletion
+		err      error
+	}{
+		{name: "unused", deletion: CategoryDeletion{CategoryID: 1}},
+		{name: "attributes alone are dropped", deletion: CategoryDeletion{CategoryID: 1, Attributes: 2}},
+		{name: "products need a target", deletion: CategoryDeletion{CategoryID: 1, Products: 1}, err: util.CategoryInUseError},
+		{name: "subcategories need a target", deletion: CategoryDeletion{CategoryID: 1, Subcategories: 1}, err: util.CategoryInUseError},
+		{name: "promotions need a target", deletion: CategoryDeletion{CategoryID: 1, Promotions: 1}, err: util.CategoryInUseError},
+		{name: "coupons need a target", deletion: CategoryDeletion{CategoryID: 1, Coupons: 1}, err: util.CategoryInUseError},
+		{name: "reassigned to another branch", deletion: CategoryDeletion{CategoryID: 1, ReassignedTo: target(5), Products: 1}},
+		{name: "reassigned to itself", deletion: CategoryDeletion{CategoryID: 1, ReassignedTo: target(1), Products: 1}, err: util.CategoryReassignError},
+		{name: "reassigned inside its subtree", deletion: CategoryDeletion{CategoryID: 1, ReassignedTo: target(3)}, err: util.CategoryReassignError},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if err := deletionError(test.deletion, subtree); !errors.Is(err, test.err) {
+				t.Errorf("got %v, want %v", err, test.err)
+			}
+		})
+	}
+}
+
+func TestRestoredParent(t *testing.T) {
+	parentID := uint(7)
+	failure := errors.New("connection reset")
+	tests := []struct {
+		name   string
+		err    error
+		parent *uint
+		fail   error
+	}{
+		{name: "parent still there", parent: &parentID},
+		{name: "parent deleted too goes to the root", err: util.ParentCategoryNotFoundError},
+		{name: "lookup failed", err: failure, fail: failure},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			parent, err := restoredParent(&parentID, test.err)
+			if !errors.Is(err, test.fail) {
+				t.Fatalf("got error %v, want %v", err, test.fail)
+			}
+			if parent != test.parent {
+				t.Errorf("got parent %v, want %v", parent, test.parent)
+			}
+		})
+	}
+}
+
+func TestReconcileMovement(t *testing.T) {
+	userID := uint(3)
+	tests := []struct {
+		name        string
+		discrepancy StockDiscrepancy
+		stock       uint
+		movement    *model.StockMovement
+	}{
+		{name: "stock above the ledger", discrepancy: StockDiscrepancy{ProductID: 1, Stock: 12, LedgerStock: 10}, stock: 10},
+		{name: "stock below the ledger", discrepancy: StockDiscrepancy{ProductID: 1, Stock: 4, LedgerStock: 10}, stock: 10},
+		{name: "ledger at zero", discrepancy: StockDiscrepancy{ProductID: 1, Stock: 2, LedgerStock: 0}, stock: 0},
+		{
+			name:        "ledger below zero is adjusted up to zero",
+			discrepancy: StockDiscrepancy{ProductID: 1, Stock: 2, LedgerStock: -5},
+			stock:       0,
+			movement:    &model.StockMovement{ProductID: 1, Quantity: 5, Reason: model.STOCK_ADJUSTMENT, Note: "Reconciled a stock ledger below zero", UserID: &userID},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			stock, movement := reconcileMovement(test.discrepancy, userID)
+			if stock != test.stock {
+				t.Errorf("got stock %d, want %d", stock, test.stock)
+			}
+			if !reflect.DeepEqual(movement, test.movement) {
+				t.Errorf("got movement %+v, want %+v", movement, test.movement)
+			}
+			if movement != nil && test.discrepancy.LedgerStock+movement.Quantity != int(stock) {
+				t.Errorf("ledger %d after the movement doesn't match stock %d", test.discrepancy.LedgerStock+movement.Quantity, stock)
+			}
+		})
+	}
+}
@@ -8,6 +8,14 @@ import (
 
 var JsonDecodeError = errors.New("Error while decoding json")
 
+var UnsupportedCurrencyError = errors.New("Unsupported currency")
+
+var IdempotencyKeyMismatchError = errors.New("Idempotency-Key was already used with a different request")
+
+var IdempotencyKeyInProgressError = errors.New("A request with this Idempotency-Key is still in progress")
+
+var PaymentFailedError = errors.New("Payment failed")
+
 func FieldErrorMessage(fe validator.FieldError) string {
 	switch fe.Tag() {
 	case "required":
@@ -26,3 +34,89 @@ func GetErrorMessages(ve validator.ValidationErrors) string {
 	}
 	return result
 }
+
+var CouponNotFoundError = errors.New("Invalid coupon code")
+
+var CouponNotActiveError = errors.New("Coupon is not active")
+
+var CouponUsageLimitError = errors.New("Coupon usage limit reached")
+
+var CouponUserLimitError = errors.New("You have reached the usage limit of this coupon")
+
+var CouponMinimumNotMetError = errors.New("Order total is below the coupon minimum")
+
+var CouponNotApplicableError = errors.New("Coupon doesn't apply to any product in the order")
+
+var CouponValueError = errors.New("Percentage coupons can't take more than 100% off")
+
+var InvalidAddressError = errors.New("Invalid address")
+
+var ReviewExistsError = errors.New("You have already reviewed this product")
+
+var ReviewOwnVoteError = errors.New("You can't vote on or report your own review")
+
+var ReviewReportedError = errors.New("You have already reported this review")
+
+var ReviewReplyExistsError = errors.New("Review already has a reply")
+
+var OrderNotShippableError = errors.New("Order can't be shipped in its current status")
+
+var ShipmentQuantityError = errors.New("Shipment quantity is more than the quantity left to ship")
+
+var ShipmentItemNotInOrderError = errors.New("Shipment item doesn't belong to the order")
+
+var ShipmentDeliveredError = errors.New("Delivered shipments can't change status")
+
+var OrderNotReturnableError = errors.New("Order has no shipped items to return")
+
+var ReturnQuantityError = errors.New("Return quantity is more than the quantity left to return")
+
+var ReturnItemNotInOrderError = errors.New("Return item doesn't belong to the order")
+
+var ReturnStatusError = errors.New("Return can't be moved to this status")
+
+var RefundLimitError = errors.New("Refund is more than the amount left to refund")
+
+var RefundNotRecordedError = errors.New("Refund was issued but couldn't be recorded")
+
+var ShippingMethodNotAvailableError = errors.New("Shipping method is not available for this address")
+
+var ParentCategoryNotFoundError = errors.New("Parent category not found")
+
+var CategoryCycleError = errors.New("Category can't be moved under itself or its descendants")
+
+var CategoryInUseError = errors.New("Category has products, subcategories, promotions or coupons; give a category to reassign them to")
+
+var ReassignCategoryNotFoundError = errors.New("Category to reassign to not found")
+
+var CategoryReassignError = errors.New("Category can't be reassigned to itself or its descendants")
+
+var SlugTakenError = errors.New("Slug is already in use")
+
+var InvalidAttributeError = errors.New("Invalid attribute")
+
+var SKUTakenError = errors.New("SKU is already in use")
+
+var SKURequiredError = errors.New("SKU is required")
+
+var ImportFormatError = errors.New("Unsupported import format, use csv or json")
+
+var ImportFileError = errors.New("Invalid import file")
+
+var ImportCategoryError = errors.New("Invalid category")
+
+var ExportFormatError = errors.New("Unsupported export format, use csv, json or xml")
+
+var ProductCategoryDeletedError = errors.New("Category of the product is deleted, restore the category first")
+
+var ScheduledPriceOverlapError = errors.New("Scheduled price overlaps another scheduled price of the product")
+
+var ScheduledPriceFinishedError = errors.New("Scheduled price has already ended or been cancelled")
+
+var ScheduledPriceWindowError = errors.New("Invalid scheduled price")
+
+var OutOfStockError = errors.New("Not enough stock")
+
+var OrderNotCancellableError = errors.New("Only pending orders can be cancelled")
+
+var StockAdjustmentError = errors.New("Invalid stock adjustment")
@@ -3,8 +3,11 @@ package util
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"net/http"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
@@ -61,3 +64,39 @@ func ParseJWT(jwtToken string) (*JwtTokenClaims, error) {
 func EncryptPassword(password string) ([]byte, error) {
 	return bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
 }
+
+// RoundAmount rounds a monetary amount to two decimal places.
+func RoundAmount(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
+
+// slugReplacer transliterates the lowercase letters of the Turkish alphabet
+// and other common accented letters to ASCII.
+var slugReplacer = strings.NewReplacer(
+	"ç", "c", "ğ", "g", "ı", "i", "ö", "o", "ş", "s", "ü", "u",
+	"â", "a", "î", "i", "û", "u",
+	"á", "a", "à", "a", "ä", "a", "ã", "a", "å", "a", "é", "e", "è", "e", "ê", "e", "ë", "e",
+	"í", "i", "ì", "i", "ï", "i", "ó", "o", "ò", "o", "ô", "o", "õ", "o", "ú", "u", "ù", "u",
+	"ñ", "n", "ß", "ss", "æ", "ae", "ø", "o", "œ", "oe",
+)
+
+// Slugify turns a name into a lowercase URL slug of ASCII letters, digits and
+// single dashes.
+func Slugify(name string) string {
+	// İ lowercases to i with a combining dot, so it is mapped before.
+	name = slugReplacer.Replace(strings.ToLower(strings.ReplaceAll(name, "İ", "i")))
+	var slug strings.Builder
+	dash := false
+	for _, r := range name {
+		if r < unicode.MaxASCII && (unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			if dash && slug.Len() > 0 {
+				slug.WriteByte('-')
+			}
+			slug.WriteRune(r)
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return slug.String()
+}
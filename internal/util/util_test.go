@@ -0,0 +1,29 @@
+package util
+
+import "testing"
+
+func TestSlugify(t *testing.T) {
+	tests := []struct {
+		name string
+		slug string
+	}{
+		{name: "Running Shoes", slug: "running-shoes"},
+		{name: "  Men's T-Shirt (XL)  ", slug: "men-s-t-shirt-xl"},
+		{name: "Éclair", slug: "eclair"},
+		{name: "İSTANBUL Çayı", slug: "istanbul-cayi"},
+		{name: "ÇĞIÖŞÜ çğıöşü", slug: "cgiosu-cgiosu"},
+		{name: "Crème Brûlée", slug: "creme-brulee"},
+		{name: "Straße ÆØ Œuvre", slug: "strasse-aeo-oeuvre"},
+		{name: "Señor Niño", slug: "senor-nino"},
+		{name: "4K -- TV!!", slug: "4k-tv"},
+		{name: "日本", slug: ""},
+		{name: "", slug: ""},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if slug := Slugify(test.name); slug != test.slug {
+				t.Errorf("Slugify(%q) = %q, want %q", test.name, slug, test.slug)
+			}
+		})
+	}
+}